@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cobra"
+)
+
+// cachePrefixes - ключи, которые репозитории кешируют в Redis, и теги их зависимостей;
+// модель рекомендаций удаляется только по флагу, потому что до следующего пересчёта похожие книги будут считаться заново
+var cachePrefixes = []string{"user:", "book:", "tag:"}
+
+const (
+	recommendationsPrefix = "recommendations:"
+	cacheScanCount        = 500
+)
+
+func newCacheCommand(cli *cli) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the Redis cache",
+	}
+
+	var recommendations bool
+	purge := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete cached users and books so they are read from Postgres again",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connections, err := cli.connect(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer connections.Close()
+
+			prefixes := cachePrefixes
+			if recommendations {
+				prefixes = append(prefixes[:len(prefixes):len(prefixes)], recommendationsPrefix)
+			}
+			for _, prefix := range prefixes {
+				deleted, err := purgePrefix(cmd.Context(), connections.RedisClient, prefix)
+				if err != nil {
+					return fmt.Errorf("purging %s*: %w", prefix, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Deleted %d keys matching %s*\n", deleted, prefix)
+			}
+			return nil
+		},
+	}
+	purge.Flags().BoolVar(&recommendations, "recommendations", false,
+		"also delete the recommendation model until the next recompute")
+	command.AddCommand(purge)
+	return command
+}
+
+// purgePrefix удаляет ключи через SCAN, а не KEYS, чтобы не блокировать Redis, который обслуживает сервер
+func purgePrefix(ctx context.Context, redisClient *redis.Client, prefix string) (int64, error) {
+	var deleted int64
+	iter := redisClient.Scan(ctx, 0, prefix+"*", cacheScanCount).Iterator()
+	batch := make([]string, 0, cacheScanCount)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		count, err := redisClient.Del(ctx, batch...).Result()
+		deleted += count
+		batch = batch[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cacheScanCount {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/service"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/mapper"
+
+	"github.com/spf13/cobra"
+)
+
+func newCatalogCommand(cli *cli) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "catalog",
+		Short: "Import and export the book catalog as JSON",
+	}
+	command.AddCommand(newCatalogExportCommand(cli), newCatalogImportCommand(cli))
+	return command
+}
+
+func newCatalogExportCommand(cli *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "export [FILE]",
+		Short: "Write all books that are not deleted to FILE or stdout",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			connections, err := cli.connect(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer connections.Close()
+
+			books, err := service.NewBookService(connections.bookRepository()).GetAll(cmd.Context())
+			if err != nil {
+				return err
+			}
+			bookDTOs := make([]*dto.BookDTO, 0, len(books))
+			for i := range books {
+				bookDTOs = append(bookDTOs, mapper.MapBookToDTO(&books[i]))
+			}
+
+			w := cmd.OutOrStdout()
+			if len(args) == 1 && args[0] != "-" {
+				file, err := os.Create(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				w = file
+			}
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(bookDTOs); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d books\n", len(bookDTOs))
+			return nil
+		},
+	}
+}
+
+func newCatalogImportCommand(cli *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "import FILE",
+		Short: "Add books from a JSON array in FILE (- for stdin); ids, ratings and covers are ignored",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r := cmd.InOrStdin()
+			if args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				r = file
+			}
+			bookDTOs, err := readCatalog(r)
+			if err != nil {
+				return err
+			}
+
+			connections, err := cli.connect(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer connections.Close()
+
+			bookService := service.NewBookService(connections.bookRepository())
+			for i, bookDTO := range bookDTOs {
+				book := mapper.MapDTOToBook(bookDTO)
+				if err := bookService.Create(cmd.Context(), book); err != nil {
+					return fmt.Errorf("book #%d %q: %w (%d books imported before it)", i+1, bookDTO.Title, err, i)
+				}
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Imported %d books\n", len(bookDTOs))
+			return nil
+		},
+	}
+}
+
+// readCatalog разбирает и проверяет весь файл до первой вставки, чтобы ошибка в конце файла
+// не оставила каталог импортированным наполовину
+func readCatalog(r io.Reader) ([]*dto.BookDTO, error) {
+	var bookDTOs []*dto.BookDTO
+	if err := json.NewDecoder(r).Decode(&bookDTOs); err != nil {
+		return nil, fmt.Errorf("parsing catalog: %w", err)
+	}
+
+	var errs []error
+	for i, bookDTO := range bookDTOs {
+		if bookDTO == nil {
+			errs = append(errs, fmt.Errorf("book #%d: must be an object", i+1))
+			continue
+		}
+		if err := validateDTO(bookDTO); err != nil {
+			errs = append(errs, fmt.Errorf("book #%d: %w", i+1, err))
+		}
+		bookDTO.ID, bookDTO.Version = 0, 0
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return bookDTOs, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCatalog(t *testing.T) {
+	bookDTOs, err := readCatalog(strings.NewReader(`[
+		{"id": 7, "title": "Dune", "author": "Frank Herbert", "category": "sci-fi", "version": 3},
+		{"title": "Solaris", "author": "Stanislaw Lem"}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, bookDTOs, 2)
+
+	// id и версию назначает база, поэтому экспорт можно загрузить в другой экземпляр
+	assert.Zero(t, bookDTOs[0].ID)
+	assert.Zero(t, bookDTOs[0].Version)
+	assert.Equal(t, "sci-fi", bookDTOs[0].Category)
+	assert.Equal(t, "Solaris", bookDTOs[1].Title)
+}
+
+func TestReadCatalog_ReportsEveryInvalidBook(t *testing.T) {
+	_, err := readCatalog(strings.NewReader(`[
+		{"title": "Dune", "author": "Frank Herbert"},
+		{"title": " ", "author": "Stanislaw Lem"},
+		null,
+		{"title": "Solaris"}
+	]`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "book #2: title must not be blank")
+	assert.Contains(t, err.Error(), "book #3: must be an object")
+	assert.Contains(t, err.Error(), "book #4: author is required")
+	assert.NotContains(t, err.Error(), "book #1")
+}
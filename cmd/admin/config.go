@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newConfigCommand(cli *cli) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the effective configuration",
+	}
+	command.AddCommand(
+		&cobra.Command{
+			Use:   "check",
+			Short: "Validate the configuration the server would start with and list every problem",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.config.Validate(); err != nil {
+					return fmt.Errorf("invalid configuration:\n%w", err)
+				}
+				source := cli.config.Path()
+				if source == "" {
+					source = "defaults and environment"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Configuration from %s is valid\n", source)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "print",
+			Short: "Print the effective configuration with secrets redacted",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return cli.config.Print(cmd.OutOrStdout())
+			},
+		},
+	)
+	return command
+}
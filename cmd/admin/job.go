@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app"
+	"github.com/Ablyamitov/simple-rest/internal/app/jobs"
+	"github.com/Ablyamitov/simple-rest/internal/app/recommendation"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	"github.com/Ablyamitov/simple-rest/internal/store/events"
+
+	"github.com/spf13/cobra"
+)
+
+// jobNames - задачи, которые можно запустить вне расписания. metrics.loans не входит:
+// метрики живут в процессе сервера, и отдельный запуск ничего бы не обновил
+var jobNames = []string{"recommendations.recompute", "stats.refresh", "soft_delete.purge",
+	"idempotency.purge", "outbox.relay", "outbox.purge"}
+
+func newJobCommand(cli *cli) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "job",
+		Short: "Run background jobs outside the schedule",
+	}
+	command.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List jobs that can be run",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				for _, name := range jobNames {
+					fmt.Fprintln(cmd.OutOrStdout(), name)
+				}
+			},
+		},
+		&cobra.Command{
+			Use:       "run NAME",
+			Short:     "Run a job once with the intervals and limits from the configuration",
+			Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+			ValidArgs: jobNames,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				connections, err := cli.connect(cmd.Context())
+				if err != nil {
+					return err
+				}
+				defer connections.Close()
+
+				job, err := newJob(args[0], cli.config, connections)
+				if err != nil {
+					return err
+				}
+				started := time.Now()
+				if err := job.Run(cmd.Context()); err != nil {
+					return fmt.Errorf("job %s: %w", job.Name(), err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Job %s finished in %s\n", job.Name(),
+					time.Since(started).Round(time.Millisecond))
+				return nil
+			},
+		},
+	)
+	return command
+}
+
+// newJob собирает задачу так же, как планировщик в cmd/app
+func newJob(name string, config *app.Configuration, connections *connections) (jobs.Job, error) {
+	switch name {
+	case "recommendations.recompute":
+		return recommendation.NewRecomputeJob(repository.NewRecommendationRepository(connections.Conn),
+			connections.RedisClient, config.Recommendations.Neighbors, 3*config.Recommendations.Interval), nil
+	case "stats.refresh":
+		return jobs.NewRefreshStatsJob(repository.NewStatsRepository(connections.Conn)), nil
+	case "soft_delete.purge":
+		return jobs.NewPurgeDeletedJob(connections.bookRepository(), connections.userRepository(),
+			config.Retention.Period), nil
+	case "idempotency.purge":
+		return jobs.NewPurgeIdempotencyKeysJob(repository.NewIdempotencyRepository(connections.Conn),
+			config.Idempotency.TTL), nil
+	case "outbox.relay":
+		return jobs.NewRelayOutboxJob(repository.NewOutboxRepository(connections.Conn),
+			newEventSink(config, connections), config.Outbox.BatchSize,
+			config.Outbox.Lease), nil
+	case "outbox.purge":
+		return jobs.NewPurgeOutboxJob(repository.NewOutboxRepository(connections.Conn), config.Outbox.Retention), nil
+	}
+	return nil, fmt.Errorf("unknown job %q, expected one of %s", name, strings.Join(jobNames, ", "))
+}
+
+func newEventSink(config *app.Configuration, connections *connections) events.Sink {
+	switch config.Outbox.Sink {
+	case "redis":
+		return events.NewRedisStreamSink(connections.RedisClient, config.Outbox.Redis.Stream, config.Outbox.Redis.MaxLen)
+	case "webhook":
+		return events.NewWebhookSink(config.Outbox.Webhook.URL, config.Outbox.Webhook.Secret,
+			config.Outbox.Webhook.Timeout)
+	}
+	return events.NewLogSink(slog.Default())
+}
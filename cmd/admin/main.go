@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Ablyamitov/simple-rest/internal/app"
+	"github.com/Ablyamitov/simple-rest/internal/app/metrics"
+	"github.com/Ablyamitov/simple-rest/internal/store/cache"
+	"github.com/Ablyamitov/simple-rest/internal/store/db"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	redisconn "github.com/Ablyamitov/simple-rest/internal/store/redis"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// cli хранит конфигурацию, загруженную тем же загрузчиком, что и у cmd/app
+type cli struct {
+	configPath string
+	config     *app.Configuration
+}
+
+func newRootCommand() *cobra.Command {
+	cli := &cli{}
+	root := &cobra.Command{
+		Use:           "simple-rest-admin",
+		Short:         "Operate the simple-rest service: users, catalog, migrations, cache, jobs and seed data",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		// Конфигурация собирается слоями, как у сервера: файл, затем SIMPLE_REST_* из окружения
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var configArgs []string
+			if cli.configPath != "" {
+				configArgs = []string{"--config", cli.configPath}
+			}
+			config, err := app.LoadConfig(configArgs)
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+			cli.config = config
+			return nil
+		},
+	}
+	root.PersistentFlags().StringVar(&cli.configPath, "config", "",
+		"path to the YAML configuration file (env CONFIG_PATH)")
+
+	root.AddCommand(
+		newUserCommand(cli),
+		newCatalogCommand(cli),
+		newMigrateCommand(cli),
+		newConfigCommand(cli),
+		newCacheCommand(cli),
+		newJobCommand(cli),
+		newSeedCommand(cli),
+	)
+	return root
+}
+
+// connections - подключения к Postgres и Redis, общие для подкоманд
+type connections struct {
+	Conn        *pgxpool.Pool
+	RedisClient *redis.Client
+	Caches      *repository.Caches
+}
+
+// connect открывает подключения по db.url и redis.addr; закрывать их нужно через Close
+func (cli *cli) connect(ctx context.Context) (*connections, error) {
+	if cli.config.DB.URL == "" {
+		return nil, errors.New("db.url is required")
+	}
+	if cli.config.Redis.Addr == "" {
+		return nil, errors.New("redis.addr is required")
+	}
+	conn := db.Connect(cli.config.DB.URL)
+	if err := conn.Ping(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connecting to postgres: %w", err)
+	}
+	redisClient := redisconn.Connect(cli.config.Redis.Addr, cli.config.Redis.Password, cli.config.Redis.DB)
+	// Без запасного кеша в памяти: изменения, сделанные CLI, должны сбросить кеш, который читает сервер
+	caches := repository.NewCaches(cache.NewRedisCache(redisClient), cache.Policy(cli.config.Cache.Books),
+		cache.Policy(cli.config.Cache.Users), metrics.NewNoopMetrics())
+	return &connections{Conn: conn, RedisClient: redisClient, Caches: caches}, nil
+}
+
+func (connections *connections) Close() {
+	connections.Conn.Close()
+	connections.RedisClient.Close()
+}
+
+func (connections *connections) userRepository() repository.UserRepository {
+	return repository.NewUserRepository(connections.Conn, connections.Caches)
+}
+
+func (connections *connections) bookRepository() repository.BookRepository {
+	return repository.NewBookRepository(connections.Conn, connections.Caches)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/Ablyamitov/simple-rest/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCommand(cli *cli) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply, revert and inspect the embedded migrations (migration.url)",
+	}
+	command.AddCommand(
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply all pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return cli.migrate(cmd, (*store.Migrator).Up)
+			},
+		},
+		&cobra.Command{
+			Use:   "down [N]",
+			Short: "Revert the last N migrations (1 by default)",
+			Args:  cobra.MaximumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				steps := 1
+				if len(args) == 1 {
+					var err error
+					if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+						return errors.New("N must be a positive number")
+					}
+				}
+				return cli.migrate(cmd, func(migrator *store.Migrator) error { return migrator.Down(steps) })
+			},
+		},
+		&cobra.Command{
+			Use:   "to N",
+			Short: "Move the schema up or down to version N",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				version, err := strconv.ParseUint(args[0], 10, 64)
+				if err != nil {
+					return errors.New("N must be a migration version")
+				}
+				return cli.migrate(cmd, func(migrator *store.Migrator) error { return migrator.To(version) })
+			},
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show the schema version and every migration",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return cli.migrate(cmd, func(*store.Migrator) error { return nil })
+			},
+		},
+		&cobra.Command{
+			Use:   "force N",
+			Short: "Set the version after fixing a dirty migration by hand",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				version, err := strconv.Atoi(args[0])
+				if err != nil {
+					return errors.New("N must be a migration version")
+				}
+				return cli.migrate(cmd, func(migrator *store.Migrator) error { return migrator.Force(version) })
+			},
+		},
+	)
+	return command
+}
+
+// migrate выполняет run над встроенными миграциями и печатает итоговое состояние схемы
+func (cli *cli) migrate(cmd *cobra.Command, run func(migrator *store.Migrator) error) error {
+	if cli.config.Migration.URL == "" {
+		return errors.New("migration.url is required")
+	}
+	migrator, err := store.NewMigrator(cli.config.Migration.URL)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if err := run(migrator); err != nil {
+		return err
+	}
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	return status.Print(cmd.OutOrStdout())
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/seed"
+	"github.com/Ablyamitov/simple-rest/internal/app/utils"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+
+	"github.com/spf13/cobra"
+)
+
+const asOfLayout = "2006-01-02"
+
+func newSeedCommand(cli *cli) *cobra.Command {
+	options := seed.Options{}
+	var asOf string
+	command := &cobra.Command{
+		Use:   "seed",
+		Short: "Fill Postgres with a reproducible dataset of books, users and loan histories",
+		Long: `Fill Postgres with a reproducible dataset of books, users and loan histories.
+
+The same flags always produce the same data, and running seed again updates the seeded rows in place,
+so their ids stay the same. Seeded rows that no longer fit the requested size are deleted,
+and the loan history of seeded users is recreated. Rows created outside seed are not touched.
+Every seeded user logs in with the password "` + seed.Password + `", e.g. admin1@seed.test.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.MaxActivePerUser = cli.config.Loans.MaxActive
+			options.LoanPeriod = cli.config.Loans.Period
+			options.AsOf = time.Now().UTC().Truncate(24 * time.Hour)
+			if asOf != "" {
+				var err error
+				if options.AsOf, err = time.Parse(asOfLayout, asOf); err != nil {
+					return fmt.Errorf("--as-of must be a date like %s", asOfLayout)
+				}
+			}
+			if err := options.Validate(); err != nil {
+				return err
+			}
+
+			// Пароль общий, поэтому медленный bcrypt считается один раз
+			passwordHash, err := utils.GenerateHashPassword(seed.Password)
+			if err != nil {
+				return err
+			}
+			dataset, err := seed.Generate(options, passwordHash)
+			if err != nil {
+				return err
+			}
+
+			connections, err := cli.connect(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer connections.Close()
+
+			result, err := repository.NewSeedRepository(connections.Conn, connections.Caches).
+				Apply(cmd.Context(), dataset)
+			if err != nil {
+				return err
+			}
+			// Статистика читается из материализованных представлений и без обновления не увидит новых займов
+			if err := repository.NewStatsRepository(connections.Conn).Refresh(cmd.Context()); err != nil {
+				return fmt.Errorf("refreshing stats: %w", err)
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Seeded %d users, %d books and %d loans as of %s\n",
+				result.Users, result.Books, result.Loans, options.AsOf.Format(asOfLayout))
+			if result.RemovedUsers > 0 || result.RemovedBooks > 0 {
+				fmt.Fprintf(out, "Removed %d users and %d books left from a larger seed\n",
+					result.RemovedUsers, result.RemovedBooks)
+			}
+			if result.SkippedLoans > 0 {
+				fmt.Fprintf(out, "Skipped %d active loans of books already lent to users outside the seed\n",
+					result.SkippedLoans)
+			}
+			return nil
+		},
+	}
+	flags := command.Flags()
+	flags.Uint64Var(&options.Seed, "seed", 1, "random seed; the same seed and sizes give the same dataset")
+	flags.IntVar(&options.Books, "books", 2000, "number of books")
+	flags.IntVar(&options.Authors, "authors", 400, "number of distinct authors")
+	flags.IntVar(&options.Users, "users", 500, "number of users, admins included")
+	flags.IntVar(&options.Admins, "admins", 1, "how many of the users are admins")
+	flags.IntVar(&options.Loans, "loans", 10000, "number of returned loans over the last year")
+	flags.IntVar(&options.ActiveLoans, "active-loans", 300, "number of books currently on loan")
+	flags.Float64Var(&options.OverdueRatio, "overdue-ratio", 0.2, "share of active loans that are overdue")
+	flags.StringVar(&asOf, "as-of", "", "date the loan history ends at, "+asOfLayout+" (today in UTC by default)")
+	return command
+}
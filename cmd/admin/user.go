@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/service"
+	"github.com/Ablyamitov/simple-rest/internal/app/validation"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/mapper"
+
+	"github.com/spf13/cobra"
+)
+
+var errEmptyPassword = errors.New("password must not be blank")
+
+func newUserCommand(cli *cli) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "user",
+		Short: "Manage users",
+	}
+	command.AddCommand(newCreateAdminCommand(cli), newResetPasswordCommand(cli))
+	return command
+}
+
+func newCreateAdminCommand(cli *cli) *cobra.Command {
+	var name, email string
+	command := &cobra.Command{
+		Use:   "create-admin",
+		Short: "Create a user with the admin role; the password is read from stdin",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			password, err := readPassword(cmd)
+			if err != nil {
+				return err
+			}
+			userDTO := &dto.UserDTO{Name: name, Email: email, Password: password, Role: "admin"}
+			if err := validateDTO(userDTO); err != nil {
+				return err
+			}
+
+			connections, err := cli.connect(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer connections.Close()
+
+			user := mapper.MapDTOToUser(userDTO)
+			if err := service.NewUserService(connections.userRepository()).Create(cmd.Context(), user); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Created admin %s with id %d\n", user.Email, user.ID)
+			return nil
+		},
+	}
+	command.Flags().StringVar(&name, "name", "", "display name of the admin")
+	command.Flags().StringVar(&email, "email", "", "email used to log in")
+	command.MarkFlagRequired("name")
+	command.MarkFlagRequired("email")
+	return command
+}
+
+func newResetPasswordCommand(cli *cli) *cobra.Command {
+	var email string
+	command := &cobra.Command{
+		Use:   "reset-password",
+		Short: "Set a new password for a user; the password is read from stdin",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			password, err := readPassword(cmd)
+			if err != nil {
+				return err
+			}
+
+			connections, err := cli.connect(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer connections.Close()
+
+			userService := service.NewUserService(connections.userRepository())
+			user, err := userService.GetByEmail(cmd.Context(), email)
+			if err != nil {
+				return err
+			}
+			_, err = userService.Patch(cmd.Context(), user.ID, user.Version, repository.UserPatch{Password: &password})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Password of %s has been reset\n", user.Email)
+			return nil
+		},
+	}
+	command.Flags().StringVar(&email, "email", "", "email of the user")
+	command.MarkFlagRequired("email")
+	return command
+}
+
+// readPassword читает первую строку stdin, чтобы пароль не попадал в историю shell и список процессов
+func readPassword(cmd *cobra.Command) (string, error) {
+	if file, ok := cmd.InOrStdin().(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprint(cmd.ErrOrStderr(), "Password: ")
+		}
+	}
+	line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(password) == "" {
+		return "", errEmptyPassword
+	}
+	return password, nil
+}
+
+// validateDTO применяет те же правила, что и HTTP-обработчики, и перечисляет все неверные поля
+func validateDTO(obj any) error {
+	err := validation.Validate(obj)
+	fieldErrors := validation.FieldErrors(err)
+	if fieldErrors == nil {
+		return err
+	}
+	errs := make([]error, 0, len(fieldErrors))
+	for _, fieldError := range fieldErrors {
+		errs = append(errs, fmt.Errorf("%s %s", fieldError.Field, fieldError.Message))
+	}
+	return errors.Join(errs...)
+}
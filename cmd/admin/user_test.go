@@ -0,0 +1,22 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPassword(t *testing.T) {
+	command := newResetPasswordCommand(&cli{})
+
+	command.SetIn(bytes.NewBufferString("s3cret pass\nignored\n"))
+	password, err := readPassword(command)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret pass", password)
+
+	command.SetIn(bytes.NewBufferString("  \n"))
+	_, err = readPassword(command)
+	assert.ErrorIs(t, err, errEmptyPassword)
+}
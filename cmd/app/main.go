@@ -3,62 +3,339 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Ablyamitov/simple-rest/internal/app"
 	"github.com/Ablyamitov/simple-rest/internal/app/handlers"
+	"github.com/Ablyamitov/simple-rest/internal/app/health"
+	"github.com/Ablyamitov/simple-rest/internal/app/jobs"
+	"github.com/Ablyamitov/simple-rest/internal/app/lifecycle"
+	"github.com/Ablyamitov/simple-rest/internal/app/metrics"
+	"github.com/Ablyamitov/simple-rest/internal/app/middlewares"
+	"github.com/Ablyamitov/simple-rest/internal/app/recommendation"
 	"github.com/Ablyamitov/simple-rest/internal/app/server"
+	"github.com/Ablyamitov/simple-rest/internal/app/service"
+	"github.com/Ablyamitov/simple-rest/internal/app/tracing"
 	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
 	"github.com/Ablyamitov/simple-rest/internal/store"
+	"github.com/Ablyamitov/simple-rest/internal/store/blob"
+	"github.com/Ablyamitov/simple-rest/internal/store/cache"
 	"github.com/Ablyamitov/simple-rest/internal/store/db"
 	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	"github.com/Ablyamitov/simple-rest/internal/store/events"
+	"github.com/Ablyamitov/simple-rest/internal/store/ratelimit"
 	redisconn "github.com/Ablyamitov/simple-rest/internal/store/redis"
-
-	"github.com/jackc/pgx/v5"
-	"github.com/redis/go-redis/v9"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	//conf
-	config := app.LoadConfig()
+	command, args := splitCommand(os.Args[1:])
+	config, err := app.LoadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	switch {
+	case slices.Equal(command, []string{"config", "print"}):
+		if err := config.Print(os.Stdout); err != nil {
+			log.Fatalf("Error printing config: %v", err)
+		}
+		return
+	case len(command) > 0 && command[0] == "migrate":
+		if err := runMigrate(config, command[1:]); err != nil {
+			log.Fatalf("Migrate: %v", err)
+		}
+		return
+	case len(command) > 0:
+		log.Fatalf("Unknown command %q, expected config print or migrate", strings.Join(command, " "))
+	}
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	//logger
+	logLevel := new(slog.LevelVar)
+	level, err := wrapper.ParseLogLevel(config.Log.Level)
+	if err != nil {
+		log.Fatalf("Error configuring logger: %v", err)
+	}
+	logLevel.Set(level)
+	logger, err := wrapper.NewLogger(os.Stdout, config.Log.Format, logLevel)
+	if err != nil {
+		log.Fatalf("Error configuring logger: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	//tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    config.Tracing.Exporter,
+		Endpoint:    config.Tracing.Endpoint,
+		Insecure:    config.Tracing.Insecure,
+		SampleRatio: config.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("Error configuring tracing: %v", err)
+	}
+
 	//migration
-	store.ApplyMigrations(config.Migration.Path, config.Migration.URL)
+	if err := store.ApplyMigrations(config.Migration.URL); err != nil {
+		log.Fatalf("Error applying migrations: %v", err)
+	}
 
 	//TODO: FIle
 	//postgres
 	conn := db.Connect(config.DB.URL)
-	defer func(conn *pgx.Conn, ctx context.Context) {
-		err := conn.Close(ctx)
-		if err != nil {
-			wrapper.LogError(fmt.Sprintf("Сlosing database connection: %v", err),
-				"main")
+
+	//metrics
+	appMetrics := metrics.NewPrometheusMetrics()
+	appMetrics.ObserveDBPool(func() metrics.DBPoolStats {
+		stat := conn.Stat()
+		return metrics.DBPoolStats{
+			TotalConns:    stat.TotalConns(),
+			IdleConns:     stat.IdleConns(),
+			AcquiredConns: stat.AcquiredConns(),
+			MaxConns:      stat.MaxConns(),
+			AcquireCount:  stat.AcquireCount(),
+			AcquireWait:   stat.AcquireDuration(),
 		}
-	}(conn, context.Background())
+	})
 
 	//redis
 	redisClient := redisconn.Connect(config.Redis.Addr, config.Redis.Password, config.Redis.DB)
-	defer func(redisClient *redis.Client) {
-		err := redisClient.Close()
-		if err != nil {
-			wrapper.LogError(fmt.Sprintf("Сlosing redis connection: %v", err),
-				"main")
+	redisClient.AddHook(tracing.NewRedisHook())
+
+	//cache
+	caches := repository.NewCaches(
+		cache.NewFallbackCache(cache.NewRedisCache(redisClient), cache.NewMemoryCache(config.Cache.MemoryEntries),
+			config.Cache.RetryAfter),
+		cache.Policy(config.Cache.Books), cache.Policy(config.Cache.Users), appMetrics)
+
+	loanRepository := repository.NewLoanRepository(conn, caches)
+	loanService := service.NewLoanService(loanRepository, service.LoanPolicy{
+		MaxActiveLoans: config.Loans.MaxActive,
+		LoanPeriod:     config.Loans.Period,
+	})
+	loanHandler := handlers.NewLoanHandler(loanService)
+
+	userRepository := repository.NewUserRepository(conn, caches)
+	userService := service.NewUserService(userRepository)
+	userHandler := handlers.NewUserHandler(userService, loanService)
+	authHandler := handlers.NewAuthHandler(config.App.Secret, userService, appMetrics)
+
+	bookRepository := repository.NewBookRepository(conn, caches)
+	bookHandler := handlers.NewBookHandler(service.NewBookService(bookRepository))
+
+	//blob storage
+	var blobStore blob.BlobStore
+	switch config.Storage.Driver {
+	case "s3":
+		s3 := config.Storage.S3
+		blobStore = blob.NewS3BlobStore(s3.Endpoint, s3.Region, s3.Bucket, s3.AccessKey, s3.SecretKey)
+	default:
+		blobStore = blob.NewLocalBlobStore(config.Storage.Local.Path)
+	}
+	coverHandler := handlers.NewCoverHandler(bookRepository, blobStore, config.Cover.MaxSize)
+
+	reviewRepository := repository.NewReviewRepository(conn, caches)
+	reviewHandler := handlers.NewReviewHandler(reviewRepository)
+
+	recommendationRepository := repository.NewRecommendationRepository(conn)
+	recommender := recommendation.NewRecommender(recommendationRepository, redisClient)
+	recommendationHandler := handlers.NewRecommendationHandler(recommender)
+
+	statsRepository := repository.NewStatsRepository(conn)
+	statsHandler := handlers.NewStatsHandler(statsRepository)
+
+	idempotencyRepository := repository.NewIdempotencyRepository(conn)
+
+	//domain events
+	outboxRepository := repository.NewOutboxRepository(conn)
+	var eventSink events.Sink
+	switch config.Outbox.Sink {
+	case "redis":
+		eventSink = events.NewRedisStreamSink(redisClient, config.Outbox.Redis.Stream, config.Outbox.Redis.MaxLen)
+	case "webhook":
+		eventSink = events.NewWebhookSink(config.Outbox.Webhook.URL, config.Outbox.Webhook.Secret,
+			config.Outbox.Webhook.Timeout)
+	default:
+		eventSink = events.NewLogSink(slog.Default())
+	}
+
+	readiness := health.NewReadiness(config.Health.Timeout,
+		health.PostgresCheck(conn),
+		health.RedisCheck(redisClient),
+		health.MigrationsCheck(conn))
+	healthHandler := handlers.NewHealthHandler(readiness)
+
+	//cors
+	cors := middlewares.NewCORS(config.CORS.AllowedOrigins)
+
+	//rate limiting
+	rateLimit := func(next http.Handler) http.Handler { return next }
+	var rateLimiter *middlewares.RateLimiter
+	if config.RateLimit.Enabled {
+		rateLimiter = middlewares.NewRateLimiter(
+			ratelimit.NewFallbackLimiter(ratelimit.NewRedisLimiter(redisClient), ratelimit.NewMemoryLimiter(),
+				config.RateLimit.RetryAfter),
+			rateLimits(config), config.App.Secret)
+		rateLimit = rateLimiter.Handler
+	}
+
+	//config reload
+	reloader := app.NewConfigReloader(config, func() (*app.Configuration, error) {
+		return app.LoadConfig(args)
+	})
+	reloader.OnReload(func(config *app.Configuration) {
+		if level, err := wrapper.ParseLogLevel(config.Log.Level); err == nil {
+			logLevel.Set(level)
+		}
+		loanService.SetPolicy(service.LoanPolicy{
+			MaxActiveLoans: config.Loans.MaxActive,
+			LoanPeriod:     config.Loans.Period,
+		})
+		if rateLimiter != nil {
+			rateLimiter.SetLimits(rateLimits(config))
 		}
-	}(redisClient)
+		cors.SetOrigins(config.CORS.AllowedOrigins)
+	})
+	configHandler := handlers.NewConfigHandler(reloader)
+	watchConfig(ctx, reloader)
 
-	userRepository := repository.NewUserRepository(conn, redisClient)
-	userHandler := handlers.NewUserHandler(userRepository)
-	authHandler := handlers.NewAuthHandler(config.App.Secret, userRepository)
+	//background jobs
+	scheduler := jobs.NewScheduler()
+	scheduler.Add(recommendation.NewRecomputeJob(recommendationRepository, redisClient,
+		config.Recommendations.Neighbors, 3*config.Recommendations.Interval), config.Recommendations.Interval)
+	scheduler.Add(jobs.NewRefreshStatsJob(statsRepository), config.Stats.RefreshInterval)
+	scheduler.Add(jobs.NewPurgeDeletedJob(bookRepository, userRepository, config.Retention.Period),
+		config.Retention.PurgeInterval)
+	scheduler.Add(jobs.NewLoanMetricsJob(loanRepository, appMetrics), config.Metrics.LoansInterval)
+	scheduler.Add(jobs.NewPurgeIdempotencyKeysJob(idempotencyRepository, config.Idempotency.TTL),
+		config.Idempotency.PurgeInterval)
+	scheduler.Add(jobs.NewRelayOutboxJob(outboxRepository, eventSink, config.Outbox.BatchSize,
+		config.Outbox.Lease), config.Outbox.RelayInterval)
+	scheduler.Add(jobs.NewPurgeOutboxJob(outboxRepository, config.Outbox.Retention), config.Outbox.PurgeInterval)
+	scheduler.Start(context.Background())
 
-	bookRepository := repository.NewBookRepository(conn, redisClient)
-	bookHandler := handlers.NewBookHandler(bookRepository)
+	//idempotency keys
+	// Обложка - самое большое тело, которое принимает API
+	idempotency := middlewares.Idempotency(idempotencyRepository, middlewares.IdempotencyOptions{
+		TTL:         config.Idempotency.TTL,
+		LockTimeout: config.Idempotency.LockTimeout,
+		MaxBody:     config.Cover.MaxSize,
+	}, config.App.Secret)
 
-	srv := server.NewServer(userHandler, bookHandler, authHandler, config.App.Secret)
+	srv := server.NewServer(config.Addr(), userHandler, bookHandler, authHandler, coverHandler, reviewHandler, recommendationHandler,
+		statsHandler, loanHandler, healthHandler, configHandler, config.App.Secret, middlewares.Deprecation{
+			DeprecatedAt: config.LegacyRoutes.DeprecatedAt,
+			SunsetAt:     config.LegacyRoutes.SunsetAt,
+		}, cors.Handler, rateLimit, idempotency, appMetrics)
+	srv.OnShutdown(readiness.Shutdown)
 
-	if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		wrapper.LogError(fmt.Sprintf("Could not listen on %s:%d: %v\n", config.Server.Host, config.Server.Port, err),
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received")
+	case err := <-serverErr:
+		wrapper.LogError(context.Background(), fmt.Sprintf("Could not listen on %s: %v", config.Addr(), err),
 			"main")
 	}
+	stop()
+
+	// Порядок важен: сначала перестаём принимать запросы и дожидаемся текущих,
+	// затем останавливаем фоновые задачи и только потом закрываем то, чем они пользуются
+	shutdown := lifecycle.New()
+	shutdown.Add("readiness", func(ctx context.Context) error {
+		readiness.Shutdown()
+		select {
+		case <-time.After(config.Shutdown.DrainDelay):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	shutdown.Add("http server", srv.Stop)
+	shutdown.AddFunc("background jobs", scheduler.Stop)
+	shutdown.Add("tracing", shutdownTracing)
+	shutdown.AddFunc("postgres", conn.Close)
+	shutdown.Add("redis", func(context.Context) error { return redisClient.Close() })
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Shutdown.DrainDelay+config.Shutdown.Timeout)
+	defer cancel()
+	if err := shutdown.Shutdown(shutdownCtx); err != nil {
+		wrapper.LogError(context.Background(), fmt.Sprintf("Graceful shutdown: %v", err), "main")
+		os.Exit(1)
+	}
+	slog.Info("Shutdown complete")
+}
 
+// rateLimits переводит настройки rate_limit в ограничения middleware
+func rateLimits(config *app.Configuration) middlewares.RateLimits {
+	routes := make(map[string]ratelimit.Limit, len(config.RateLimit.Routes))
+	for route, limit := range config.RateLimit.Routes {
+		routes[route] = ratelimit.Limit(limit)
+	}
+	return middlewares.RateLimits{
+		IP:                ratelimit.Limit(config.RateLimit.IP),
+		User:              ratelimit.Limit(config.RateLimit.User),
+		APIKey:            ratelimit.Limit(config.RateLimit.APIKey),
+		Routes:            routes,
+		TrustForwardedFor: config.RateLimit.TrustForwardedFor,
+	}
+}
+
+// splitCommand отделяет подкоманду (слова до первого флага) от флагов конфигурации
+func splitCommand(args []string) ([]string, []string) {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+// watchConfig перезагружает конфигурацию по SIGHUP и при изменении файла, пока не отменён ctx
+func watchConfig(ctx context.Context, reloader *app.ConfigReloader) {
+	if path := reloader.Current().Path(); path != "" {
+		if err := reloader.Watch(ctx, path); err != nil {
+			wrapper.LogError(ctx, fmt.Sprintf("Watching config %s: %v", path, err), "main")
+		}
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				slog.Info("SIGHUP received, reloading configuration")
+				if err := reloader.Reload(); err != nil {
+					wrapper.LogError(ctx, err.Error(), "main")
+				}
+			}
+		}
+	}()
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/Ablyamitov/simple-rest/internal/app"
+	"github.com/Ablyamitov/simple-rest/internal/store"
+)
+
+var errMigrateUsage = errors.New("usage: simple-rest migrate up | down [N] | to N | status | force N")
+
+// runMigrate выполняет migrate up, down [N], to N, status и force N над встроенными миграциями
+func runMigrate(config *app.Configuration, command []string) error {
+	run, err := migrateAction(command)
+	if err != nil {
+		return err
+	}
+	if config.Migration.URL == "" {
+		return errors.New("migration.url is required")
+	}
+
+	migrator, err := store.NewMigrator(config.Migration.URL)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if err := run(migrator); err != nil {
+		return err
+	}
+	return printMigrationStatus(migrator)
+}
+
+// migrateAction разбирает аргументы до подключения к базе, чтобы опечатка не ждала соединения
+func migrateAction(command []string) (func(migrator *store.Migrator) error, error) {
+	if len(command) == 0 || len(command) > 2 {
+		return nil, errMigrateUsage
+	}
+	var number string
+	if len(command) == 2 {
+		number = command[1]
+	}
+
+	switch {
+	case command[0] == "up" && number == "":
+		return (*store.Migrator).Up, nil
+	case command[0] == "status" && number == "":
+		return func(*store.Migrator) error { return nil }, nil
+	case command[0] == "down":
+		steps := 1
+		if number != "" {
+			var err error
+			if steps, err = strconv.Atoi(number); err != nil || steps < 1 {
+				return nil, errMigrateUsage
+			}
+		}
+		return func(migrator *store.Migrator) error { return migrator.Down(steps) }, nil
+	case command[0] == "to":
+		version, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return nil, errMigrateUsage
+		}
+		return func(migrator *store.Migrator) error { return migrator.To(version) }, nil
+	case command[0] == "force":
+		version, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, errMigrateUsage
+		}
+		return func(migrator *store.Migrator) error { return migrator.Force(version) }, nil
+	}
+	return nil, errMigrateUsage
+}
+
+func printMigrationStatus(migrator *store.Migrator) error {
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	return status.Print(os.Stdout)
+}
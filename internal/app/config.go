@@ -1,52 +1,472 @@
 package app
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+var httpMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodOptions}
+
+const (
+	defaultConfigPath = "./config/config.yaml"
+	// EnvPrefix - префикс переменных окружения: db.url задаётся через SIMPLE_REST_DB_URL,
+	// а SIMPLE_REST_DB_URL_FILE читает значение из файла (секреты Docker и Kubernetes)
+	EnvPrefix = "SIMPLE_REST_"
+)
+
+// Configuration собирается слоями: значения по умолчанию, YAML, переменные окружения, флаги.
+// Поля с тегом secret не выводятся в config print: secret:"url" скрывает пароль в URL, secret:"true" - всё значение
 type Configuration struct {
 	Server struct {
 		Host string `yaml:"host"`
 		Port int    `yaml:"port"`
 	} `yaml:"server"`
+	Shutdown struct {
+		DrainDelay time.Duration `yaml:"drain_delay"`
+		Timeout    time.Duration `yaml:"timeout"`
+	} `yaml:"shutdown"`
+	Log struct {
+		Format string `yaml:"format"`
+		Level  string `yaml:"level"`
+	} `yaml:"log"`
 	DB struct {
-		URL string `yaml:"url"`
+		URL string `yaml:"url" secret:"url"`
 	} `yaml:"db"`
 	Migration struct {
-		Path string `yaml:"path"`
-		URL  string `yaml:"url"`
+		URL string `yaml:"url" secret:"url"`
 	} `yaml:"migration"`
 	Redis struct {
 		Addr     string `yaml:"addr"`
-		Password string `yaml:"password"`
+		Password string `yaml:"password" secret:"true"`
 		DB       int    `yaml:"db"`
 	} `yaml:"redis"`
+	Cache struct {
+		MemoryEntries int           `yaml:"memory_entries"`
+		RetryAfter    time.Duration `yaml:"retry_after"`
+		Books         CachePolicy   `yaml:"books"`
+		Users         CachePolicy   `yaml:"users"`
+	} `yaml:"cache"`
+	RateLimit struct {
+		Enabled           bool                 `yaml:"enabled"`
+		TrustForwardedFor bool                 `yaml:"trust_forwarded_for"`
+		RetryAfter        time.Duration        `yaml:"retry_after"`
+		IP                RateLimit            `yaml:"ip"`
+		User              RateLimit            `yaml:"user"`
+		APIKey            RateLimit            `yaml:"api_key"`
+		Routes            map[string]RateLimit `yaml:"routes"`
+	} `yaml:"rate_limit"`
+	CORS struct {
+		AllowedOrigins []string `yaml:"allowed_origins"`
+	} `yaml:"cors"`
+	Idempotency struct {
+		TTL           time.Duration `yaml:"ttl"`
+		LockTimeout   time.Duration `yaml:"lock_timeout"`
+		PurgeInterval time.Duration `yaml:"purge_interval"`
+	} `yaml:"idempotency"`
+	Outbox struct {
+		Sink          string        `yaml:"sink"`
+		RelayInterval time.Duration `yaml:"relay_interval"`
+		BatchSize     int           `yaml:"batch_size"`
+		Lease         time.Duration `yaml:"lease"`
+		Retention     time.Duration `yaml:"retention"`
+		PurgeInterval time.Duration `yaml:"purge_interval"`
+		Redis         struct {
+			Stream string `yaml:"stream"`
+			MaxLen int64  `yaml:"max_len"`
+		} `yaml:"redis"`
+		Webhook struct {
+			URL     string        `yaml:"url" secret:"url"`
+			Secret  string        `yaml:"secret" secret:"true"`
+			Timeout time.Duration `yaml:"timeout"`
+		} `yaml:"webhook"`
+	} `yaml:"outbox"`
 	App struct {
-		Secret string `yaml:"secret"`
+		Secret string `yaml:"secret" secret:"true"`
 	} `yaml:"app"`
+	Storage struct {
+		Driver string `yaml:"driver"`
+		Local  struct {
+			Path string `yaml:"path"`
+		} `yaml:"local"`
+		S3 struct {
+			Endpoint  string `yaml:"endpoint"`
+			Region    string `yaml:"region"`
+			Bucket    string `yaml:"bucket"`
+			AccessKey string `yaml:"access_key"`
+			SecretKey string `yaml:"secret_key" secret:"true"`
+		} `yaml:"s3"`
+	} `yaml:"storage"`
+	Cover struct {
+		MaxSize int64 `yaml:"max_size"`
+	} `yaml:"cover"`
+	Recommendations struct {
+		Interval  time.Duration `yaml:"interval"`
+		Neighbors int           `yaml:"neighbors"`
+	} `yaml:"recommendations"`
+
+	Stats struct {
+		RefreshInterval time.Duration `yaml:"refresh_interval"`
+	} `yaml:"stats"`
+
+	Tracing struct {
+		Exporter    string  `yaml:"exporter"`
+		Endpoint    string  `yaml:"endpoint"`
+		Insecure    bool    `yaml:"insecure"`
+		SampleRatio float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+
+	Health struct {
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"health"`
+
+	Metrics struct {
+		LoansInterval time.Duration `yaml:"loans_interval"`
+	} `yaml:"metrics"`
+
+	Retention struct {
+		Period        time.Duration `yaml:"period"`
+		PurgeInterval time.Duration `yaml:"purge_interval"`
+	} `yaml:"retention"`
+
+	Loans struct {
+		MaxActive int           `yaml:"max_active"`
+		Period    time.Duration `yaml:"period"`
+	} `yaml:"loans"`
+
+	LegacyRoutes struct {
+		DeprecatedAt time.Time `yaml:"deprecated_at"`
+		SunsetAt     time.Time `yaml:"sunset_at"`
+	} `yaml:"legacy_routes"`
+
+	// path - файл, из которого прочитана конфигурация; пуст, если файла по умолчанию нет
+	path string
+}
+
+// CachePolicy - сроки кеширования одного вида сущностей: свежая запись, устаревшая, отдаваемая во время
+// обновления, и запомненное отсутствие записи
+type CachePolicy struct {
+	TTL         time.Duration `yaml:"ttl"`
+	StaleTTL    time.Duration `yaml:"stale_ttl"`
+	NegativeTTL time.Duration `yaml:"negative_ttl"`
+}
+
+// RateLimit - в среднем Rate запросов за Period и не больше Burst подряд; rate 0 снимает ограничение
+type RateLimit struct {
+	Rate   int           `yaml:"rate"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"`
+}
+
+// MarshalYAML выводит period так же, как он записывается в файле, а не в наносекундах
+func (limit RateLimit) MarshalYAML() (any, error) {
+	return struct {
+		Rate   int    `yaml:"rate"`
+		Period string `yaml:"period"`
+		Burst  int    `yaml:"burst"`
+	}{limit.Rate, limit.Period.String(), limit.Burst}, nil
 }
 
+// NewConfig возвращает значения по умолчанию, поверх которых накладываются YAML, окружение и флаги
 func NewConfig() *Configuration {
-	return &Configuration{}
+	config := &Configuration{}
+	config.Server.Port = 8080
+	config.Shutdown.DrainDelay = 5 * time.Second
+	config.Shutdown.Timeout = 20 * time.Second
+	config.Log.Format = "text"
+	config.Log.Level = "info"
+	config.Cache.MemoryEntries = 10000
+	config.Cache.RetryAfter = 5 * time.Second
+	config.Cache.Books = CachePolicy{TTL: 10 * time.Minute, StaleTTL: time.Minute, NegativeTTL: 30 * time.Second}
+	config.Cache.Users = CachePolicy{TTL: 5 * time.Minute, StaleTTL: 30 * time.Second, NegativeTTL: 30 * time.Second}
+	config.RateLimit.Enabled = true
+	config.RateLimit.RetryAfter = 5 * time.Second
+	config.RateLimit.IP = RateLimit{Rate: 1200, Period: time.Minute, Burst: 200}
+	config.RateLimit.User = RateLimit{Rate: 600, Period: time.Minute, Burst: 100}
+	config.RateLimit.APIKey = RateLimit{Rate: 1200, Period: time.Minute, Burst: 200}
+	// Вход и регистрация считают bcrypt, поэтому ограничены гораздо строже остальных маршрутов
+	login := RateLimit{Rate: 10, Period: time.Minute, Burst: 5}
+	register := RateLimit{Rate: 10, Period: time.Hour, Burst: 3}
+	config.RateLimit.Routes = map[string]RateLimit{
+		"POST /api/v1/auth/login":    login,
+		"POST /api/v1/auth/register": register,
+	}
+	config.Idempotency.TTL = 24 * time.Hour
+	config.Idempotency.LockTimeout = time.Minute
+	config.Idempotency.PurgeInterval = time.Hour
+	config.Outbox.Sink = "log"
+	config.Outbox.RelayInterval = 5 * time.Second
+	config.Outbox.BatchSize = 100
+	config.Outbox.Lease = 10 * time.Minute
+	config.Outbox.Retention = 7 * 24 * time.Hour
+	config.Outbox.PurgeInterval = time.Hour
+	config.Outbox.Redis.Stream = "simple-rest:events"
+	config.Outbox.Redis.MaxLen = 100000
+	config.Outbox.Webhook.Timeout = 5 * time.Second
+	config.Storage.Driver = "local"
+	config.Storage.Local.Path = "./data"
+	config.Storage.S3.Region = "us-east-1"
+	config.Cover.MaxSize = 5 << 20
+	config.Recommendations.Interval = time.Hour
+	config.Recommendations.Neighbors = 20
+	config.Stats.RefreshInterval = 15 * time.Minute
+	config.Tracing.Exporter = "none"
+	config.Tracing.SampleRatio = 1
+	config.Health.Timeout = 2 * time.Second
+	config.Metrics.LoansInterval = time.Minute
+	config.Retention.Period = 30 * 24 * time.Hour
+	config.Retention.PurgeInterval = 24 * time.Hour
+	config.Loans.MaxActive = 5
+	config.Loans.Period = 14 * 24 * time.Hour
+	return config
 }
-func LoadConfig() *Configuration {
+
+// LoadConfig собирает конфигурацию из args (os.Args[1:] без подкоманды).
+// Путь к YAML берётся из --config, затем из CONFIG_PATH; файла по умолчанию может и не быть.
+// Ошибки всех переменных окружения и флагов возвращаются вместе
+func LoadConfig(args []string) (*Configuration, error) {
 	config := NewConfig()
+	fields := configFields(config)
 
-	configPath := "./config/config.yaml"
-	if envPath, ok := os.LookupEnv("CONFIG_PATH"); ok {
-		configPath = envPath
+	flags := flag.NewFlagSet("simple-rest", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to the YAML configuration file (env CONFIG_PATH)")
+	var overrides []func() error
+	for _, field := range fields {
+		flags.Func(field.path, "overrides "+field.path+" (env "+field.env()+")", func(raw string) error {
+			overrides = append(overrides, func() error { return field.set(raw) })
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
 	}
 
-	configFile, err := os.ReadFile(configPath)
-	if err != nil {
-		log.Fatalf("Error reading config.yaml: %v", err)
+	if err := config.readFile(*configPath); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, field := range fields {
+		if err := field.setFromEnv(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, override := range overrides {
+		if err := override(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (config *Configuration) readFile(path string) error {
+	explicit := path != ""
+	if !explicit {
+		path, explicit = os.LookupEnv("CONFIG_PATH")
+	}
+	if !explicit {
+		path = defaultConfigPath
+	}
+
+	configFile, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
 	}
-	err = yaml.Unmarshal(configFile, &config)
 	if err != nil {
-		log.Fatalf("Error parsing config.yaml: %v", err)
+		return fmt.Errorf("reading config %s: %w", path, err)
 	}
-	return config
+	if err := yaml.Unmarshal(configFile, config); err != nil {
+		return fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	config.path = path
+	return nil
+}
+
+// Path возвращает файл, из которого прочитана конфигурация
+func (config *Configuration) Path() string {
+	return config.path
+}
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные проблемы, а не только первую
+func (config *Configuration) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	positive := func(value time.Duration, path string) {
+		check(value > 0, "%s must be positive, got %s", path, value)
+	}
+
+	check(config.Server.Port > 0 && config.Server.Port <= 65535,
+		"server.port must be between 1 and 65535, got %d", config.Server.Port)
+	positive(config.Shutdown.Timeout, "shutdown.timeout")
+	check(config.Shutdown.DrainDelay >= 0, "shutdown.drain_delay must not be negative")
+
+	check(slices.Contains([]string{"text", "json"}, strings.ToLower(config.Log.Format)),
+		"log.format must be text or json, got %q", config.Log.Format)
+	check(slices.Contains([]string{"debug", "info", "warn", "error"}, strings.ToLower(config.Log.Level)),
+		"log.level must be debug, info, warn or error, got %q", config.Log.Level)
+
+	checkURL := func(raw string, path string) {
+		if raw == "" {
+			check(false, "%s is required (env %s)", path, envName(path))
+			return
+		}
+		parsed, err := url.Parse(raw)
+		check(err == nil && parsed.Scheme != "" && parsed.Host != "", "%s is not a valid URL", path)
+	}
+	checkURL(config.DB.URL, "db.url")
+	checkURL(config.Migration.URL, "migration.url")
+	check(config.Redis.Addr != "", "redis.addr is required (env %s)", envName("redis.addr"))
+	check(config.Cache.MemoryEntries > 0, "cache.memory_entries must be positive")
+	positive(config.Cache.RetryAfter, "cache.retry_after")
+	cachePolicy := func(policy CachePolicy, path string) {
+		positive(policy.TTL, path+".ttl")
+		check(policy.StaleTTL >= 0, "%s.stale_ttl must not be negative", path)
+		check(policy.NegativeTTL >= 0, "%s.negative_ttl must not be negative", path)
+	}
+	cachePolicy(config.Cache.Books, "cache.books")
+	cachePolicy(config.Cache.Users, "cache.users")
+
+	positive(config.RateLimit.RetryAfter, "rate_limit.retry_after")
+	rateLimit := func(limit RateLimit, path string) {
+		check(limit.Rate >= 0, "%s.rate must not be negative", path)
+		if limit.Rate > 0 {
+			positive(limit.Period, path+".period")
+			check(limit.Burst > 0, "%s.burst must be positive", path)
+			check(limit.Period/time.Duration(limit.Rate) >= time.Millisecond,
+				"%s allows more than one request per millisecond", path)
+		}
+	}
+	rateLimit(config.RateLimit.IP, "rate_limit.ip")
+	rateLimit(config.RateLimit.User, "rate_limit.user")
+	rateLimit(config.RateLimit.APIKey, "rate_limit.api_key")
+	routes := slices.Sorted(maps.Keys(config.RateLimit.Routes))
+	// Устаревший маршрут считается вместе с версионным, поэтому задать им разные ограничения нельзя
+	versioned := make(map[string]string, len(routes))
+	for _, route := range routes {
+		method, pattern, _ := strings.Cut(route, " ")
+		check(slices.Contains(httpMethods, method) && strings.HasPrefix(pattern, "/"),
+			"rate_limit.routes key must look like \"POST /api/v1/auth/login\", got %q", route)
+		rateLimit(config.RateLimit.Routes[route], fmt.Sprintf("rate_limit.routes[%q]", route))
+		canonical := method + " /api/v1" + strings.TrimPrefix(pattern, "/api/v1")
+		if alias, ok := versioned[canonical]; ok {
+			check(false, "rate_limit.routes %q and %q are the same route, keep only the /api/v1 one", alias, route)
+		}
+		versioned[canonical] = route
+	}
+
+	for _, origin := range config.CORS.AllowedOrigins {
+		parsed, err := url.Parse(origin)
+		check(origin == "*" || err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") &&
+			parsed.Host != "" && parsed.Path == "" && parsed.RawQuery == "",
+			"cors.allowed_origins must contain * or origins like https://library.example.com, got %q", origin)
+	}
+
+	positive(config.Idempotency.TTL, "idempotency.ttl")
+	positive(config.Idempotency.LockTimeout, "idempotency.lock_timeout")
+	positive(config.Idempotency.PurgeInterval, "idempotency.purge_interval")
+	check(config.Idempotency.LockTimeout <= config.Idempotency.TTL,
+		"idempotency.lock_timeout must not exceed idempotency.ttl")
+
+	switch config.Outbox.Sink {
+	case "redis":
+		check(config.Outbox.Redis.Stream != "", "outbox.redis.stream is required for the redis sink")
+		check(config.Outbox.Redis.MaxLen >= 0, "outbox.redis.max_len must not be negative")
+	case "webhook":
+		checkURL(config.Outbox.Webhook.URL, "outbox.webhook.url")
+		positive(config.Outbox.Webhook.Timeout, "outbox.webhook.timeout")
+		// Иначе пачку, которая ещё отправляется, успеет занять другой экземпляр
+		check(config.Outbox.Lease > time.Duration(config.Outbox.BatchSize)*config.Outbox.Webhook.Timeout,
+			"outbox.lease must exceed outbox.batch_size * outbox.webhook.timeout")
+	case "log":
+	default:
+		check(false, "outbox.sink must be redis, webhook or log, got %q", config.Outbox.Sink)
+	}
+	positive(config.Outbox.RelayInterval, "outbox.relay_interval")
+	check(config.Outbox.BatchSize > 0, "outbox.batch_size must be positive")
+	positive(config.Outbox.Lease, "outbox.lease")
+	positive(config.Outbox.Retention, "outbox.retention")
+	positive(config.Outbox.PurgeInterval, "outbox.purge_interval")
+
+	check(config.App.Secret != "", "app.secret is required (env %s or %s_FILE)",
+		envName("app.secret"), envName("app.secret"))
+	check(config.App.Secret == "" || len(config.App.Secret) >= 32,
+		"app.secret must be at least 32 characters long")
+
+	switch config.Storage.Driver {
+	case "local":
+		check(config.Storage.Local.Path != "", "storage.local.path is required for the local driver")
+	case "s3":
+		s3 := config.Storage.S3
+		check(s3.Endpoint != "", "storage.s3.endpoint is required for the s3 driver")
+		check(s3.Bucket != "", "storage.s3.bucket is required for the s3 driver")
+		check(s3.AccessKey != "", "storage.s3.access_key is required for the s3 driver")
+		check(s3.SecretKey != "", "storage.s3.secret_key is required for the s3 driver")
+	default:
+		check(false, "storage.driver must be local or s3, got %q", config.Storage.Driver)
+	}
+	check(config.Cover.MaxSize > 0, "cover.max_size must be positive")
+
+	switch config.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		check(config.Tracing.Endpoint != "", "tracing.endpoint is required for the otlp exporter")
+	default:
+		check(false, "tracing.exporter must be otlp, stdout or none, got %q", config.Tracing.Exporter)
+	}
+	check(config.Tracing.SampleRatio >= 0 && config.Tracing.SampleRatio <= 1,
+		"tracing.sample_ratio must be between 0 and 1, got %g", config.Tracing.SampleRatio)
+
+	positive(config.Recommendations.Interval, "recommendations.interval")
+	check(config.Recommendations.Neighbors > 0, "recommendations.neighbors must be positive")
+	positive(config.Stats.RefreshInterval, "stats.refresh_interval")
+	positive(config.Health.Timeout, "health.timeout")
+	positive(config.Metrics.LoansInterval, "metrics.loans_interval")
+	positive(config.Retention.Period, "retention.period")
+	positive(config.Retention.PurgeInterval, "retention.purge_interval")
+	check(config.Loans.MaxActive > 0, "loans.max_active must be positive")
+	positive(config.Loans.Period, "loans.period")
+
+	legacy := config.LegacyRoutes
+	check(legacy.DeprecatedAt.IsZero() || legacy.SunsetAt.IsZero() || legacy.SunsetAt.After(legacy.DeprecatedAt),
+		"legacy_routes.sunset_at must be after legacy_routes.deprecated_at")
+
+	return errors.Join(errs...)
+}
+
+// Print выводит итоговую конфигурацию в YAML, скрывая секреты
+func (config *Configuration) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(configNode(config)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// Addr - адрес, который слушает HTTP-сервер
+func (config *Configuration) Addr() string {
+	return net.JoinHostPort(config.Server.Host, strconv.Itoa(config.Server.Port))
 }
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	redacted   = "******"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// configField - лист конфигурации с путём из yaml-тегов, например db.url
+type configField struct {
+	path   string
+	secret string
+	value  reflect.Value
+}
+
+// configFields обходит Configuration и возвращает все листья в порядке объявления
+func configFields(config *Configuration) []configField {
+	var fields []configField
+	var walk func(value reflect.Value, prefix string)
+	walk = func(value reflect.Value, prefix string) {
+		for i := 0; i < value.NumField(); i++ {
+			structField := value.Type().Field(i)
+			if !structField.IsExported() {
+				continue
+			}
+			path := prefix + strings.Split(structField.Tag.Get("yaml"), ",")[0]
+			fieldValue := value.Field(i)
+			if fieldValue.Kind() == reflect.Struct && fieldValue.Type() != timeType {
+				walk(fieldValue, path+".")
+				continue
+			}
+			fields = append(fields, configField{path: path, secret: structField.Tag.Get("secret"), value: fieldValue})
+		}
+	}
+	walk(reflect.ValueOf(config).Elem(), "")
+	return fields
+}
+
+func envName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+func (field configField) env() string {
+	return envName(field.path)
+}
+
+// setFromEnv читает SIMPLE_REST_<PATH> или содержимое файла из SIMPLE_REST_<PATH>_FILE
+func (field configField) setFromEnv() error {
+	raw, ok := os.LookupEnv(field.env())
+	file, fromFile := os.LookupEnv(field.env() + "_FILE")
+	switch {
+	case ok && fromFile:
+		return fmt.Errorf("%s and %s_FILE are both set", field.env(), field.env())
+	case fromFile:
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("%s_FILE: %w", field.env(), err)
+		}
+		raw = strings.TrimSpace(string(content))
+	case !ok:
+		return nil
+	}
+	if err := field.set(raw); err != nil {
+		return fmt.Errorf("%s: %w", field.env(), err)
+	}
+	return nil
+}
+
+func (field configField) set(raw string) error {
+	if err := setValue(field.value, raw); err != nil {
+		return fmt.Errorf("%s: %w", field.path, err)
+	}
+	return nil
+}
+
+func setValue(value reflect.Value, raw string) error {
+	switch value.Type() {
+	case durationType:
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+		return nil
+	case timeType:
+		date, err := time.Parse(dateLayout, raw)
+		if err != nil {
+			return fmt.Errorf("expected date in format %s", dateLayout)
+		}
+		value.Set(reflect.ValueOf(date))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		number, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected integer, got %q", raw)
+		}
+		value.SetInt(number)
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("expected number, got %q", raw)
+		}
+		value.SetFloat(number)
+	case reflect.Bool:
+		flag, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected boolean, got %q", raw)
+		}
+		value.SetBool(flag)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return errors.New("unsupported type " + value.Type().String())
+		}
+		// Список задаётся через запятую, пустая строка его очищает
+		items := reflect.MakeSlice(value.Type(), 0, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = reflect.Append(items, reflect.ValueOf(item))
+			}
+		}
+		value.Set(items)
+	default:
+		return errors.New("unsupported type " + value.Type().String())
+	}
+	return nil
+}
+
+// configNode строит YAML-дерево для config print: длительности и даты в том же виде, что и в файле,
+// секреты скрыты
+func configNode(config *Configuration) *yaml.Node {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := map[string]*yaml.Node{"": root}
+	for _, field := range configFields(config) {
+		parent := root
+		keys := strings.Split(field.path, ".")
+		for i, key := range keys[:len(keys)-1] {
+			prefix := strings.Join(keys[:i+1], ".")
+			section, ok := sections[prefix]
+			if !ok {
+				section = &yaml.Node{Kind: yaml.MappingNode}
+				sections[prefix] = section
+				parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, section)
+			}
+			parent = section
+		}
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: keys[len(keys)-1]}, field.node())
+	}
+	return root
+}
+
+func (field configField) node() *yaml.Node {
+	var value any
+	switch field.value.Type() {
+	case durationType:
+		value = time.Duration(field.value.Int()).String()
+	case timeType:
+		date := field.value.Interface().(time.Time)
+		value = ""
+		if !date.IsZero() {
+			value = date.Format(dateLayout)
+		}
+	default:
+		value = field.value.Interface()
+	}
+
+	if raw, ok := value.(string); ok && raw != "" {
+		switch field.secret {
+		case "true":
+			value = redacted
+		case "url":
+			value = redactURL(raw)
+		}
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(value)}
+	}
+	return node
+}
+
+// redactURL оставляет в URL всё, кроме пароля
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	return parsed.Redacted()
+}
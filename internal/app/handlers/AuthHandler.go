@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
@@ -9,16 +8,18 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/Ablyamitov/simple-rest/internal/app/metrics"
+	"github.com/Ablyamitov/simple-rest/internal/app/service"
+	"github.com/Ablyamitov/simple-rest/internal/app/tracing"
 	"github.com/Ablyamitov/simple-rest/internal/app/utils"
 	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/domain"
 	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
-	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
 var (
-	errNotUniqueEmail        = errors.New("user with the same email already exists")
 	errNotFoundWithSameEmail = errors.New("user with the same email is not exists")
 	errInvalidPassword       = errors.New("invalid password")
 	errGenerateToken         = errors.New("could not generate token")
@@ -34,56 +35,36 @@ type AuthHandler interface {
 }
 
 type AuthHandlerImpl struct {
-	UserRepository repository.UserRepository
-	Secret         string
+	UserService service.UserService
+	Metrics     metrics.Metrics
+	Secret      string
 }
 
 type LoginRequest struct {
 }
 
-func NewAuthHandler(secret string, userRepository repository.UserRepository) AuthHandler {
-	return &AuthHandlerImpl{UserRepository: userRepository, Secret: secret}
+func NewAuthHandler(secret string, userService service.UserService, metrics metrics.Metrics) AuthHandler {
+	return &AuthHandlerImpl{UserService: userService, Metrics: metrics, Secret: secret}
 }
 
 func (authHandler *AuthHandlerImpl) Register(w http.ResponseWriter, r *http.Request) {
 
 	var userDTO dto.UserDTO
 	if err := json.NewDecoder(r.Body).Decode(&userDTO); err != nil {
-		wrapper.LogError(err.Error(), "AuthHandlerImpl.Register")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "AuthHandlerImpl.Register")
 		return
 	}
 
-	var existingUser entity.User
-	existingUser, err := authHandler.UserRepository.GetByEmail(context.Background(), mapper.MapDTOToUser(&userDTO).Email)
+	user := mapper.MapDTOToUser(&userDTO)
+	err := authHandler.UserService.Create(r.Context(), user)
 	if err != nil {
-		wrapper.LogError(err.Error(), "AuthHandlerImpl.Register")
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if existingUser.ID != 0 {
-		wrapper.LogError(errNotUniqueEmail.Error(), "AuthHandlerImpl.Register")
-		http.Error(w, errNotUniqueEmail.Error(), http.StatusBadRequest)
-		return
-	}
-
-	userDTO.Password, err = utils.GenerateHashPassword(userDTO.Password)
-	if err != nil {
-		wrapper.LogError(err.Error(), "AuthHandlerImpl.Register")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	err = authHandler.UserRepository.Create(context.Background(), mapper.MapDTOToUser(&userDTO))
-	if err != nil {
-		wrapper.LogError(err.Error(), "AuthHandlerImpl.Register")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.WriteError(w, r, errorStatus(err), err, "AuthHandlerImpl.Register")
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(userDTO); err != nil {
-		wrapper.LogError(err.Error(), "AuthHandlerImpl.Register")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(mapper.MapUserToDTO(user)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "AuthHandlerImpl.Register")
 	}
 }
 
@@ -92,34 +73,34 @@ func (authHandler *AuthHandlerImpl) Login(w http.ResponseWriter, r *http.Request
 	var userDTO dto.UserDTO
 
 	if err := json.NewDecoder(r.Body).Decode(&userDTO); err != nil {
-		wrapper.LogError(err.Error(), "AuthHandlerImpl.Login")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "AuthHandlerImpl.Login")
 		return
 	}
-	var existingUser entity.User
-	existingUser, err := authHandler.UserRepository.GetByEmail(context.Background(), mapper.MapDTOToUser(&userDTO).Email)
+	existingUser, err := authHandler.UserService.GetByEmail(r.Context(), mapper.MapDTOToUser(&userDTO).Email)
 	if err != nil {
-		wrapper.LogError(err.Error(), "AuthHandlerImpl.Login")
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if existingUser.ID == 0 {
-		wrapper.LogError(errNotFoundWithSameEmail.Error(), "AuthHandlerImpl.Login")
-		http.Error(w, errNotFoundWithSameEmail.Error(), http.StatusBadRequest)
+		if errors.Is(err, domain.ErrNotFound) {
+			authHandler.Metrics.LoginFailed("unknown_email")
+			wrapper.WriteError(w, r, http.StatusBadRequest, errNotFoundWithSameEmail, "AuthHandlerImpl.Login")
+		} else {
+			wrapper.WriteError(w, r, http.StatusInternalServerError, err, "AuthHandlerImpl.Login")
+		}
 		return
 	}
 
+	_, span := tracing.Tracer().Start(r.Context(), "bcrypt.compare")
 	errHash := utils.CompareHashPassword(userDTO.Password, existingUser.Password)
+	span.End()
 	if !errHash {
-		wrapper.LogError(errInvalidPassword.Error(), "AuthHandlerImpl.Login")
-		http.Error(w, errInvalidPassword.Error(), http.StatusBadRequest)
+		authHandler.Metrics.LoginFailed("invalid_password")
+		wrapper.WriteError(w, r, http.StatusBadRequest, errInvalidPassword, "AuthHandlerImpl.Login")
 		return
 	}
 
 	expirationTime := time.Now().Add(5 * time.Minute)
 
 	claims := &entity.Claims{
-		Role: existingUser.Role,
+		UserID: existingUser.ID,
+		Role:   existingUser.Role,
 		StandardClaims: jwt.StandardClaims{
 			Subject:   existingUser.Email,
 			ExpiresAt: expirationTime.Unix(),
@@ -131,8 +112,7 @@ func (authHandler *AuthHandlerImpl) Login(w http.ResponseWriter, r *http.Request
 	tokenString, err := token.SignedString([]byte(authHandler.Secret))
 
 	if err != nil {
-		wrapper.LogError(errGenerateToken.Error(), "AuthHandlerImpl.Login")
-		http.Error(w, errGenerateToken.Error(), http.StatusInternalServerError)
+		wrapper.WriteError(w, r, http.StatusInternalServerError, errGenerateToken, "AuthHandlerImpl.Login")
 		return
 	}
 
@@ -142,8 +122,7 @@ func (authHandler *AuthHandlerImpl) Login(w http.ResponseWriter, r *http.Request
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(existingUser); err != nil {
-		wrapper.LogError(err.Error(), "AuthHandlerImpl.Login")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.LogError(r.Context(), err.Error(), "AuthHandlerImpl.Login")
 	}
 }
 
@@ -151,8 +130,7 @@ func (authHandler *AuthHandlerImpl) CheckAuth(w http.ResponseWriter, r *http.Req
 
 	bearerToken := r.Header.Get("Authorization")
 	if bearerToken == "" {
-		wrapper.LogError(errEmptyToken.Error(), "AuthHandlerImpl.CheckAuth")
-		http.Error(w, errEmptyToken.Error(), http.StatusUnauthorized)
+		wrapper.WriteError(w, r, http.StatusUnauthorized, errEmptyToken, "AuthHandlerImpl.CheckAuth")
 		return
 	}
 	token := bearerToken[7:]
@@ -160,14 +138,12 @@ func (authHandler *AuthHandlerImpl) CheckAuth(w http.ResponseWriter, r *http.Req
 	claims, err := utils.ParseToken(token, authHandler.Secret)
 
 	if err != nil {
-		wrapper.LogError(errNotValidToken.Error(), "AuthHandlerImpl.CheckAuth")
-		http.Error(w, errNotValidToken.Error(), http.StatusUnauthorized)
+		wrapper.WriteError(w, r, http.StatusUnauthorized, errNotValidToken, "AuthHandlerImpl.CheckAuth")
 		return
 	}
 
 	if claims.Role != "user" && claims.Role != "admin" {
-		wrapper.LogError(errAccessDenied.Error(), "AuthHandlerImpl.CheckAuth")
-		http.Error(w, errAccessDenied.Error(), http.StatusUnauthorized)
+		wrapper.WriteError(w, r, http.StatusUnauthorized, errAccessDenied, "AuthHandlerImpl.CheckAuth")
 		return
 	}
 
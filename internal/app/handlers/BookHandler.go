@@ -1,24 +1,24 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/Ablyamitov/simple-rest/internal/app/service"
+	"github.com/Ablyamitov/simple-rest/internal/app/utils"
 	"github.com/Ablyamitov/simple-rest/internal/app/validation"
 	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/domain"
 	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
 	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
 	"github.com/Ablyamitov/simple-rest/internal/store/web/mapper"
-	"github.com/jackc/pgx/v5"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type BookHandlerImpl struct {
-	BookRepository repository.BookRepository
+	BookService service.BookService
 }
 
 type BookHandler interface {
@@ -26,23 +26,19 @@ type BookHandler interface {
 	GetById(w http.ResponseWriter, r *http.Request)
 	Create(w http.ResponseWriter, r *http.Request)
 	Update(w http.ResponseWriter, r *http.Request)
+	Patch(w http.ResponseWriter, r *http.Request)
 	Delete(w http.ResponseWriter, r *http.Request)
+	Restore(w http.ResponseWriter, r *http.Request)
 }
 
-func NewBookHandler(bookRepository repository.BookRepository) BookHandler {
-	return &BookHandlerImpl{BookRepository: bookRepository}
+func NewBookHandler(bookService service.BookService) BookHandler {
+	return &BookHandlerImpl{BookService: bookService}
 }
 
 func (bookHandler *BookHandlerImpl) GetAll(w http.ResponseWriter, r *http.Request) {
-	books, err := bookHandler.BookRepository.GetALL(context.Background())
+	books, err := bookHandler.BookService.GetAll(r.Context())
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			wrapper.LogError(err.Error(), "BookHandlerImpl.GetAll")
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			wrapper.LogError(err.Error(), "BookHandlerImpl.GetAll")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		wrapper.WriteError(w, r, errorStatus(err), err, "BookHandlerImpl.GetAll")
 		return
 	}
 
@@ -53,8 +49,7 @@ func (bookHandler *BookHandlerImpl) GetAll(w http.ResponseWriter, r *http.Reques
 		booksDTO = append(booksDTO, mapper.MapBookToDTO(&book))
 	}
 	if err := json.NewEncoder(w).Encode(booksDTO); err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.GetAll")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.LogError(r.Context(), err.Error(), "BookHandlerImpl.GetAll")
 		return
 	}
 }
@@ -62,28 +57,27 @@ func (bookHandler *BookHandlerImpl) GetAll(w http.ResponseWriter, r *http.Reques
 func (bookHandler *BookHandlerImpl) GetById(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.GetByID")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "BookHandlerImpl.GetByID")
 		return
 	}
-	book, err := bookHandler.BookRepository.GetByID(context.Background(), id)
+	book, err := bookHandler.BookService.GetByID(r.Context(), id)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			wrapper.LogError(err.Error(), "BookHandlerImpl.GetByID")
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			wrapper.LogError(err.Error(), "BookHandlerImpl.GetByID")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		wrapper.WriteError(w, r, errorStatus(err), err, "BookHandlerImpl.GetByID")
 
 		return
 	}
 
+	etag := utils.ETag(book.Version)
+	w.Header().Set("ETag", etag)
+	if utils.NotModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 
 	if err := json.NewEncoder(w).Encode(mapper.MapBookToDTO(book)); err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.GetByID")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.LogError(r.Context(), err.Error(), "BookHandlerImpl.GetByID")
 		return
 	}
 
@@ -92,79 +86,165 @@ func (bookHandler *BookHandlerImpl) GetById(w http.ResponseWriter, r *http.Reque
 func (bookHandler *BookHandlerImpl) Create(w http.ResponseWriter, r *http.Request) {
 	var bookDTO *dto.BookDTO
 	if err := json.NewDecoder(r.Body).Decode(&bookDTO); err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.Create")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "BookHandlerImpl.Create")
 		return
 	}
 
 	if err := validation.Validate(bookDTO); err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.Create")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "BookHandlerImpl.Create")
 		return
 	}
 
-	err := bookHandler.BookRepository.Create(context.Background(), mapper.MapDTOToBook(bookDTO))
+	book := mapper.MapDTOToBook(bookDTO)
+	err := bookHandler.BookService.Create(r.Context(), book)
 	if err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.Create")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.WriteError(w, r, errorStatus(err), err, "BookHandlerImpl.Create")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("ETag", utils.ETag(book.Version))
+	w.WriteHeader(http.StatusCreated)
 
-	if err := json.NewEncoder(w).Encode(bookDTO); err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.Create")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(mapper.MapBookToDTO(book)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "BookHandlerImpl.Create")
 		return
 	}
 }
 
 func (bookHandler *BookHandlerImpl) Update(w http.ResponseWriter, r *http.Request) {
+	version, ok := ifMatchVersion(w, r, "BookHandlerImpl.Update")
+	if !ok {
+		return
+	}
+
 	var bookDTO *dto.BookDTO
 	if err := json.NewDecoder(r.Body).Decode(&bookDTO); err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.Update")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "BookHandlerImpl.Update")
 		return
 	}
 
 	if err := validation.Validate(bookDTO); err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.Update")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "BookHandlerImpl.Update")
 		return
 	}
 
-	updatedBook, err := bookHandler.BookRepository.Update(context.Background(), mapper.MapDTOToBook(bookDTO))
+	book := mapper.MapDTOToBook(bookDTO)
+	if book.ID, ok = bodyID(w, r, book.ID, "BookHandlerImpl.Update"); !ok {
+		return
+	}
+	book.Version = version
+	updatedBook, err := bookHandler.BookService.Update(r.Context(), book)
 	if err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.Update")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.WriteError(w, r, errorStatus(err), err, "BookHandlerImpl.Update")
 		return
 	}
 
+	w.Header().Set("ETag", utils.ETag(updatedBook.Version))
 	w.WriteHeader(http.StatusOK)
 
 	if err := json.NewEncoder(w).Encode(mapper.MapBookToDTO(updatedBook)); err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.Update")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.LogError(r.Context(), err.Error(), "BookHandlerImpl.Update")
 		return
 	}
 
 }
 
-func (bookHandler *BookHandlerImpl) Delete(w http.ResponseWriter, r *http.Request) {
+// Patch принимает JSON Merge Patch или JSON Patch, проверяет итоговую книгу и записывает только изменённые поля
+func (bookHandler *BookHandlerImpl) Patch(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.Delete")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "BookHandlerImpl.Patch")
+		return
+	}
+	version, ok := ifMatchVersion(w, r, "BookHandlerImpl.Patch")
+	if !ok {
+		return
+	}
+
+	book, err := bookHandler.BookService.GetByID(r.Context(), id)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "BookHandlerImpl.Patch")
+		return
+	}
+	if version != 0 && version != book.Version {
+		wrapper.WriteError(w, r, http.StatusPreconditionFailed, domain.ErrVersionMismatch, "BookHandlerImpl.Patch")
+		return
+	}
+
+	var bookDTO dto.BookDTO
+	if !patchDTO(w, r, mapper.MapBookToDTO(book), &bookDTO, "BookHandlerImpl.Patch") {
+		return
+	}
+	if bookDTO.ID != id {
+		wrapper.WriteError(w, r, http.StatusBadRequest, errIdChanged, "BookHandlerImpl.Patch")
+		return
+	}
+	if err := validation.Validate(&bookDTO); err != nil {
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "BookHandlerImpl.Patch")
+		return
+	}
+
+	// Остальные поля только для чтения, их изменения в патче игнорируются
+	var patch repository.BookPatch
+	if bookDTO.Title != book.Title {
+		patch.Title = &bookDTO.Title
+	}
+	if bookDTO.Author != book.Author {
+		patch.Author = &bookDTO.Author
+	}
+	if bookDTO.Category != book.Category {
+		patch.Category = &bookDTO.Category
+	}
+
+	// Запись условна по версии, к которой применялся патч
+	patchedBook, err := bookHandler.BookService.Patch(r.Context(), id, book.Version, patch)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "BookHandlerImpl.Patch")
 		return
 	}
 
-	err = bookHandler.BookRepository.Delete(context.Background(), id)
+	w.Header().Set("ETag", utils.ETag(patchedBook.Version))
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(mapper.MapBookToDTO(patchedBook)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "BookHandlerImpl.Patch")
+		return
+	}
+}
+
+func (bookHandler *BookHandlerImpl) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "BookHandlerImpl.Delete")
+		return
+	}
 
+	err = bookHandler.BookService.Delete(r.Context(), id)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "BookHandlerImpl.Delete")
+		return
+	}
 	w.WriteHeader(http.StatusOK)
+}
+
+func (bookHandler *BookHandlerImpl) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		wrapper.LogError(err.Error(), "BookHandlerImpl.Delete")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "BookHandlerImpl.Restore")
 		return
 	}
 
+	book, err := bookHandler.BookService.Restore(r.Context(), id)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "BookHandlerImpl.Restore")
+		return
+	}
+
+	w.Header().Set("ETag", utils.ETag(book.Version))
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(mapper.MapBookToDTO(book)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "BookHandlerImpl.Restore")
+		return
+	}
 }
@@ -8,9 +8,13 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/Ablyamitov/simple-rest/internal/app/service"
+	"github.com/Ablyamitov/simple-rest/internal/domain"
 	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	repo "github.com/Ablyamitov/simple-rest/internal/store/db/repository"
 	"github.com/Ablyamitov/simple-rest/internal/store/db/repository/mock"
 	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
 
@@ -73,7 +77,7 @@ func TestBookHandler_GetAll(t *testing.T) {
 
 			mockRepository := repository.NewMockBookRepository(ctrl)
 			testCase.mockBehavior(mockRepository)
-			handler := NewBookHandler(mockRepository)
+			handler := NewBookHandler(service.NewBookService(mockRepository))
 
 			req := httptest.NewRequest(http.MethodGet, "/books", nil)
 			w := httptest.NewRecorder()
@@ -152,7 +156,7 @@ func TestBookHandler_GetById(t *testing.T) {
 
 			mockRepository := repository.NewMockBookRepository(ctrl)
 			testCase.mockBehavior(mockRepository)
-			handler := NewBookHandler(mockRepository)
+			handler := NewBookHandler(service.NewBookService(mockRepository))
 
 			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/books/%s", testCase.inputID), nil)
 			req, err := func(req *http.Request, strId string) (*http.Request, error) {
@@ -191,4 +195,262 @@ func TestBookHandler_GetById(t *testing.T) {
 
 func TestBookHandler_Create(t *testing.T) {
 
+	type mockBehavior func(mockRepository *repository.MockBookRepository)
+
+	testCases := []struct {
+		name               string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name: "Test 1: OK",
+			body: `{"title": "Test", "author": "Test"}`,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, book *entity.Book) error {
+					book.ID, book.Version = 1, 1
+					return nil
+				})
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "Test 2: Invalid book",
+			body:               `{"title": "Test"}`,
+			mockBehavior:       func(mockRepository *repository.MockBookRepository) {},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Test 3: Conflict",
+			body: `{"title": "Test", "author": "Test"}`,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.ErrConflict)
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name: "Test 4: Database error",
+			body: `{"title": "Test", "author": "Test"}`,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).Return(errors.New("db down"))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepository := repository.NewMockBookRepository(ctrl)
+			testCase.mockBehavior(mockRepository)
+			handler := NewBookHandler(service.NewBookService(mockRepository))
+
+			w := httptest.NewRecorder()
+			handler.Create(w, httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(testCase.body)))
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestBookHandler_DeleteAndRestore(t *testing.T) {
+
+	type mockBehavior func(mockRepository *repository.MockBookRepository)
+
+	testCases := []struct {
+		name               string
+		restore            bool
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name: "Test 1: Delete OK",
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().Delete(gomock.Any(), gomock.Eq(1)).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "Test 2: Delete book on loan",
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().Delete(gomock.Any(), gomock.Eq(1)).Return(domain.ErrActiveLoans)
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name: "Test 3: Delete already deleted",
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().Delete(gomock.Any(), gomock.Eq(1)).Return(domain.ErrBookNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:    "Test 4: Restore OK",
+			restore: true,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().Restore(gomock.Any(), gomock.Eq(1)).Return(nil)
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(&entity.Book{ID: 1, Title: "Test", Author: "Test"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:    "Test 5: Restore not deleted",
+			restore: true,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().Restore(gomock.Any(), gomock.Eq(1)).Return(domain.ErrBookNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepository := repository.NewMockBookRepository(ctrl)
+			testCase.mockBehavior(mockRepository)
+			handler := NewBookHandler(service.NewBookService(mockRepository))
+
+			w := httptest.NewRecorder()
+			if testCase.restore {
+				handler.Restore(w, chiCtxWithID(httptest.NewRequest(http.MethodPost, "/books/1/restore", nil), 1))
+			} else {
+				handler.Delete(w, chiCtxWithID(httptest.NewRequest(http.MethodDelete, "/books/1", nil), 1))
+			}
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestBookHandler_Patch(t *testing.T) {
+
+	type mockBehavior func(mockRepository *repository.MockBookRepository)
+
+	current := &entity.Book{ID: 1, Title: "Old title", Author: "Author", Category: "Fiction", Available: true, Version: 3}
+	newTitle := "New title"
+
+	testCases := []struct {
+		name               string
+		contentType        string
+		ifMatch            string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+		expectedETag       string
+	}{
+		{
+			name:        "Test 1: Merge patch writes only the changed column",
+			contentType: "application/merge-patch+json",
+			ifMatch:     `"3"`,
+			body:        `{"title": "New title", "rating_count": 100}`,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(current, nil)
+				mockRepository.EXPECT().
+					Patch(gomock.Any(), gomock.Eq(1), gomock.Eq(3), gomock.Eq(repo.BookPatch{Title: &newTitle})).
+					Return(&entity.Book{ID: 1, Title: newTitle, Author: "Author", Category: "Fiction", Available: true, Version: 4}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedETag:       `"4"`,
+		},
+		{
+			name:        "Test 2: JSON patch",
+			contentType: "application/json-patch+json",
+			ifMatch:     "*",
+			body:        `[{"op": "test", "path": "/author", "value": "Author"}, {"op": "replace", "path": "/title", "value": "New title"}]`,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(current, nil)
+				mockRepository.EXPECT().
+					Patch(gomock.Any(), gomock.Eq(1), gomock.Eq(3), gomock.Eq(repo.BookPatch{Title: &newTitle})).
+					Return(&entity.Book{ID: 1, Title: newTitle, Author: "Author", Version: 4}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedETag:       `"4"`,
+		},
+		{
+			name:        "Test 3: Merged result is validated",
+			contentType: "application/merge-patch+json",
+			ifMatch:     `"3"`,
+			body:        `{"title": null}`,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(current, nil)
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "Test 4: Stale version",
+			contentType: "application/merge-patch+json",
+			ifMatch:     `"2"`,
+			body:        `{"title": "New title"}`,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(current, nil)
+			},
+			expectedStatusCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:               "Test 5: Missing If-Match",
+			contentType:        "application/merge-patch+json",
+			body:               `{"title": "New title"}`,
+			mockBehavior:       func(mockRepository *repository.MockBookRepository) {},
+			expectedStatusCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:        "Test 6: Unsupported content type",
+			contentType: "application/json",
+			ifMatch:     `"3"`,
+			body:        `{"title": "New title"}`,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(current, nil)
+			},
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "Test 7: Failed test operation",
+			contentType: "application/json-patch+json",
+			ifMatch:     `"3"`,
+			body:        `[{"op": "test", "path": "/title", "value": "Other"}]`,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(current, nil)
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:        "Test 8: Concurrent change between read and write",
+			contentType: "application/merge-patch+json",
+			ifMatch:     `"3"`,
+			body:        `{"author": "Other"}`,
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(current, nil)
+				mockRepository.EXPECT().Patch(gomock.Any(), gomock.Eq(1), gomock.Eq(3), gomock.Any()).Return(nil, domain.ErrVersionMismatch)
+			},
+			expectedStatusCode: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepository := repository.NewMockBookRepository(ctrl)
+			testCase.mockBehavior(mockRepository)
+			handler := NewBookHandler(service.NewBookService(mockRepository))
+
+			req := httptest.NewRequest(http.MethodPatch, "/books/1", strings.NewReader(testCase.body))
+			req.Header.Set("Content-Type", testCase.contentType)
+			if testCase.ifMatch != "" {
+				req.Header.Set("If-Match", testCase.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			handler.Patch(w, chiCtxWithID(req, 1))
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			if testCase.expectedETag != "" {
+				assert.Equal(t, testCase.expectedETag, w.Header().Get("ETag"))
+			}
+		})
+	}
 }
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Ablyamitov/simple-rest/internal/app"
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+)
+
+type ConfigHandler interface {
+	GetStatus(w http.ResponseWriter, r *http.Request)
+}
+
+type ConfigHandlerImpl struct {
+	Reloader *app.ConfigReloader
+}
+
+func NewConfigHandler(reloader *app.ConfigReloader) ConfigHandler {
+	return &ConfigHandlerImpl{Reloader: reloader}
+}
+
+// GetStatus показывает версию и отпечаток действующей конфигурации, чтобы проверить, что перезагрузка применилась
+func (configHandler *ConfigHandlerImpl) GetStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(configHandler.Reloader.Status()); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "ConfigHandlerImpl.GetStatus")
+	}
+}
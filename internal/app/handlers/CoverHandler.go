@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/utils"
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/store/blob"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/mapper"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	coverFormField    = "cover"
+	coverOriginalSize = "original"
+	coverCacheControl = "public, max-age=604800"
+)
+
+var (
+	errCoverTooLarge  = errors.New("cover image is too large")
+	errCoverNotFound  = errors.New("book has no cover")
+	errCoverBadSize   = errors.New("unknown cover size, expected small, medium, large or original")
+	errCoverEmptyBody = errors.New("cover image is empty")
+)
+
+type CoverHandler interface {
+	Upload(w http.ResponseWriter, r *http.Request)
+	Get(w http.ResponseWriter, r *http.Request)
+}
+
+type CoverHandlerImpl struct {
+	BookRepository repository.BookRepository
+	BlobStore      blob.BlobStore
+	MaxSize        int64
+}
+
+func NewCoverHandler(bookRepository repository.BookRepository, blobStore blob.BlobStore, maxSize int64) CoverHandler {
+	return &CoverHandlerImpl{BookRepository: bookRepository, BlobStore: blobStore, MaxSize: maxSize}
+}
+
+func (coverHandler *CoverHandlerImpl) Upload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "CoverHandlerImpl.Upload")
+		return
+	}
+
+	book, err := coverHandler.BookRepository.GetByID(r.Context(), id)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "CoverHandlerImpl.Upload")
+		return
+	}
+
+	data, err := coverHandler.readCover(w, r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			wrapper.WriteError(w, r, http.StatusRequestEntityTooLarge, errCoverTooLarge, "CoverHandlerImpl.Upload")
+		} else {
+			wrapper.WriteError(w, r, http.StatusBadRequest, err, "CoverHandlerImpl.Upload")
+		}
+		return
+	}
+
+	contentType, err := utils.SniffCoverType(data)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusUnsupportedMediaType, err, "CoverHandlerImpl.Upload")
+		return
+	}
+
+	thumbnails, err := utils.GenerateCoverThumbnails(data)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "CoverHandlerImpl.Upload")
+		return
+	}
+
+	// Новая версия пишется рядом со старой, а книга переключается на неё, только когда записаны все размеры:
+	// иначе по старому URL отдавалась бы смесь старых и новых изображений
+	updatedAt := time.Now().UTC().Truncate(time.Second)
+	if book.CoverUpdatedAt != nil && !updatedAt.After(*book.CoverUpdatedAt) {
+		updatedAt = book.CoverUpdatedAt.Add(time.Second)
+	}
+	if err = coverHandler.putCover(r.Context(), id, updatedAt, data, contentType, thumbnails); err != nil {
+		coverHandler.deleteCover(r.Context(), id, updatedAt)
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "CoverHandlerImpl.Upload")
+		return
+	}
+	if err = coverHandler.BookRepository.UpdateCover(r.Context(), id, updatedAt); err != nil {
+		coverHandler.deleteCover(r.Context(), id, updatedAt)
+		wrapper.WriteError(w, r, errorStatus(err), err, "CoverHandlerImpl.Upload")
+		return
+	}
+	if book.CoverUpdatedAt != nil {
+		coverHandler.deleteCover(r.Context(), id, *book.CoverUpdatedAt)
+	}
+	book.CoverUpdatedAt = &updatedAt
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(mapper.MapBookToDTO(book)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "CoverHandlerImpl.Upload")
+	}
+}
+
+func (coverHandler *CoverHandlerImpl) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "CoverHandlerImpl.Get")
+		return
+	}
+
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		size = "medium"
+	}
+	if _, ok := utils.CoverSizes[size]; !ok && size != coverOriginalSize {
+		wrapper.WriteError(w, r, http.StatusBadRequest, errCoverBadSize, "CoverHandlerImpl.Get")
+		return
+	}
+
+	book, err := coverHandler.BookRepository.GetByID(r.Context(), id)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "CoverHandlerImpl.Get")
+		return
+	}
+	if book.CoverUpdatedAt == nil {
+		wrapper.WriteError(w, r, http.StatusNotFound, errCoverNotFound, "CoverHandlerImpl.Get")
+		return
+	}
+
+	object, err := coverHandler.BlobStore.Get(r.Context(), coverKey(id, *book.CoverUpdatedAt, size))
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			wrapper.WriteError(w, r, http.StatusNotFound, errCoverNotFound, "CoverHandlerImpl.Get")
+		} else {
+			wrapper.WriteError(w, r, http.StatusInternalServerError, err, "CoverHandlerImpl.Get")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", object.ContentType)
+	w.Header().Set("Cache-Control", coverCacheControl)
+	if object.ETag != "" {
+		w.Header().Set("ETag", object.ETag)
+	}
+	// ServeContent сам отвечает 304 на If-None-Match / If-Modified-Since
+	http.ServeContent(w, r, "", object.LastModified, bytes.NewReader(object.Data))
+}
+
+// readCover принимает как multipart/form-data с полем cover, так и "сырое" тело запроса
+func (coverHandler *CoverHandlerImpl) readCover(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, coverHandler.MaxSize)
+
+	var reader io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile(coverFormField)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errCoverEmptyBody
+	}
+	return data, nil
+}
+
+func (coverHandler *CoverHandlerImpl) putCover(ctx context.Context, bookID int, updatedAt time.Time, data []byte,
+	contentType string, thumbnails map[string][]byte) error {
+	if err := coverHandler.BlobStore.Put(ctx, coverKey(bookID, updatedAt, coverOriginalSize), data, contentType); err != nil {
+		return err
+	}
+	for size, thumbnail := range thumbnails {
+		if err := coverHandler.BlobStore.Put(ctx, coverKey(bookID, updatedAt, size), thumbnail, "image/jpeg"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteCover удаляет все размеры версии обложки: недописанной новой или заменённой старой.
+// Ошибка только пишется в лог, ответ клиенту от неё не зависит
+func (coverHandler *CoverHandlerImpl) deleteCover(ctx context.Context, bookID int, updatedAt time.Time) {
+	ctx = context.WithoutCancel(ctx)
+	for _, size := range append(slices.Collect(maps.Keys(utils.CoverSizes)), coverOriginalSize) {
+		if err := coverHandler.BlobStore.Delete(ctx, coverKey(bookID, updatedAt, size)); err != nil {
+			wrapper.LogError(ctx, err.Error(), "CoverHandlerImpl.deleteCover")
+		}
+	}
+}
+
+// coverKey включает версию обложки, чтобы загрузка новой не перезаписывала ту, что сейчас отдаётся
+func coverKey(bookID int, updatedAt time.Time, size string) string {
+	if size == coverOriginalSize {
+		return fmt.Sprintf("covers/%d/%d/%s", bookID, updatedAt.Unix(), size)
+	}
+	return fmt.Sprintf("covers/%d/%d/%s.jpg", bookID, updatedAt.Unix(), size)
+}
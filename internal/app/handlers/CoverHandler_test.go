@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+	"github.com/Ablyamitov/simple-rest/internal/store/blob"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository/mock"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("could not encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func withChiID(req *http.Request, id string) *http.Request {
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("id", id)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+}
+
+func TestCoverHandler_Upload(t *testing.T) {
+
+	type mockBehavior func(mockRepository *repository.MockBookRepository)
+
+	testCases := []struct {
+		name               string
+		body               []byte
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name: "Test 1: OK",
+			body: testPNG(t, 600, 900),
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(&entity.Book{ID: 1}, nil)
+				mockRepository.EXPECT().UpdateCover(gomock.Any(), gomock.Eq(1), gomock.Any()).Return(nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name: "Test 2: Not an image",
+			body: []byte("<html>definitely not a cover</html>"),
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(&entity.Book{ID: 1}, nil)
+			},
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "Test 3: Too large",
+			body: bytes.Repeat([]byte{0xff}, 2048),
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(&entity.Book{ID: 1}, nil)
+			},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "Test 4: Book not found",
+			body: testPNG(t, 10, 10),
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(nil, domain.ErrBookNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "Test 5: Book deleted during upload",
+			body: testPNG(t, 10, 10),
+			mockBehavior: func(mockRepository *repository.MockBookRepository) {
+				mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(&entity.Book{ID: 1}, nil)
+				mockRepository.EXPECT().UpdateCover(gomock.Any(), gomock.Eq(1), gomock.Any()).Return(domain.ErrBookNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepository := repository.NewMockBookRepository(ctrl)
+			testCase.mockBehavior(mockRepository)
+			maxSize := int64(1 << 20)
+			if testCase.expectedStatusCode == http.StatusRequestEntityTooLarge {
+				maxSize = 1024
+			}
+			dir := t.TempDir()
+			handler := NewCoverHandler(mockRepository, blob.NewLocalBlobStore(dir), maxSize)
+
+			req := httptest.NewRequest(http.MethodPost, "/books/1/cover", bytes.NewReader(testCase.body))
+			req = withChiID(req, "1")
+			w := httptest.NewRecorder()
+			handler.Upload(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			// Неудачная загрузка не оставляет файлов
+			if testCase.expectedStatusCode == http.StatusCreated {
+				assert.Equal(t, 4, countFiles(t, dir))
+			} else {
+				assert.Zero(t, countFiles(t, dir))
+			}
+		})
+	}
+}
+
+func TestCoverHandler_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	book := &entity.Book{ID: 1}
+	mockRepository := repository.NewMockBookRepository(ctrl)
+	mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).DoAndReturn(func(ctx context.Context, id int) (*entity.Book, error) {
+		copied := *book
+		return &copied, nil
+	}).AnyTimes()
+	mockRepository.EXPECT().UpdateCover(gomock.Any(), gomock.Eq(1), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id int, updatedAt time.Time) error {
+			book.CoverUpdatedAt = &updatedAt
+			return nil
+		}).Times(2)
+	mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(2)).Return(&entity.Book{ID: 2}, nil)
+
+	dir := t.TempDir()
+	handler := NewCoverHandler(mockRepository, blob.NewLocalBlobStore(dir), 1<<20)
+
+	req := withChiID(httptest.NewRequest(http.MethodPost, "/books/1/cover", bytes.NewReader(testPNG(t, 300, 300))), "1")
+	w := httptest.NewRecorder()
+	handler.Upload(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	//1
+	req = withChiID(httptest.NewRequest(http.MethodGet, "/books/1/cover?size=small", nil), "1")
+	w = httptest.NewRecorder()
+	handler.Get(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	assert.NotEmpty(t, w.Header().Get("Cache-Control"))
+	thumbnail, _, err := image.DecodeConfig(w.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, 96, thumbnail.Width)
+
+	//2
+	etag := w.Header().Get("ETag")
+	req = withChiID(httptest.NewRequest(http.MethodGet, "/books/1/cover?size=small", nil), "1")
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.Get(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	//3
+	req = withChiID(httptest.NewRequest(http.MethodGet, "/books/1/cover?size=huge", nil), "1")
+	w = httptest.NewRecorder()
+	handler.Get(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	//4
+	req = withChiID(httptest.NewRequest(http.MethodGet, "/books/2/cover", nil), "2")
+	w = httptest.NewRecorder()
+	handler.Get(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	//5 Новая обложка в ту же секунду получает следующую версию, а файлы прежней удаляются
+	previous := *book.CoverUpdatedAt
+	req = withChiID(httptest.NewRequest(http.MethodPost, "/books/1/cover", bytes.NewReader(testPNG(t, 600, 600))), "1")
+	w = httptest.NewRecorder()
+	handler.Upload(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.True(t, book.CoverUpdatedAt.After(previous))
+	assert.Equal(t, 4, countFiles(t, dir))
+
+	req = withChiID(httptest.NewRequest(http.MethodGet, "/books/1/cover?size=original", nil), "1")
+	w = httptest.NewRecorder()
+	handler.Get(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	original, _, err := image.DecodeConfig(w.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, 600, original.Width)
+}
+
+func countFiles(t *testing.T, dir string) int {
+	count := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			count++
+		}
+		return err
+	})
+	assert.NoError(t, err)
+	return count
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+)
+
+// errorStatus сопоставляет доменную ошибку с HTTP-статусом; неизвестные ошибки считаются внутренними
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrConflict), errors.Is(err, domain.ErrBookUnavailable):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrLimitExceeded):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError
+	}
+}
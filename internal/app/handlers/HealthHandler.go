@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/health"
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+)
+
+type HealthHandler interface {
+	Live(w http.ResponseWriter, r *http.Request)
+	Ready(w http.ResponseWriter, r *http.Request)
+}
+
+type HealthHandlerImpl struct {
+	Readiness *health.Readiness
+}
+
+func NewHealthHandler(readiness *health.Readiness) HealthHandler {
+	return &HealthHandlerImpl{Readiness: readiness}
+}
+
+// Live отвечает, пока процесс жив; зависимости не проверяются, чтобы их сбой не приводил к перезапуску
+func (healthHandler *HealthHandlerImpl) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(health.Report{Status: "ok"}); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "HealthHandlerImpl.Live")
+	}
+}
+
+func (healthHandler *HealthHandlerImpl) Ready(w http.ResponseWriter, r *http.Request) {
+	report, ready := healthHandler.Readiness.Check(r.Context())
+
+	w.Header().Set("Cache-Control", "no-store")
+	if ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "HealthHandlerImpl.Ready")
+	}
+}
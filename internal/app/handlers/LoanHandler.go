@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/service"
+	"github.com/Ablyamitov/simple-rest/internal/app/validation"
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/mapper"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type LoanHandlerImpl struct {
+	LoanService service.LoanService
+}
+
+type LoanHandler interface {
+	GetById(w http.ResponseWriter, r *http.Request)
+	Create(w http.ResponseWriter, r *http.Request)
+	Return(w http.ResponseWriter, r *http.Request)
+}
+
+func NewLoanHandler(loanService service.LoanService) LoanHandler {
+	return &LoanHandlerImpl{LoanService: loanService}
+}
+
+func (loanHandler *LoanHandlerImpl) GetById(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "LoanHandlerImpl.GetById")
+		return
+	}
+
+	loan, err := loanHandler.LoanService.GetByID(r.Context(), id)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "LoanHandlerImpl.GetById")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mapper.MapLoanToDTO(loan)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "LoanHandlerImpl.GetById")
+	}
+}
+
+// Create выдаёт книгу пользователю и возвращает созданный займ с его адресом в Location
+func (loanHandler *LoanHandlerImpl) Create(w http.ResponseWriter, r *http.Request) {
+	var loanDTO dto.LoanDTO
+	if err := json.NewDecoder(r.Body).Decode(&loanDTO); err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "LoanHandlerImpl.Create")
+		return
+	}
+
+	if err := validation.Validate(&loanDTO); err != nil {
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "LoanHandlerImpl.Create")
+		return
+	}
+
+	loan, err := loanHandler.LoanService.Take(r.Context(), loanDTO.UserID, loanDTO.BookID)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "LoanHandlerImpl.Create")
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", r.URL.Path, loan.ID))
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(mapper.MapLoanToDTO(loan)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "LoanHandlerImpl.Create")
+	}
+}
+
+// Return закрывает займ: DELETE /loans/{id} возвращает книгу в библиотеку
+func (loanHandler *LoanHandlerImpl) Return(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "LoanHandlerImpl.Return")
+		return
+	}
+
+	loan, err := loanHandler.LoanService.Return(r.Context(), id)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "LoanHandlerImpl.Return")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mapper.MapLoanToDTO(loan)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "LoanHandlerImpl.Return")
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/service"
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	mock "github.com/Ablyamitov/simple-rest/internal/store/db/repository/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var errorIDPattern = regexp.MustCompile(`"error_id":"[^"]+"`)
+
+var testLoanPolicy = service.LoanPolicy{MaxActiveLoans: 5, LoanPeriod: 14 * 24 * time.Hour}
+
+func TestLoanHandler_Create(t *testing.T) {
+
+	type mockBehavior func(mockRepository *mock.MockLoanRepository)
+
+	takenDate := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+	dueDate := takenDate.Add(testLoanPolicy.LoanPeriod)
+
+	testCases := []struct {
+		name               string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name: "Test 1: Created",
+			body: `{"user_id": 1, "book_id": 2}`,
+			mockBehavior: func(mockRepository *mock.MockLoanRepository) {
+				mockRepository.EXPECT().Create(gomock.Any(), gomock.Eq(1), gomock.Eq(2), gomock.Any()).
+					Return(&entity.Loan{ID: 5, UserID: 1, BookID: 2, TakenDate: takenDate, DueDate: dueDate}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedLocation:   "/api/v1/loans/5",
+			expectedBody: `{"id":5,"user_id":1,"book_id":2,"taken_date":"2026-10-19T10:00:00Z",` +
+				`"due_date":"2026-11-02T10:00:00Z","return_date":null}` +
+				"\n",
+		},
+		{
+			name:               "Test 2: Missing book",
+			body:               `{"user_id": 1}`,
+			mockBehavior:       func(mockRepository *mock.MockLoanRepository) {},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedBody: `{"type":"about:blank","title":"Unprocessable Entity","status":422,` +
+				`"detail":"book_id is required","instance":"/api/v1/loans","error_id":"<id>",` +
+				`"errors":[{"field":"book_id","message":"is required"}]}` + "\n",
+		},
+		{
+			name: "Test 3: User or book not found",
+			body: `{"user_id": 1, "book_id": 9}`,
+			mockBehavior: func(mockRepository *mock.MockLoanRepository) {
+				mockRepository.EXPECT().Create(gomock.Any(), gomock.Eq(1), gomock.Eq(9), gomock.Any()).
+					Return(nil, domain.ErrBookNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "Test 4: Book is taken",
+			body: `{"user_id": 1, "book_id": 2}`,
+			mockBehavior: func(mockRepository *mock.MockLoanRepository) {
+				mockRepository.EXPECT().Create(gomock.Any(), gomock.Eq(1), gomock.Eq(2), gomock.Any()).
+					Return(nil, domain.ErrBookUnavailable)
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name: "Test 5: Loan limit reached",
+			body: `{"user_id": 1, "book_id": 2}`,
+			mockBehavior: func(mockRepository *mock.MockLoanRepository) {
+				mockRepository.EXPECT().Create(gomock.Any(), gomock.Eq(1), gomock.Eq(2), gomock.Any()).
+					Return(nil, domain.NewError(domain.ErrLimitExceeded, "user already has 5 active loans"))
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Test 6: Repository error",
+			body: `{"user_id": 1, "book_id": 2}`,
+			mockBehavior: func(mockRepository *mock.MockLoanRepository) {
+				mockRepository.EXPECT().Create(gomock.Any(), gomock.Eq(1), gomock.Eq(2), gomock.Any()).
+					Return(nil, errors.New("db down"))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepository := mock.NewMockLoanRepository(ctrl)
+			testCase.mockBehavior(mockRepository)
+			handler := NewLoanHandler(service.NewLoanService(mockRepository, testLoanPolicy))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/loans", strings.NewReader(testCase.body))
+			w := httptest.NewRecorder()
+			handler.Create(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			if testCase.expectedLocation != "" {
+				assert.Equal(t, testCase.expectedLocation, w.Header().Get("Location"))
+			}
+			if testCase.expectedBody != "" {
+				// error_id случаен, поэтому сравнивается тело без него
+				body := errorIDPattern.ReplaceAllString(w.Body.String(), `"error_id":"<id>"`)
+				assert.Equal(t, testCase.expectedBody, body)
+			}
+		})
+	}
+}
+
+func TestLoanHandler_Return(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mock.NewMockLoanRepository(ctrl)
+	handler := NewLoanHandler(service.NewLoanService(mockRepository, testLoanPolicy))
+
+	returnDate := time.Date(2026, time.October, 20, 10, 0, 0, 0, time.UTC)
+	mockRepository.EXPECT().Return(gomock.Any(), gomock.Eq(5)).
+		Return(&entity.Loan{ID: 5, UserID: 1, BookID: 2, ReturnDate: &returnDate}, nil)
+	req := chiCtxWithID(httptest.NewRequest(http.MethodDelete, "/api/v1/loans/5", nil), 5)
+	w := httptest.NewRecorder()
+	handler.Return(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"return_date":"2026-10-20T10:00:00Z"`)
+
+	// Повторный возврат того же займа
+	mockRepository.EXPECT().Return(gomock.Any(), gomock.Eq(5)).Return(nil, domain.ErrLoanNotFound)
+	req = chiCtxWithID(httptest.NewRequest(http.MethodDelete, "/api/v1/loans/5", nil), 5)
+	w = httptest.NewRecorder()
+	handler.Return(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/utils"
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxPatchSize = 1 << 20
+
+var (
+	errIdChanged  = errors.New("id cannot be changed")
+	errIdMismatch = errors.New("id in body does not match id in path")
+)
+
+// bodyID сверяет id из тела с id из пути: PUT /{id} берёт id из пути, устаревший PATCH /update - из тела
+func bodyID(w http.ResponseWriter, r *http.Request, id int, method string) (int, bool) {
+	param := chi.URLParam(r, "id")
+	if param == "" {
+		return id, true
+	}
+	pathID, err := strconv.Atoi(param)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, method)
+		return 0, false
+	}
+	if id != 0 && id != pathID {
+		wrapper.WriteError(w, r, http.StatusBadRequest, errIdMismatch, method)
+		return 0, false
+	}
+	return pathID, true
+}
+
+// ifMatchVersion читает версию из If-Match: без заголовка отвечает 428, с некорректным - 412
+func ifMatchVersion(w http.ResponseWriter, r *http.Request, method string) (int, bool) {
+	version, err := utils.IfMatchVersion(r)
+	if err != nil {
+		if errors.Is(err, utils.ErrIfMatchRequired) {
+			wrapper.WriteError(w, r, http.StatusPreconditionRequired, err, method)
+		} else {
+			wrapper.WriteError(w, r, http.StatusPreconditionFailed, err, method)
+		}
+		return 0, false
+	}
+	return version, true
+}
+
+// patchDTO применяет тело PATCH-запроса к текущему представлению current и декодирует результат в target
+func patchDTO(w http.ResponseWriter, r *http.Request, current any, target any, method string) bool {
+	document, err := json.Marshal(current)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, method)
+		return false
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, method)
+		return false
+	}
+
+	patched, err := utils.ApplyPatch(r.Header.Get("Content-Type"), document, patch)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrUnsupportedPatchType):
+			w.Header().Set("Accept-Patch", utils.AcceptPatch)
+			wrapper.WriteError(w, r, http.StatusUnsupportedMediaType, err, method)
+		case errors.Is(err, utils.ErrPatchTestFailed):
+			wrapper.WriteError(w, r, http.StatusConflict, err, method)
+		default:
+			wrapper.WriteError(w, r, http.StatusBadRequest, err, method)
+		}
+		return false
+	}
+
+	if err = json.Unmarshal(patched, target); err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, method)
+		return false
+	}
+	return true
+}
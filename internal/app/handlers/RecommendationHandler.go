@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/middlewares"
+	"github.com/Ablyamitov/simple-rest/internal/app/recommendation"
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/mapper"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultRecommendationLimit = 10
+	maxRecommendationLimit     = 50
+)
+
+var errInvalidLimit = errors.New("limit must be a number between 1 and 50")
+
+type RecommendationHandler interface {
+	GetForMe(w http.ResponseWriter, r *http.Request)
+	GetSimilar(w http.ResponseWriter, r *http.Request)
+}
+
+type RecommendationHandlerImpl struct {
+	Recommender recommendation.Recommender
+}
+
+func NewRecommendationHandler(recommender recommendation.Recommender) RecommendationHandler {
+	return &RecommendationHandlerImpl{Recommender: recommender}
+}
+
+func (recommendationHandler *RecommendationHandlerImpl) GetForMe(w http.ResponseWriter, r *http.Request) {
+	limit, err := recommendationLimit(r)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "RecommendationHandlerImpl.GetForMe")
+		return
+	}
+
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
+	books, err := recommendationHandler.Recommender.ForUser(r.Context(), claims.UserID, limit)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "RecommendationHandlerImpl.GetForMe")
+		return
+	}
+
+	writeBooks(w, r, books, "RecommendationHandlerImpl.GetForMe")
+}
+
+func (recommendationHandler *RecommendationHandlerImpl) GetSimilar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "RecommendationHandlerImpl.GetSimilar")
+		return
+	}
+	limit, err := recommendationLimit(r)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "RecommendationHandlerImpl.GetSimilar")
+		return
+	}
+
+	books, err := recommendationHandler.Recommender.Similar(r.Context(), id, limit)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "RecommendationHandlerImpl.GetSimilar")
+		return
+	}
+
+	writeBooks(w, r, books, "RecommendationHandlerImpl.GetSimilar")
+}
+
+func recommendationLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultRecommendationLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxRecommendationLimit {
+		return 0, errInvalidLimit
+	}
+	return limit, nil
+}
+
+func writeBooks(w http.ResponseWriter, r *http.Request, books []entity.Book, method string) {
+	booksDTO := make([]*dto.BookDTO, 0, len(books))
+	for _, book := range books {
+		booksDTO = append(booksDTO, mapper.MapBookToDTO(&book))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(booksDTO); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), method)
+	}
+}
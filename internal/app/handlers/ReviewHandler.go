@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/middlewares"
+	"github.com/Ablyamitov/simple-rest/internal/app/validation"
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/mapper"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	errNotReviewAuthor     = errors.New("only the author can change the review")
+	errReviewNotFound      = errors.New("review not found")
+	errUnknownReviewStatus = errors.New("unknown review status, expected pending, approved or hidden")
+)
+
+type ReviewHandler interface {
+	GetByBook(w http.ResponseWriter, r *http.Request)
+	Create(w http.ResponseWriter, r *http.Request)
+	Update(w http.ResponseWriter, r *http.Request)
+	Delete(w http.ResponseWriter, r *http.Request)
+	GetModerationQueue(w http.ResponseWriter, r *http.Request)
+	Approve(w http.ResponseWriter, r *http.Request)
+	Hide(w http.ResponseWriter, r *http.Request)
+}
+
+type ReviewHandlerImpl struct {
+	ReviewRepository repository.ReviewRepository
+}
+
+func NewReviewHandler(reviewRepository repository.ReviewRepository) ReviewHandler {
+	return &ReviewHandlerImpl{ReviewRepository: reviewRepository}
+}
+
+func (reviewHandler *ReviewHandlerImpl) GetByBook(w http.ResponseWriter, r *http.Request) {
+	bookId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "ReviewHandlerImpl.GetByBook")
+		return
+	}
+
+	reviews, err := reviewHandler.ReviewRepository.GetByBook(r.Context(), bookId)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "ReviewHandlerImpl.GetByBook")
+		return
+	}
+
+	writeReviews(w, r, reviews, "ReviewHandlerImpl.GetByBook")
+}
+
+func (reviewHandler *ReviewHandlerImpl) Create(w http.ResponseWriter, r *http.Request) {
+	bookId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "ReviewHandlerImpl.Create")
+		return
+	}
+
+	var reviewDTO *dto.ReviewDTO
+	if err := json.NewDecoder(r.Body).Decode(&reviewDTO); err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "ReviewHandlerImpl.Create")
+		return
+	}
+
+	if err := validation.Validate(reviewDTO); err != nil {
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "ReviewHandlerImpl.Create")
+		return
+	}
+
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
+	review := mapper.MapDTOToReview(reviewDTO)
+	review.BookID = bookId
+	review.UserID = claims.UserID
+
+	err = reviewHandler.ReviewRepository.Create(r.Context(), review)
+	if err != nil {
+		if errors.Is(err, repository.ErrLoanNotCompleted) {
+			wrapper.WriteError(w, r, http.StatusForbidden, err, "ReviewHandlerImpl.Create")
+		} else {
+			wrapper.WriteError(w, r, errorStatus(err), err, "ReviewHandlerImpl.Create")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(mapper.MapReviewToDTO(review)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "ReviewHandlerImpl.Create")
+	}
+}
+
+func (reviewHandler *ReviewHandlerImpl) Update(w http.ResponseWriter, r *http.Request) {
+	existing, ok := reviewHandler.authorReview(w, r, "ReviewHandlerImpl.Update", false)
+	if !ok {
+		return
+	}
+
+	var reviewDTO *dto.ReviewDTO
+	if err := json.NewDecoder(r.Body).Decode(&reviewDTO); err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "ReviewHandlerImpl.Update")
+		return
+	}
+
+	if err := validation.Validate(reviewDTO); err != nil {
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "ReviewHandlerImpl.Update")
+		return
+	}
+
+	review := mapper.MapDTOToReview(reviewDTO)
+	review.ID = existing.ID
+
+	// Изменённый отзыв снова уходит на модерацию
+	updatedReview, err := reviewHandler.ReviewRepository.Update(r.Context(), review)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "ReviewHandlerImpl.Update")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mapper.MapReviewToDTO(updatedReview)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "ReviewHandlerImpl.Update")
+	}
+}
+
+func (reviewHandler *ReviewHandlerImpl) Delete(w http.ResponseWriter, r *http.Request) {
+	existing, ok := reviewHandler.authorReview(w, r, "ReviewHandlerImpl.Delete", true)
+	if !ok {
+		return
+	}
+
+	if err := reviewHandler.ReviewRepository.Delete(r.Context(), existing.ID); err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "ReviewHandlerImpl.Delete")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (reviewHandler *ReviewHandlerImpl) GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = entity.ReviewStatusPending
+	}
+	if status != entity.ReviewStatusPending && status != entity.ReviewStatusApproved && status != entity.ReviewStatusHidden {
+		wrapper.WriteError(w, r, http.StatusBadRequest, errUnknownReviewStatus, "ReviewHandlerImpl.GetModerationQueue")
+		return
+	}
+
+	reviews, err := reviewHandler.ReviewRepository.GetByStatus(r.Context(), status)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "ReviewHandlerImpl.GetModerationQueue")
+		return
+	}
+
+	writeReviews(w, r, reviews, "ReviewHandlerImpl.GetModerationQueue")
+}
+
+func (reviewHandler *ReviewHandlerImpl) Approve(w http.ResponseWriter, r *http.Request) {
+	reviewHandler.setStatus(w, r, entity.ReviewStatusApproved, "ReviewHandlerImpl.Approve")
+}
+
+func (reviewHandler *ReviewHandlerImpl) Hide(w http.ResponseWriter, r *http.Request) {
+	reviewHandler.setStatus(w, r, entity.ReviewStatusHidden, "ReviewHandlerImpl.Hide")
+}
+
+func (reviewHandler *ReviewHandlerImpl) setStatus(w http.ResponseWriter, r *http.Request, status string, method string) {
+	id, err := strconv.Atoi(chi.URLParam(r, "reviewId"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, method)
+		return
+	}
+
+	review, err := reviewHandler.ReviewRepository.SetStatus(r.Context(), id, status)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			wrapper.WriteError(w, r, http.StatusNotFound, errReviewNotFound, method)
+		} else {
+			wrapper.WriteError(w, r, http.StatusInternalServerError, err, method)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mapper.MapReviewToDTO(review)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), method)
+	}
+}
+
+// authorReview загружает отзыв из URL и проверяет, что его меняет автор
+// (или администратор, если allowAdmin).
+func (reviewHandler *ReviewHandlerImpl) authorReview(w http.ResponseWriter, r *http.Request, method string, allowAdmin bool) (*entity.Review, bool) {
+	bookId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, method)
+		return nil, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "reviewId"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, method)
+		return nil, false
+	}
+
+	review, err := reviewHandler.ReviewRepository.GetByID(r.Context(), id)
+	if err != nil || review.BookID != bookId {
+		if err == nil || errors.Is(err, domain.ErrNotFound) {
+			wrapper.WriteError(w, r, http.StatusNotFound, errReviewNotFound, method)
+		} else {
+			wrapper.WriteError(w, r, http.StatusInternalServerError, err, method)
+		}
+		return nil, false
+	}
+
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
+	if review.UserID != claims.UserID && !(allowAdmin && claims.Role == "admin") {
+		wrapper.WriteError(w, r, http.StatusForbidden, errNotReviewAuthor, method)
+		return nil, false
+	}
+	return review, true
+}
+
+func writeReviews(w http.ResponseWriter, r *http.Request, reviews []entity.Review, method string) {
+	reviewsDTO := make([]*dto.ReviewDTO, 0, len(reviews))
+	for _, review := range reviews {
+		reviewsDTO = append(reviewsDTO, mapper.MapReviewToDTO(&review))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(reviewsDTO); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), method)
+	}
+}
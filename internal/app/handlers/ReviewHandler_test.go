@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/middlewares"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	mock "github.com/Ablyamitov/simple-rest/internal/store/db/repository/mock"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func reviewRequest(method, body string, claims *entity.Claims, params map[string]string) *http.Request {
+	req := httptest.NewRequest(method, "/books/1/reviews", bytes.NewReader([]byte(body)))
+	chiCtx := chi.NewRouteContext()
+	for key, value := range params {
+		chiCtx.URLParams.Add(key, value)
+	}
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx)
+	return req.WithContext(middlewares.WithClaims(ctx, claims))
+}
+
+func TestReviewHandler_Create(t *testing.T) {
+
+	type mockBehavior func(mockRepository *mock.MockReviewRepository)
+
+	testCases := []struct {
+		name               string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name: "Test 1: OK",
+			body: `{"rating": 5, "text": "Great book"}`,
+			mockBehavior: func(mockRepository *mock.MockReviewRepository) {
+				mockRepository.EXPECT().
+					Create(gomock.Any(), gomock.Eq(&entity.Review{BookID: 1, UserID: 7, Rating: 5, Text: "Great book"})).
+					Return(nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "Test 2: Rating out of range",
+			body:               `{"rating": 6}`,
+			mockBehavior:       func(mockRepository *mock.MockReviewRepository) {},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Test 3: No completed loan",
+			body: `{"rating": 3}`,
+			mockBehavior: func(mockRepository *mock.MockReviewRepository) {
+				mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).Return(repository.ErrLoanNotCompleted)
+			},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name: "Test 4: Already reviewed",
+			body: `{"rating": 3}`,
+			mockBehavior: func(mockRepository *mock.MockReviewRepository) {
+				mockRepository.EXPECT().Create(gomock.Any(), gomock.Any()).Return(repository.ErrReviewExists)
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepository := mock.NewMockReviewRepository(ctrl)
+			testCase.mockBehavior(mockRepository)
+			handler := NewReviewHandler(mockRepository)
+
+			req := reviewRequest(http.MethodPost, testCase.body, &entity.Claims{UserID: 7, Role: "user"}, map[string]string{"id": "1"})
+			w := httptest.NewRecorder()
+			handler.Create(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestReviewHandler_UpdateAndDelete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mock.NewMockReviewRepository(ctrl)
+	handler := NewReviewHandler(mockRepository)
+	existing := &entity.Review{ID: 3, BookID: 1, UserID: 7, Rating: 2, Status: entity.ReviewStatusApproved}
+	params := map[string]string{"id": "1", "reviewId": "3"}
+
+	//1 чужой отзыв менять нельзя
+	mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(3)).Return(existing, nil)
+	req := reviewRequest(http.MethodPatch, `{"rating": 4}`, &entity.Claims{UserID: 8, Role: "user"}, params)
+	w := httptest.NewRecorder()
+	handler.Update(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	//2 автор может изменить, отзыв уходит на модерацию
+	mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(3)).Return(existing, nil)
+	mockRepository.EXPECT().
+		Update(gomock.Any(), gomock.Eq(&entity.Review{ID: 3, Rating: 4})).
+		Return(&entity.Review{ID: 3, BookID: 1, UserID: 7, Rating: 4, Status: entity.ReviewStatusPending}, nil)
+	req = reviewRequest(http.MethodPatch, `{"rating": 4}`, &entity.Claims{UserID: 7, Role: "user"}, params)
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	//3 администратор может удалить чужой отзыв
+	mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(3)).Return(existing, nil)
+	mockRepository.EXPECT().Delete(gomock.Any(), gomock.Eq(3)).Return(nil)
+	req = reviewRequest(http.MethodDelete, "", &entity.Claims{UserID: 1, Role: "admin"}, params)
+	w = httptest.NewRecorder()
+	handler.Delete(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/mapper"
+)
+
+const (
+	defaultMostBorrowedLimit = 10
+	maxMostBorrowedLimit     = 100
+)
+
+var (
+	errInvalidDate      = errors.New("dates must be in YYYY-MM-DD format")
+	errInvalidDateRange = errors.New("from must not be after to")
+	errInvalidStatLimit = errors.New("limit must be a number between 1 and 100")
+)
+
+type StatsHandler interface {
+	GetMostBorrowed(w http.ResponseWriter, r *http.Request)
+	GetLoanLength(w http.ResponseWriter, r *http.Request)
+	GetNeverBorrowed(w http.ResponseWriter, r *http.Request)
+	GetActiveReaders(w http.ResponseWriter, r *http.Request)
+}
+
+type StatsHandlerImpl struct {
+	StatsRepository repository.StatsRepository
+}
+
+func NewStatsHandler(statsRepository repository.StatsRepository) StatsHandler {
+	return &StatsHandlerImpl{StatsRepository: statsRepository}
+}
+
+func (statsHandler *StatsHandlerImpl) GetMostBorrowed(w http.ResponseWriter, r *http.Request) {
+	filter, err := statsFilter(r)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "StatsHandlerImpl.GetMostBorrowed")
+		return
+	}
+	limit := defaultMostBorrowedLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxMostBorrowedLimit {
+			wrapper.WriteError(w, r, http.StatusBadRequest, errInvalidStatLimit, "StatsHandlerImpl.GetMostBorrowed")
+			return
+		}
+	}
+
+	result, err := statsHandler.StatsRepository.GetMostBorrowed(r.Context(), filter, limit)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "StatsHandlerImpl.GetMostBorrowed")
+		return
+	}
+
+	resultDTO := make([]*dto.BookLoansDTO, 0, len(result))
+	records := [][]string{{"book_id", "title", "author", "category", "loans"}}
+	for _, bookLoans := range result {
+		resultDTO = append(resultDTO, mapper.MapBookLoansToDTO(&bookLoans))
+		records = append(records, []string{strconv.Itoa(bookLoans.Book.ID), bookLoans.Book.Title, bookLoans.Book.Author,
+			bookLoans.Book.Category, strconv.Itoa(bookLoans.Loans)})
+	}
+	writeStats(w, r, "most-borrowed", resultDTO, records, "StatsHandlerImpl.GetMostBorrowed")
+}
+
+func (statsHandler *StatsHandlerImpl) GetLoanLength(w http.ResponseWriter, r *http.Request) {
+	filter, err := statsFilter(r)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "StatsHandlerImpl.GetLoanLength")
+		return
+	}
+
+	result, err := statsHandler.StatsRepository.GetLoanLength(r.Context(), filter)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "StatsHandlerImpl.GetLoanLength")
+		return
+	}
+
+	resultDTO := make([]*dto.LoanLengthDTO, 0, len(result))
+	records := [][]string{{"category", "returned", "average_days"}}
+	for _, loanLength := range result {
+		loanLengthDTO := mapper.MapLoanLengthToDTO(&loanLength)
+		resultDTO = append(resultDTO, loanLengthDTO)
+		records = append(records, []string{loanLengthDTO.Category, strconv.Itoa(loanLengthDTO.Returned),
+			strconv.FormatFloat(loanLengthDTO.AverageDays, 'f', 2, 64)})
+	}
+	writeStats(w, r, "loan-length", resultDTO, records, "StatsHandlerImpl.GetLoanLength")
+}
+
+func (statsHandler *StatsHandlerImpl) GetNeverBorrowed(w http.ResponseWriter, r *http.Request) {
+	filter, err := statsFilter(r)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "StatsHandlerImpl.GetNeverBorrowed")
+		return
+	}
+
+	books, err := statsHandler.StatsRepository.GetNeverBorrowed(r.Context(), filter)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "StatsHandlerImpl.GetNeverBorrowed")
+		return
+	}
+
+	booksDTO := make([]*dto.BookDTO, 0, len(books))
+	records := [][]string{{"book_id", "title", "author", "category"}}
+	for _, book := range books {
+		booksDTO = append(booksDTO, mapper.MapBookToDTO(&book))
+		records = append(records, []string{strconv.Itoa(book.ID), book.Title, book.Author, book.Category})
+	}
+	writeStats(w, r, "never-borrowed", booksDTO, records, "StatsHandlerImpl.GetNeverBorrowed")
+}
+
+func (statsHandler *StatsHandlerImpl) GetActiveReaders(w http.ResponseWriter, r *http.Request) {
+	filter, err := statsFilter(r)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "StatsHandlerImpl.GetActiveReaders")
+		return
+	}
+
+	result, err := statsHandler.StatsRepository.GetActiveReaders(r.Context(), filter)
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusInternalServerError, err, "StatsHandlerImpl.GetActiveReaders")
+		return
+	}
+
+	resultDTO := make([]*dto.WeeklyReadersDTO, 0, len(result))
+	records := [][]string{{"week", "readers"}}
+	for _, weeklyReaders := range result {
+		weeklyReadersDTO := mapper.MapWeeklyReadersToDTO(&weeklyReaders)
+		resultDTO = append(resultDTO, weeklyReadersDTO)
+		records = append(records, []string{weeklyReadersDTO.Week, strconv.Itoa(weeklyReadersDTO.Readers)})
+	}
+	writeStats(w, r, "active-readers", resultDTO, records, "StatsHandlerImpl.GetActiveReaders")
+}
+
+// statsFilter читает общие для всех отчётов параметры from, to (YYYY-MM-DD, включительно) и category
+func statsFilter(r *http.Request) (repository.StatsFilter, error) {
+	query := r.URL.Query()
+	filter := repository.StatsFilter{Category: query.Get("category")}
+
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return filter, errInvalidDate
+		}
+		*target = &date
+	}
+
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return filter, errInvalidDateRange
+	}
+	return filter, nil
+}
+
+// writeStats отдаёт отчёт как JSON или, при ?format=csv, как CSV-файл для таблиц
+func writeStats(w http.ResponseWriter, r *http.Request, name string, result any, records [][]string, method string) {
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, name))
+		w.WriteHeader(http.StatusOK)
+		if err := csv.NewWriter(w).WriteAll(records); err != nil {
+			wrapper.LogError(r.Context(), err.Error(), method)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), method)
+	}
+}
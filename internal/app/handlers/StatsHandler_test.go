@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	mock "github.com/Ablyamitov/simple-rest/internal/store/db/repository/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsHandler_GetMostBorrowed(t *testing.T) {
+
+	type mockBehavior func(mockRepository *mock.MockStatsRepository)
+
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC)
+	result := []entity.BookLoans{{Book: entity.Book{ID: 1, Title: "Dune", Author: "Frank Herbert", Category: "sci-fi"}, Loans: 4}}
+
+	testCases := []struct {
+		name                string
+		query               string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:  "Test 1: JSON",
+			query: "?from=2024-01-01&to=2024-01-31&category=sci-fi",
+			mockBehavior: func(mockRepository *mock.MockStatsRepository) {
+				mockRepository.EXPECT().
+					GetMostBorrowed(gomock.Any(), gomock.Eq(repository.StatsFilter{From: &from, To: &to, Category: "sci-fi"}), gomock.Eq(10)).
+					Return(result, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: `[{"book":{"id":1,"title":"Dune","author":"Frank Herbert","category":"sci-fi","available":false,` +
+				`"rating_average":0,"rating_count":0},"loans":4}]` + "\n",
+		},
+		{
+			name:  "Test 2: CSV",
+			query: "?format=csv&limit=5",
+			mockBehavior: func(mockRepository *mock.MockStatsRepository) {
+				mockRepository.EXPECT().
+					GetMostBorrowed(gomock.Any(), gomock.Eq(repository.StatsFilter{}), gomock.Eq(5)).
+					Return(result, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "book_id,title,author,category,loans\n1,Dune,Frank Herbert,sci-fi,4\n",
+		},
+		{
+			name:               "Test 3: Invalid date",
+			query:              "?from=01.01.2024",
+			mockBehavior:       func(mockRepository *mock.MockStatsRepository) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Test 4: Reversed range",
+			query:              "?from=2024-02-01&to=2024-01-01",
+			mockBehavior:       func(mockRepository *mock.MockStatsRepository) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Test 5: Invalid limit",
+			query:              "?limit=0",
+			mockBehavior:       func(mockRepository *mock.MockStatsRepository) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepository := mock.NewMockStatsRepository(ctrl)
+			testCase.mockBehavior(mockRepository)
+			handler := NewStatsHandler(mockRepository)
+
+			req := httptest.NewRequest(http.MethodGet, "/stats/most-borrowed"+testCase.query, nil)
+			w := httptest.NewRecorder()
+			handler.GetMostBorrowed(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			if testCase.expectedContentType != "" {
+				assert.Equal(t, testCase.expectedContentType, w.Header().Get("Content-Type"))
+			}
+			if testCase.expectedBody != "" {
+				assert.Equal(t, testCase.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
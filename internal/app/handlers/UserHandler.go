@@ -1,24 +1,25 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/Ablyamitov/simple-rest/internal/app/service"
+	"github.com/Ablyamitov/simple-rest/internal/app/utils"
 	"github.com/Ablyamitov/simple-rest/internal/app/validation"
 	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/domain"
 	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
 	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
 	"github.com/Ablyamitov/simple-rest/internal/store/web/mapper"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
 )
 
 type UserHandlerImpl struct {
-	UserRepository repository.UserRepository
+	UserService service.UserService
+	LoanService service.LoanService
 }
 
 type UserHandler interface {
@@ -26,25 +27,20 @@ type UserHandler interface {
 	GetById(w http.ResponseWriter, r *http.Request)
 	Create(w http.ResponseWriter, r *http.Request)
 	Update(w http.ResponseWriter, r *http.Request)
+	Patch(w http.ResponseWriter, r *http.Request)
 	Delete(w http.ResponseWriter, r *http.Request)
 	TakeBook(w http.ResponseWriter, r *http.Request)
 	ReturnBook(w http.ResponseWriter, r *http.Request)
 }
 
-func NewUserHandler(userRepository repository.UserRepository) UserHandler {
-	return &UserHandlerImpl{UserRepository: userRepository}
+func NewUserHandler(userService service.UserService, loanService service.LoanService) UserHandler {
+	return &UserHandlerImpl{UserService: userService, LoanService: loanService}
 }
 
 func (userHandler *UserHandlerImpl) GetAll(w http.ResponseWriter, r *http.Request) {
-	users, err := userHandler.UserRepository.GetAll(context.Background())
+	users, err := userHandler.UserService.GetAll(r.Context())
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			wrapper.LogError(err.Error(), "UserHandlerImpl.GetAll")
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			wrapper.LogError(err.Error(), "UserHandlerImpl.GetAll")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		wrapper.WriteError(w, r, errorStatus(err), err, "UserHandlerImpl.GetAll")
 		return
 	}
 
@@ -54,110 +50,172 @@ func (userHandler *UserHandlerImpl) GetAll(w http.ResponseWriter, r *http.Reques
 		usersDTO = append(usersDTO, mapper.MapUserToDTO(&user))
 	}
 	if err := json.NewEncoder(w).Encode(usersDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.GetAll")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.LogError(r.Context(), err.Error(), "UserHandlerImpl.GetAll")
 	}
 }
 
 func (userHandler *UserHandlerImpl) GetById(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.GetByID")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "UserHandlerImpl.GetByID")
 		return
 	}
 
-	user, err := userHandler.UserRepository.GetByID(context.Background(), id)
+	user, err := userHandler.UserService.GetByID(r.Context(), id)
 
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			wrapper.LogError(err.Error(), "UserHandlerImpl.GetByID")
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			wrapper.LogError(err.Error(), "UserHandlerImpl.GetByID")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		wrapper.WriteError(w, r, errorStatus(err), err, "UserHandlerImpl.GetByID")
 		return
 	}
 	userDTO := mapper.MapUserToDTO(user)
 
+	etag := utils.ETag(user.Version)
+	w.Header().Set("ETag", etag)
+	if utils.NotModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(userDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.GetByID")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.LogError(r.Context(), err.Error(), "UserHandlerImpl.GetByID")
 	}
 }
 
 func (userHandler *UserHandlerImpl) Create(w http.ResponseWriter, r *http.Request) {
 	var userDTO *dto.UserDTO
 	if err := json.NewDecoder(r.Body).Decode(&userDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.Create")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "UserHandlerImpl.Create")
 		return
 	}
 
 	if err := validation.Validate(userDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.Create")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "UserHandlerImpl.Create")
 		return
 	}
 
 	user := mapper.MapDTOToUser(userDTO)
-	err := userHandler.UserRepository.Create(context.Background(), user)
+	err := userHandler.UserService.Create(r.Context(), user)
 	if err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.Create")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.WriteError(w, r, errorStatus(err), err, "UserHandlerImpl.Create")
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
 	userDTO = mapper.MapUserToDTO(user)
 	if err := json.NewEncoder(w).Encode(userDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.Create")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.LogError(r.Context(), err.Error(), "UserHandlerImpl.Create")
 	}
 }
 
 func (userHandler *UserHandlerImpl) Update(w http.ResponseWriter, r *http.Request) {
+	version, ok := ifMatchVersion(w, r, "UserHandlerImpl.Update")
+	if !ok {
+		return
+	}
+
 	var userDTO *dto.UserDTO
 	if err := json.NewDecoder(r.Body).Decode(&userDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.Update")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "UserHandlerImpl.Update")
 		return
 	}
 
 	if err := validation.Validate(userDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.Update")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "UserHandlerImpl.Update")
 		return
 	}
 
-	updatedUser, err := userHandler.UserRepository.Update(context.Background(), mapper.MapDTOToUser(userDTO))
+	user := mapper.MapDTOToUser(userDTO)
+	if user.ID, ok = bodyID(w, r, user.ID, "UserHandlerImpl.Update"); !ok {
+		return
+	}
+	user.Version = version
+	updatedUser, err := userHandler.UserService.Update(r.Context(), user)
 	if err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.Update")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.WriteError(w, r, errorStatus(err), err, "UserHandlerImpl.Update")
 		return
 	}
 
+	w.Header().Set("ETag", utils.ETag(updatedUser.Version))
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(mapper.MapUserToDTO(updatedUser)); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.Update")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.LogError(r.Context(), err.Error(), "UserHandlerImpl.Update")
 	}
 
 }
 
+// Patch принимает JSON Merge Patch или JSON Patch, проверяет итогового пользователя и записывает только изменённые поля
+func (userHandler *UserHandlerImpl) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "UserHandlerImpl.Patch")
+		return
+	}
+	version, ok := ifMatchVersion(w, r, "UserHandlerImpl.Patch")
+	if !ok {
+		return
+	}
+
+	user, err := userHandler.UserService.GetByID(r.Context(), id)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "UserHandlerImpl.Patch")
+		return
+	}
+	if version != 0 && version != user.Version {
+		wrapper.WriteError(w, r, http.StatusPreconditionFailed, domain.ErrVersionMismatch, "UserHandlerImpl.Patch")
+		return
+	}
+
+	var userDTO dto.UserDTO
+	if !patchDTO(w, r, mapper.MapUserToDTO(user), &userDTO, "UserHandlerImpl.Patch") {
+		return
+	}
+	if userDTO.ID != id {
+		wrapper.WriteError(w, r, http.StatusBadRequest, errIdChanged, "UserHandlerImpl.Patch")
+		return
+	}
+	if err := validation.Validate(&userDTO); err != nil {
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "UserHandlerImpl.Patch")
+		return
+	}
+
+	// Роль, книги и версия только для чтения, их изменения в патче игнорируются
+	var patch repository.UserPatch
+	if userDTO.Name != user.Name {
+		patch.Name = &userDTO.Name
+	}
+	if userDTO.Email != user.Email {
+		patch.Email = &userDTO.Email
+	}
+	if userDTO.Password != user.Password {
+		// В документе лежит хеш, поэтому отличающееся значение - новый пароль, его хеширует сервис
+		patch.Password = &userDTO.Password
+	}
+
+	// Запись условна по версии, к которой применялся патч
+	patchedUser, err := userHandler.UserService.Patch(r.Context(), id, user.Version, patch)
+	if err != nil {
+		wrapper.WriteError(w, r, errorStatus(err), err, "UserHandlerImpl.Patch")
+		return
+	}
+	patchedUser.Books = user.Books
+
+	w.Header().Set("ETag", utils.ETag(patchedUser.Version))
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mapper.MapUserToDTO(patchedUser)); err != nil {
+		wrapper.LogError(r.Context(), err.Error(), "UserHandlerImpl.Patch")
+	}
+}
+
 func (userHandler *UserHandlerImpl) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.Delete")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "UserHandlerImpl.Delete")
 		return
 	}
-	err = userHandler.UserRepository.Delete(context.Background(), id)
+	err = userHandler.UserService.Delete(r.Context(), id)
 	if err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.Delete")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.WriteError(w, r, errorStatus(err), err, "UserHandlerImpl.Delete")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -166,28 +224,25 @@ func (userHandler *UserHandlerImpl) Delete(w http.ResponseWriter, r *http.Reques
 func (userHandler *UserHandlerImpl) TakeBook(w http.ResponseWriter, r *http.Request) {
 
 	type TakeBookDTO struct {
-		UserId int `json:"userId" validate:"required, notblank, gte=0"`
-		BookId int `json:"bookId" validate:"required, notblank, gte=0"`
+		UserId int `json:"userId" validate:"required,gt=0"`
+		BookId int `json:"bookId" validate:"required,gt=0"`
 	}
 
 	var takeBookDTO *TakeBookDTO
 
 	if err := json.NewDecoder(r.Body).Decode(&takeBookDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.TakeBook")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "UserHandlerImpl.TakeBook")
 		return
 	}
 
 	if err := validation.Validate(takeBookDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.TakeBook")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "UserHandlerImpl.TakeBook")
 		return
 	}
 
-	err := userHandler.UserRepository.TakeBook(context.Background(), takeBookDTO.UserId, takeBookDTO.BookId)
+	_, err := userHandler.LoanService.Take(r.Context(), takeBookDTO.UserId, takeBookDTO.BookId)
 	if err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.TakeBook")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.WriteError(w, r, errorStatus(err), err, "UserHandlerImpl.TakeBook")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -196,28 +251,25 @@ func (userHandler *UserHandlerImpl) TakeBook(w http.ResponseWriter, r *http.Requ
 func (userHandler *UserHandlerImpl) ReturnBook(w http.ResponseWriter, r *http.Request) {
 
 	type ReturnBookDTO struct {
-		UserId int `json:"userId" validate:"required, notblank, gte=0"`
-		BookId int `json:"bookId" validate:"required, notblank, gte=0"`
+		UserId int `json:"userId" validate:"required,gt=0"`
+		BookId int `json:"bookId" validate:"required,gt=0"`
 	}
 
 	var returnBookDTO ReturnBookDTO
 
 	if err := json.NewDecoder(r.Body).Decode(&returnBookDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.ReturnBook")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusBadRequest, err, "UserHandlerImpl.ReturnBook")
 		return
 	}
 
 	if err := validation.Validate(returnBookDTO); err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.ReturnBook")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		wrapper.WriteError(w, r, http.StatusUnprocessableEntity, err, "UserHandlerImpl.ReturnBook")
 		return
 	}
 
-	err := userHandler.UserRepository.ReturnBook(context.Background(), returnBookDTO.UserId, returnBookDTO.BookId)
+	_, err := userHandler.LoanService.ReturnBook(r.Context(), returnBookDTO.UserId, returnBookDTO.BookId)
 	if err != nil {
-		wrapper.LogError(err.Error(), "UserHandlerImpl.ReturnBook")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		wrapper.WriteError(w, r, errorStatus(err), err, "UserHandlerImpl.ReturnBook")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -11,8 +11,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/Ablyamitov/simple-rest/internal/app/service"
+	"github.com/Ablyamitov/simple-rest/internal/app/utils"
+	"github.com/Ablyamitov/simple-rest/internal/domain"
 	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
@@ -26,11 +30,11 @@ func TestUserHandler_GetAll(t *testing.T) {
 
 	mockRepository := repository.NewMockUserRepository(ctrl)
 
-	handler := NewUserHandler(mockRepository)
+	handler := NewUserHandler(service.NewUserService(mockRepository), nil)
 
 	users := []entity.User{
-		{ID: 1, Name: "John", Email: "john@example.com"},
-		{ID: 2, Name: "Alex", Email: "alex@example.com"},
+		{ID: 1, Name: "John", Email: "john@example.com", Books: []*entity.Book{}},
+		{ID: 2, Name: "Alex", Email: "alex@example.com", Books: []*entity.Book{}},
 	}
 
 	mockRepository.EXPECT().
@@ -86,13 +90,14 @@ func TestUserHandler_GetById(t *testing.T) {
 
 	mockRepository := repository.NewMockUserRepository(ctrl)
 
-	handler := NewUserHandler(mockRepository)
+	handler := NewUserHandler(service.NewUserService(mockRepository), nil)
 
 	//1
 	user := &entity.User{
 		ID:       1,
 		Name:     "John",
 		Email:    "john@example.com",
+		Books:    []*entity.Book{},
 		Password: "1234",
 		Role:     "user",
 	}
@@ -168,7 +173,7 @@ func TestUserHandler_Create(t *testing.T) {
 
 	mockRepository := repository.NewMockUserRepository(ctrl)
 
-	handler := NewUserHandler(mockRepository)
+	handler := NewUserHandler(service.NewUserService(mockRepository), nil)
 
 	newUser := &entity.User{
 		ID:       1,
@@ -185,7 +190,15 @@ func TestUserHandler_Create(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	mockRepository.EXPECT().
-		Create(gomock.Any(), gomock.Eq(newUser)).
+		GetByEmail(gomock.Any(), gomock.Eq("John@example.com")).
+		Return(entity.User{}, nil)
+	// Пароль сохраняется только в виде хеша
+	mockRepository.EXPECT().
+		Create(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, user *entity.User) {
+			assert.Equal(t, newUser.Email, user.Email)
+			assert.True(t, utils.CompareHashPassword("1234", user.Password))
+		}).
 		Return(nil)
 
 	handler.Create(w, req)
@@ -227,14 +240,15 @@ func TestUserHandler_Create(t *testing.T) {
 		}
 	}(resp.Body)
 
-	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
 }
 
 func TestUserHandler_Update(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mockRepository := repository.NewMockUserRepository(ctrl)
-	handler := NewUserHandler(mockRepository)
+	handler := NewUserHandler(service.NewUserService(mockRepository), nil)
 
 	existingUser := &entity.User{
 		ID:       1,
@@ -248,6 +262,7 @@ func TestUserHandler_Update(t *testing.T) {
 		ID:       1,
 		Name:     "Alex",
 		Email:    "alex@example.com",
+		Books:    []*entity.Book{},
 		Password: "1234",
 		Role:     "user",
 	}
@@ -258,10 +273,15 @@ func TestUserHandler_Update(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodPatch, "/users/update", bytes.NewReader(userJSON))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"3"`)
 	w := httptest.NewRecorder()
 
+	// Версия для проверки берётся из If-Match, а в ответе возвращается уже увеличенной
+	expectedUser := *updatedUser
+	expectedUser.Version = 3
+	updatedUser.Version = 4
 	mockRepository.EXPECT().
-		Update(gomock.Any(), gomock.Eq(updatedUser)).
+		Update(gomock.Any(), gomock.Eq(&expectedUser)).
 		Return(updatedUser, nil)
 
 	handler.Update(w, req)
@@ -280,6 +300,7 @@ func TestUserHandler_Update(t *testing.T) {
 
 	assert.Equal(t, *updatedUser, responseUser)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"4"`, resp.Header.Get("ETag"))
 
 }
 
@@ -287,7 +308,7 @@ func TestUserHandler_Delete(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mockRepository := repository.NewMockUserRepository(ctrl)
-	handler := NewUserHandler(mockRepository)
+	handler := NewUserHandler(service.NewUserService(mockRepository), nil)
 	//1
 	req := httptest.NewRequest(http.MethodDelete, "/users/1", nil)
 	req = chiCtxWithID(req, 1)
@@ -348,3 +369,74 @@ func chiCtxWithID(req *http.Request, id int) *http.Request {
 	chiCtx.URLParams.Add("id", strconv.Itoa(id))
 	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
 }
+
+func TestUserHandler_UpdatePreconditions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockUserRepository(ctrl)
+	handler := NewUserHandler(service.NewUserService(mockRepository), nil)
+	body := `{"id": 1, "name": "Alex", "email": "alex@example.com", "password": "1234"}`
+
+	//1 без If-Match обновление не выполняется
+	req := httptest.NewRequest(http.MethodPatch, "/users/update", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.Update(w, req)
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+
+	//2 устаревшая версия
+	mockRepository.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, domain.ErrVersionMismatch)
+	req = httptest.NewRequest(http.MethodPatch, "/users/update", strings.NewReader(body))
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	//3 слабый ETag не подходит для If-Match
+	req = httptest.NewRequest(http.MethodPatch, "/users/update", strings.NewReader(body))
+	req.Header.Set("If-Match", `W/"1"`)
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	//4 PUT /users/{id}: id в теле расходится с id в пути
+	req = chiCtxWithID(httptest.NewRequest(http.MethodPut, "/api/v1/users/2", strings.NewReader(body)), 2)
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	//5 PUT /users/{id}: id берётся из пути, если в теле его нет
+	mockRepository.EXPECT().Update(gomock.Any(), gomock.Eq(&entity.User{ID: 2, Name: "Alex", Email: "alex@example.com",
+		Books: []*entity.Book{}, Password: "1234", Version: 1})).Return(&entity.User{ID: 2, Version: 2}, nil)
+	req = chiCtxWithID(httptest.NewRequest(http.MethodPut, "/api/v1/users/2",
+		strings.NewReader(`{"name": "Alex", "email": "alex@example.com", "password": "1234"}`)), 2)
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUserHandler_GetByIdNotModified(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockUserRepository(ctrl)
+	handler := NewUserHandler(service.NewUserService(mockRepository), nil)
+
+	mockRepository.EXPECT().GetByID(gomock.Any(), gomock.Eq(1)).Return(&entity.User{ID: 1, Name: "Alex", Version: 2}, nil).Times(2)
+
+	//1 актуальная версия
+	req := chiCtxWithID(httptest.NewRequest(http.MethodGet, "/users/1", nil), 1)
+	req.Header.Set("If-None-Match", `"1", W/"2"`)
+	w := httptest.NewRecorder()
+	handler.GetById(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	//2 устаревшая версия
+	req = chiCtxWithID(httptest.NewRequest(http.MethodGet, "/users/1", nil), 1)
+	req.Header.Set("If-None-Match", `"1"`)
+	w = httptest.NewRecorder()
+	handler.GetById(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+}
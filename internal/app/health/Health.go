@@ -0,0 +1,131 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// Check - одна зависимость, без которой сервис не может обслуживать запросы.
+// Сбой необязательной (Optional) зависимости попадает в отчёт, но не снимает готовность
+type Check struct {
+	Name     string
+	Run      func(ctx context.Context) error
+	Optional bool
+}
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Readiness выполняет проверки параллельно, каждую со своим таймаутом.
+// После Shutdown сервис сразу считается неготовым, чтобы балансировщик перестал слать ему запросы
+type Readiness struct {
+	Checks       []Check
+	Timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewReadiness(timeout time.Duration, checks ...Check) *Readiness {
+	return &Readiness{Checks: checks, Timeout: timeout}
+}
+
+func (readiness *Readiness) Shutdown() {
+	readiness.shuttingDown.Store(true)
+}
+
+// Check возвращает отчёт и признак готовности
+func (readiness *Readiness) Check(ctx context.Context) (Report, bool) {
+	report := Report{Status: "ok", Checks: make(map[string]CheckResult, len(readiness.Checks)+1)}
+	if readiness.shuttingDown.Load() {
+		report.Checks["shutdown"] = CheckResult{Status: "error", Error: errShuttingDown.Error()}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var degraded atomic.Bool
+	for _, check := range readiness.Checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, readiness.Timeout)
+			defer cancel()
+
+			started := time.Now()
+			err := check.Run(checkCtx)
+			result := CheckResult{Status: "ok", DurationMs: time.Since(started).Milliseconds()}
+			if err != nil {
+				result.Status = "error"
+				result.Error = err.Error()
+			}
+			if err != nil && check.Optional {
+				result.Status = "degraded"
+				degraded.Store(true)
+			}
+			mu.Lock()
+			report.Checks[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == "error" {
+			report.Status = "unavailable"
+			return report, false
+		}
+	}
+	if degraded.Load() {
+		report.Status = "degraded"
+	}
+	return report, true
+}
+
+func PostgresCheck(conn *pgxpool.Pool) Check {
+	return Check{Name: "postgres", Run: conn.Ping}
+}
+
+// RedisCheck необязательна: пока Redis недоступен, книги и пользователи кешируются в памяти
+func RedisCheck(redisClient *redis.Client) Check {
+	return Check{Name: "redis", Optional: true, Run: func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	}}
+}
+
+// MigrationsCheck сверяет версию схемы в базе с последней встроенной миграцией
+func MigrationsCheck(conn *pgxpool.Pool) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) error {
+		expected, err := store.LatestMigrationVersion()
+		if err != nil {
+			return err
+		}
+		version, dirty, err := store.MigrationVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("migration %d is dirty", version)
+		}
+		if version != expected {
+			return fmt.Errorf("schema version %d, expected %d", version, expected)
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadiness_Check(t *testing.T) {
+	ok := Check{Name: "postgres", Run: func(ctx context.Context) error { return nil }}
+	failing := Check{Name: "redis", Run: func(ctx context.Context) error { return errors.New("connection refused") }}
+	optional := Check{Name: "redis", Optional: true, Run: func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}}
+	hanging := Check{Name: "migrations", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	testCases := []struct {
+		name           string
+		checks         []Check
+		shutdown       bool
+		expectedReady  bool
+		expectedStatus string
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "Test 1: All checks pass",
+			checks:         []Check{ok},
+			expectedReady:  true,
+			expectedStatus: "ok",
+		},
+		{
+			name:           "Test 2: Failing and hanging checks",
+			checks:         []Check{ok, failing, hanging},
+			expectedStatus: "unavailable",
+			expectedErrors: map[string]string{"redis": "connection refused", "migrations": "context deadline exceeded"},
+		},
+		{
+			name:           "Test 3: Shutting down",
+			checks:         []Check{ok},
+			shutdown:       true,
+			expectedStatus: "unavailable",
+			expectedErrors: map[string]string{"shutdown": "server is shutting down"},
+		},
+		{
+			name:           "Test 4: Failing optional check keeps the service ready",
+			checks:         []Check{ok, optional},
+			expectedReady:  true,
+			expectedStatus: "degraded",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			readiness := NewReadiness(20*time.Millisecond, testCase.checks...)
+			if testCase.shutdown {
+				readiness.Shutdown()
+			}
+
+			report, ready := readiness.Check(context.Background())
+
+			assert.Equal(t, testCase.expectedReady, ready)
+			assert.Equal(t, "ok", report.Checks["postgres"].Status)
+			for name, message := range testCase.expectedErrors {
+				assert.Equal(t, "error", report.Checks[name].Status)
+				assert.Equal(t, message, report.Checks[name].Error)
+			}
+			assert.Equal(t, testCase.expectedStatus, report.Status)
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/metrics"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+)
+
+// LoanMetricsJob обновляет число выданных и просроченных книг в метриках
+type LoanMetricsJob struct {
+	LoanRepository repository.LoanRepository
+	Metrics        metrics.Metrics
+}
+
+func NewLoanMetricsJob(loanRepository repository.LoanRepository, metrics metrics.Metrics) *LoanMetricsJob {
+	return &LoanMetricsJob{LoanRepository: loanRepository, Metrics: metrics}
+}
+
+func (job *LoanMetricsJob) Name() string {
+	return "metrics.loans"
+}
+
+func (job *LoanMetricsJob) Run(ctx context.Context) error {
+	counts, err := job.LoanRepository.CountOpen(ctx)
+	if err != nil {
+		return err
+	}
+	job.Metrics.SetLoans(counts.Active, counts.Overdue)
+	return nil
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+)
+
+// PurgeDeletedJob окончательно удаляет книги и пользователей, которые пролежали удалёнными дольше Retention
+type PurgeDeletedJob struct {
+	BookRepository repository.BookRepository
+	UserRepository repository.UserRepository
+	Retention      time.Duration
+}
+
+func NewPurgeDeletedJob(bookRepository repository.BookRepository, userRepository repository.UserRepository,
+	retention time.Duration) *PurgeDeletedJob {
+	return &PurgeDeletedJob{BookRepository: bookRepository, UserRepository: userRepository, Retention: retention}
+}
+
+func (job *PurgeDeletedJob) Name() string {
+	return "soft_delete.purge"
+}
+
+func (job *PurgeDeletedJob) Run(ctx context.Context) error {
+	deletedBefore := time.Now().Add(-job.Retention)
+
+	books, err := job.BookRepository.Purge(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+	users, err := job.UserRepository.Purge(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+	if books > 0 || users > 0 {
+		slog.InfoContext(ctx, "Purged deleted records", slog.String("job", job.Name()),
+			slog.Int64("books", books), slog.Int64("users", users), slog.Time("deleted_before", deletedBefore))
+	}
+	return nil
+}
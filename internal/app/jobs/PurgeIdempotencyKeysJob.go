@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+)
+
+// PurgeIdempotencyKeysJob удаляет ответы на запросы с Idempotency-Key, которые хранятся дольше TTL
+type PurgeIdempotencyKeysJob struct {
+	IdempotencyRepository repository.IdempotencyRepository
+	TTL                   time.Duration
+}
+
+func NewPurgeIdempotencyKeysJob(idempotencyRepository repository.IdempotencyRepository,
+	ttl time.Duration) *PurgeIdempotencyKeysJob {
+	return &PurgeIdempotencyKeysJob{IdempotencyRepository: idempotencyRepository, TTL: ttl}
+}
+
+func (job *PurgeIdempotencyKeysJob) Name() string {
+	return "idempotency.purge"
+}
+
+func (job *PurgeIdempotencyKeysJob) Run(ctx context.Context) error {
+	createdBefore := time.Now().Add(-job.TTL)
+
+	keys, err := job.IdempotencyRepository.Purge(ctx, createdBefore)
+	if err != nil {
+		return err
+	}
+	if keys > 0 {
+		slog.InfoContext(ctx, "Purged idempotency keys", slog.String("job", job.Name()),
+			slog.Int64("keys", keys), slog.Time("created_before", createdBefore))
+	}
+	return nil
+}
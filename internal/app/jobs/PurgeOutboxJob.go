@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+)
+
+// PurgeOutboxJob удаляет события, отправленные больше Retention назад
+type PurgeOutboxJob struct {
+	OutboxRepository repository.OutboxRepository
+	Retention        time.Duration
+}
+
+func NewPurgeOutboxJob(outboxRepository repository.OutboxRepository, retention time.Duration) *PurgeOutboxJob {
+	return &PurgeOutboxJob{OutboxRepository: outboxRepository, Retention: retention}
+}
+
+func (job *PurgeOutboxJob) Name() string {
+	return "outbox.purge"
+}
+
+func (job *PurgeOutboxJob) Run(ctx context.Context) error {
+	publishedBefore := time.Now().Add(-job.Retention)
+
+	purged, err := job.OutboxRepository.Purge(ctx, publishedBefore)
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "Purged domain events", slog.String("job", job.Name()),
+			slog.Int64("events", purged), slog.Time("published_before", publishedBefore))
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+)
+
+type RefreshStatsJob struct {
+	StatsRepository repository.StatsRepository
+}
+
+func NewRefreshStatsJob(statsRepository repository.StatsRepository) *RefreshStatsJob {
+	return &RefreshStatsJob{StatsRepository: statsRepository}
+}
+
+func (job *RefreshStatsJob) Name() string {
+	return "stats.refresh"
+}
+
+func (job *RefreshStatsJob) Run(ctx context.Context) error {
+	return job.StatsRepository.Refresh(ctx)
+}
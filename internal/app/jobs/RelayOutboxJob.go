@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	"github.com/Ablyamitov/simple-rest/internal/store/events"
+)
+
+// RelayOutboxJob отправляет накопившиеся в outbox события в Sink пачками по BatchSize, пока они не кончатся.
+// Пачка занята на Lease, чтобы другие экземпляры её не отправили. Событие, которое Sink не принял,
+// остаётся первым в очереди и отправляется снова при следующем запуске
+type RelayOutboxJob struct {
+	OutboxRepository repository.OutboxRepository
+	Sink             events.Sink
+	BatchSize        int
+	Lease            time.Duration
+}
+
+func NewRelayOutboxJob(outboxRepository repository.OutboxRepository, sink events.Sink, batchSize int,
+	lease time.Duration) *RelayOutboxJob {
+	return &RelayOutboxJob{OutboxRepository: outboxRepository, Sink: sink, BatchSize: batchSize, Lease: lease}
+}
+
+func (job *RelayOutboxJob) Name() string {
+	return "outbox.relay"
+}
+
+// Quiet - задача запускается каждые несколько секунд и сама пишет в лог, когда что-то отправила
+func (job *RelayOutboxJob) Quiet() bool {
+	return true
+}
+
+func (job *RelayOutboxJob) Run(ctx context.Context) error {
+	total := 0
+	for {
+		published, err := job.OutboxRepository.Relay(ctx, job.BatchSize, job.Lease, job.Sink.Publish)
+		total += published
+		if err != nil {
+			return fmt.Errorf("relayed %d events before failure: %w", total, err)
+		}
+		if published < job.BatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		slog.InfoContext(ctx, "Relayed domain events", slog.String("job", job.Name()), slog.Int("events", total))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	mock "github.com/Ablyamitov/simple-rest/internal/store/db/repository/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct {
+	events []entity.OutboxEvent
+}
+
+func (sink *recordingSink) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	sink.events = append(sink.events, event)
+	return nil
+}
+
+// relayed отдаёт publish события с id из ids, как OutboxRepository.Relay
+func relayed(ids ...int64) func(ctx context.Context, limit int, lease time.Duration,
+	publish func(context.Context, entity.OutboxEvent) error) (int, error) {
+	return func(ctx context.Context, limit int, lease time.Duration, publish func(context.Context, entity.OutboxEvent) error) (int, error) {
+		for _, id := range ids {
+			if err := publish(ctx, entity.OutboxEvent{ID: id, Type: entity.EventBookCreated}); err != nil {
+				return 0, err
+			}
+		}
+		return len(ids), nil
+	}
+}
+
+func TestRelayOutboxJob_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repository := mock.NewMockOutboxRepository(ctrl)
+	sink := &recordingSink{}
+	job := NewRelayOutboxJob(repository, sink, 2, time.Minute)
+
+	// Полная пачка значит, что в очереди могут остаться события
+	gomock.InOrder(
+		repository.EXPECT().Relay(gomock.Any(), 2, time.Minute, gomock.Any()).DoAndReturn(relayed(1, 2)),
+		repository.EXPECT().Relay(gomock.Any(), 2, time.Minute, gomock.Any()).DoAndReturn(relayed(3)),
+	)
+	assert.NoError(t, job.Run(context.Background()))
+	assert.Len(t, sink.events, 3)
+
+	failure := errors.New("connection refused")
+	gomock.InOrder(
+		repository.EXPECT().Relay(gomock.Any(), 2, time.Minute, gomock.Any()).DoAndReturn(relayed(4, 5)),
+		repository.EXPECT().Relay(gomock.Any(), 2, time.Minute, gomock.Any()).Return(1, failure),
+	)
+	err := job.Run(context.Background())
+	assert.ErrorIs(t, err, failure)
+	assert.ErrorContains(t, err, "relayed 3 events")
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+)
+
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// QuietJob реализуют частые задачи, чтобы планировщик не писал в лог каждый их успешный запуск
+type QuietJob interface {
+	Job
+	Quiet() bool
+}
+
+type Scheduler interface {
+	Add(job Job, interval time.Duration)
+	Start(ctx context.Context)
+	Stop()
+}
+
+type scheduledJob struct {
+	job      Job
+	interval time.Duration
+}
+
+type SchedulerImpl struct {
+	jobs   []scheduledJob
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler() Scheduler {
+	return &SchedulerImpl{}
+}
+
+func (scheduler *SchedulerImpl) Add(job Job, interval time.Duration) {
+	scheduler.jobs = append(scheduler.jobs, scheduledJob{job: job, interval: interval})
+}
+
+// Start запускает каждую задачу сразу и затем с её интервалом, пока не отменён ctx или не вызван Stop
+func (scheduler *SchedulerImpl) Start(ctx context.Context) {
+	ctx, scheduler.cancel = context.WithCancel(ctx)
+	for _, scheduled := range scheduler.jobs {
+		if scheduled.interval <= 0 {
+			slog.InfoContext(ctx, "Job is disabled", slog.String("job", scheduled.job.Name()))
+			continue
+		}
+		scheduler.wg.Add(1)
+		go func(scheduled scheduledJob) {
+			defer scheduler.wg.Done()
+			scheduler.loop(ctx, scheduled)
+		}(scheduled)
+	}
+}
+
+func (scheduler *SchedulerImpl) Stop() {
+	if scheduler.cancel != nil {
+		scheduler.cancel()
+	}
+	scheduler.wg.Wait()
+}
+
+func (scheduler *SchedulerImpl) loop(ctx context.Context, scheduled scheduledJob) {
+	ticker := time.NewTicker(scheduled.interval)
+	defer ticker.Stop()
+
+	for {
+		run(ctx, scheduled.job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func run(ctx context.Context, job Job) {
+	started := time.Now()
+	if err := job.Run(ctx); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		wrapper.LogError(ctx, fmt.Sprintf("Job %s failed: %v", job.Name(), err), "jobs.run")
+		return
+	}
+	if quiet, ok := job.(QuietJob); ok && quiet.Quiet() {
+		return
+	}
+	slog.InfoContext(ctx, "Job finished", slog.String("job", job.Name()),
+		slog.Duration("duration", time.Since(started).Round(time.Millisecond)))
+}
@@ -0,0 +1,59 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type step struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Lifecycle останавливает компоненты приложения в порядке добавления.
+// Ошибка одного шага не мешает остальным: пул и соединения закрываются в любом случае
+type Lifecycle struct {
+	steps []step
+}
+
+func New() *Lifecycle {
+	return &Lifecycle{}
+}
+
+func (lifecycle *Lifecycle) Add(name string, stop func(ctx context.Context) error) {
+	lifecycle.steps = append(lifecycle.steps, step{name: name, stop: stop})
+}
+
+// AddFunc добавляет шаг без контекста; если он не уложился в срок, Shutdown идёт дальше, не дожидаясь его
+func (lifecycle *Lifecycle) AddFunc(name string, stop func()) {
+	lifecycle.Add(name, func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			stop()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Shutdown выполняет все шаги, пока не истечёт ctx, и возвращает их ошибки
+func (lifecycle *Lifecycle) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, step := range lifecycle.steps {
+		started := time.Now()
+		if err := step.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+			continue
+		}
+		slog.Info("Stopped", slog.String("component", step.name), slog.Duration("duration", time.Since(started)))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,38 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycle_Shutdown(t *testing.T) {
+	var stopped []string
+	lifecycle := New()
+	lifecycle.Add("http", func(ctx context.Context) error {
+		stopped = append(stopped, "http")
+		return errors.New("requests still running")
+	})
+	lifecycle.AddFunc("jobs", func() {
+		stopped = append(stopped, "jobs")
+	})
+	lifecycle.AddFunc("stuck", func() {
+		time.Sleep(200 * time.Millisecond)
+	})
+	lifecycle.Add("db", func(ctx context.Context) error {
+		stopped = append(stopped, "db")
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := lifecycle.Shutdown(ctx)
+
+	// Следующие шаги выполняются даже после ошибки и зависшего шага
+	assert.Equal(t, []string{"http", "jobs", "db"}, stopped)
+	assert.ErrorContains(t, err, "http: requests still running")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
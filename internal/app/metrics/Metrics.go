@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+)
+
+// DBPoolStats - снимок состояния пула соединений с базой
+type DBPoolStats struct {
+	TotalConns    int32
+	IdleConns     int32
+	AcquiredConns int32
+	MaxConns      int32
+	AcquireCount  int64
+	AcquireWait   time.Duration
+}
+
+// Metrics - всё, что сервис сообщает о своей работе. Код пишет в интерфейс,
+// поэтому его можно проверить подменной реализацией, не разбирая вывод /metrics
+type Metrics interface {
+	ObserveRequest(method string, route string, status int, duration time.Duration)
+	CacheHit(cache string)
+	CacheMiss(cache string)
+	LoginFailed(reason string)
+	SetLoans(active int, overdue int)
+	// ObserveDBPool регистрирует источник статистики пула, который опрашивается при каждом сборе метрик
+	ObserveDBPool(stats func() DBPoolStats)
+	Handler() http.Handler
+}
+
+// NoopMetrics ничего не собирает; подходит для тестов и запуска без мониторинга
+type NoopMetrics struct{}
+
+func NewNoopMetrics() Metrics {
+	return NoopMetrics{}
+}
+
+func (NoopMetrics) ObserveRequest(string, string, int, time.Duration) {}
+func (NoopMetrics) CacheHit(string)                                   {}
+func (NoopMetrics) CacheMiss(string)                                  {}
+func (NoopMetrics) LoginFailed(string)                                {}
+func (NoopMetrics) SetLoans(int, int)                                 {}
+func (NoopMetrics) ObserveDBPool(func() DBPoolStats)                  {}
+
+func (NoopMetrics) Handler() http.Handler {
+	return http.NotFoundHandler()
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "simple_rest"
+
+type PrometheusMetrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	cacheRequests   *prometheus.CounterVec
+	loginFailures   *prometheus.CounterVec
+	loans           *prometheus.GaugeVec
+}
+
+func NewPrometheusMetrics() Metrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	prometheusMetrics := &PrometheusMetrics{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Entity cache lookups by cache and result (hit or miss); stale hits count as hits.",
+		}, []string{"cache", "result"}),
+		loginFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "login_failures_total",
+			Help:      "Failed logins by reason.",
+		}, []string{"reason"}),
+		loans: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "loans",
+			Help:      "Open loans by state (active or overdue).",
+		}, []string{"state"}),
+	}
+	registry.MustRegister(prometheusMetrics.requests, prometheusMetrics.requestDuration,
+		prometheusMetrics.cacheRequests, prometheusMetrics.loginFailures, prometheusMetrics.loans)
+	return prometheusMetrics
+}
+
+func (prometheusMetrics *PrometheusMetrics) ObserveRequest(method string, route string, status int, duration time.Duration) {
+	prometheusMetrics.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	prometheusMetrics.requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+func (prometheusMetrics *PrometheusMetrics) CacheHit(cache string) {
+	prometheusMetrics.cacheRequests.WithLabelValues(cache, "hit").Inc()
+}
+
+func (prometheusMetrics *PrometheusMetrics) CacheMiss(cache string) {
+	prometheusMetrics.cacheRequests.WithLabelValues(cache, "miss").Inc()
+}
+
+func (prometheusMetrics *PrometheusMetrics) LoginFailed(reason string) {
+	prometheusMetrics.loginFailures.WithLabelValues(reason).Inc()
+}
+
+func (prometheusMetrics *PrometheusMetrics) SetLoans(active int, overdue int) {
+	prometheusMetrics.loans.WithLabelValues("active").Set(float64(active))
+	prometheusMetrics.loans.WithLabelValues("overdue").Set(float64(overdue))
+}
+
+func (prometheusMetrics *PrometheusMetrics) ObserveDBPool(stats func() DBPoolStats) {
+	gauge := func(name, help string, value func(DBPoolStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "db_pool",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(stats()) })
+	}
+	counter := func(name, help string, value func(DBPoolStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db_pool",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(stats()) })
+	}
+
+	prometheusMetrics.registry.MustRegister(
+		gauge("total_conns", "Connections currently in the pool.",
+			func(s DBPoolStats) float64 { return float64(s.TotalConns) }),
+		gauge("idle_conns", "Idle connections in the pool.",
+			func(s DBPoolStats) float64 { return float64(s.IdleConns) }),
+		gauge("acquired_conns", "Connections currently in use.",
+			func(s DBPoolStats) float64 { return float64(s.AcquiredConns) }),
+		gauge("max_conns", "Maximum size of the pool.",
+			func(s DBPoolStats) float64 { return float64(s.MaxConns) }),
+		counter("acquires_total", "Connections acquired from the pool.",
+			func(s DBPoolStats) float64 { return float64(s.AcquireCount) }),
+		counter("acquire_wait_seconds_total", "Time spent waiting for a free connection.",
+			func(s DBPoolStats) float64 { return s.AcquireWait.Seconds() }),
+	)
+}
+
+func (prometheusMetrics *PrometheusMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(prometheusMetrics.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	m := NewPrometheusMetrics().(*PrometheusMetrics)
+
+	m.ObserveRequest(http.MethodGet, "/api/v1/books/{id}", http.StatusOK, 20*time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "/api/v1/books/{id}", http.StatusOK, 30*time.Millisecond)
+	m.CacheHit("book")
+	m.CacheMiss("book")
+	m.CacheMiss("book")
+	m.LoginFailed("invalid_password")
+	m.SetLoans(4, 1)
+	m.ObserveDBPool(func() DBPoolStats { return DBPoolStats{TotalConns: 3, IdleConns: 2, AcquiredConns: 1, MaxConns: 10} })
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, "/api/v1/books/{id}", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.cacheRequests.WithLabelValues("book", "hit")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.cacheRequests.WithLabelValues("book", "miss")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.loginFailures.WithLabelValues("invalid_password")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.loans.WithLabelValues("overdue")))
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "simple_rest_db_pool_acquired_conns 1"))
+	assert.True(t, strings.Contains(w.Body.String(), `simple_rest_http_request_duration_seconds_count{method="GET",route="/api/v1/books/{id}"} 2`))
+}
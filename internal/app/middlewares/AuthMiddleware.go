@@ -1,16 +1,24 @@
 package middlewares
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/Ablyamitov/simple-rest/internal/app/utils"
 	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
 )
 
+type claimsContextKey struct{}
+
 var (
 	errEmptyToken    = errors.New("authentication failed, because token is empty")
 	errTokenNotValid = errors.New("token is not valid")
+	errAccessDenied  = errors.New("role does not have permission")
 )
 
 func IsAuthorized(secret string) func(http.Handler) http.Handler {
@@ -18,19 +26,52 @@ func IsAuthorized(secret string) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			bearerToken := r.Header.Get("Authorization")
 			if bearerToken == "" {
-				wrapper.LogError(errEmptyToken.Error(), "middleware.IsAuthorized")
-				http.Error(w, errEmptyToken.Error(), http.StatusBadRequest)
+				wrapper.WriteError(w, r, http.StatusUnauthorized, errEmptyToken, "middleware.IsAuthorized")
 				return
 			}
 			token := bearerToken[7:]
 			claims, err := utils.ParseToken(token, secret)
 			if err != nil {
-				wrapper.LogError(errTokenNotValid.Error(), "middleware.IsAuthorized")
-				http.Error(w, errTokenNotValid.Error(), http.StatusUnauthorized)
+				wrapper.WriteError(w, r, http.StatusUnauthorized, errTokenNotValid, "middleware.IsAuthorized")
 				return
 			}
 			w.Header().Add("role", claims.Role)
+			wrapper.AddLogAttrs(r.Context(), slog.Int("user_id", claims.UserID), slog.String("role", claims.Role))
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// HasRole пропускает запрос дальше, только если роль из токена входит в roles.
+// Должен стоять после IsAuthorized.
+func HasRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok || !slices.Contains(roles, claims.Role) {
+				wrapper.WriteError(w, r, http.StatusForbidden, errAccessDenied, "middleware.HasRole")
+				return
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+func WithClaims(ctx context.Context, claims *entity.Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+func ClaimsFromContext(ctx context.Context) (*entity.Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*entity.Claims)
+	return claims, ok && claims != nil
+}
+
+// bearerClaims разбирает токен запроса для middleware, которые стоят до IsAuthorized и обходятся без него
+func bearerClaims(r *http.Request, secret string) (*entity.Claims, bool) {
+	bearerToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, false
+	}
+	claims, err := utils.ParseToken(bearerToken, secret)
+	return claims, err == nil
+}
@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE"
+	corsAllowedHeaders = "Authorization, Content-Type, If-Match, If-None-Match, Idempotency-Key, X-API-Key, X-Request-ID"
+	// Заголовки ответа, которые скрипт на другом источнике иначе не увидит
+	corsExposedHeaders = "ETag, Location, Link, Deprecation, Sunset, Retry-After, RateLimit-Limit, RateLimit-Remaining, " +
+		"RateLimit-Reset, Idempotent-Replayed, X-Request-ID"
+	corsMaxAge = 10 * 60
+)
+
+// CORS разрешает браузерам запросы со страниц из списка источников, "*" разрешает любой.
+// Токен передаётся в заголовке, а не в cookie, поэтому учётные данные браузера не разрешаются.
+// Пустой список выключает CORS: ответы не получают заголовков Access-Control-*
+type CORS struct {
+	origins atomic.Pointer[[]string]
+}
+
+func NewCORS(origins []string) *CORS {
+	cors := &CORS{}
+	cors.SetOrigins(origins)
+	return cors
+}
+
+// SetOrigins меняет список разрешённых источников на лету
+func (cors *CORS) SetOrigins(origins []string) {
+	origins = slices.Clone(origins)
+	cors.origins.Store(&origins)
+}
+
+// Handler отвечает на preflight-запросы сам, не пропуская их к ограничению запросов и авторизации
+func (cors *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Ответ зависит от Origin, и кеши не должны отдавать его другому источнику
+		w.Header().Add("Vary", "Origin")
+		origins := *cors.origins.Load()
+		if !slices.Contains(origins, "*") && !slices.ContainsFunc(origins, func(allowed string) bool {
+			return strings.EqualFold(allowed, origin)
+		}) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	cors := NewCORS([]string{"https://library.example.com"})
+	r := chi.NewRouter()
+	r.Use(cors.Handler)
+	r.Get("/books", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	serve := func(method string, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/books", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodGet, "https://library.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://library.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "ETag")
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	// Preflight не доходит до маршрутов, у которых нет OPTIONS
+	w = serve(http.MethodOptions, "https://library.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPatch)
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Idempotency-Key")
+
+	// Чужой источник получает ответ без разрешения, и браузер его не отдаст
+	w = serve(http.MethodGet, "https://evil.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodOptions, "https://evil.example.com").Code)
+
+	w = serve(http.MethodGet, "")
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Vary"))
+
+	// Новый список действует без перезапуска
+	cors.SetOrigins([]string{"*"})
+	w = serve(http.MethodGet, "https://evil.example.com")
+	assert.Equal(t, "https://evil.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	cors.SetOrigins(nil)
+	assert.Empty(t, serve(http.MethodGet, "https://library.example.com").Header().Get("Access-Control-Allow-Origin"))
+}
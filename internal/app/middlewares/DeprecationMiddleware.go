@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Deprecation описывает сроки жизни устаревших маршрутов
+type Deprecation struct {
+	DeprecatedAt time.Time
+	SunsetAt     time.Time
+}
+
+var routeParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// Deprecated помечает ответ заголовками Deprecation (RFC 9745) и Sunset (RFC 8594)
+// и ссылается на замену; параметры вида {id} в successor подставляются из пути запроса.
+// Ставится через With, чтобы параметры маршрута были уже разобраны.
+func Deprecated(deprecation Deprecation, successor string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			link := routeParam.ReplaceAllStringFunc(successor, func(param string) string {
+				return chi.URLParam(r, param[1:len(param)-1])
+			})
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecation.DeprecatedAt.Unix()))
+			if !deprecation.SunsetAt.IsZero() {
+				w.Header().Set("Sunset", deprecation.SunsetAt.UTC().Format(http.TimeFormat))
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, link))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecated(t *testing.T) {
+	deprecation := Deprecation{
+		DeprecatedAt: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		SunsetAt:     time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC),
+	}
+
+	r := chi.NewRouter()
+	r.With(Deprecated(deprecation, "/api/v1/books/{id}/reviews/{reviewId}")).
+		Patch("/books/{id}/reviews/{reviewId}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+	req := httptest.NewRequest(http.MethodPatch, "/books/7/reviews/3", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "@1792368000", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Mon, 19 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1/books/7/reviews/3>; rel="successor-version"`, w.Header().Get("Link"))
+}
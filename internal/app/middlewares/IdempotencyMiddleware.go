@@ -0,0 +1,166 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	anonymousIdempotencyScope = "anonymous"
+)
+
+// IdempotencyOptions - сколько хранится ответ, через сколько незавершённый запрос считается брошенным
+// и сколько байт тела читается, чтобы сравнить повтор с исходным запросом
+type IdempotencyOptions struct {
+	TTL         time.Duration
+	LockTimeout time.Duration
+	MaxBody     int64
+}
+
+// Ключ пишется в базу и в лог, поэтому допускаются только видимые символы ASCII
+var idempotencyKeyPattern = regexp.MustCompile(`^[!-~]{1,255}$`)
+
+var (
+	errInvalidIdempotencyKey    = errors.New("idempotency key must be 1 to 255 visible ASCII characters")
+	errIdempotencyKeyReused     = errors.New("idempotency key has already been used with a different request")
+	errIdempotencyKeyInProgress = errors.New("request with this idempotency key is still in progress, retry later")
+	errRequestTooLarge          = errors.New("request body is too large")
+)
+
+// Idempotency выполняет POST, PUT, PATCH и DELETE с заголовком Idempotency-Key один раз: повтор с тем же ключом
+// получает записанный ответ с заголовком Idempotent-Replayed, повтор с другим методом, путём или телом - 422,
+// а повтор, пока первый запрос ещё выполняется, - 409. Ключи разных пользователей не пересекаются.
+// Ответы 5xx не записываются, чтобы повтор выполнил запрос заново
+func Idempotency(idempotencyRepository repository.IdempotencyRepository, options IdempotencyOptions,
+	secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := r.Header[IdempotencyKeyHeader]
+			if !ok || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) != 1 || !idempotencyKeyPattern.MatchString(key[0]) {
+				wrapper.WriteError(w, r, http.StatusBadRequest, errInvalidIdempotencyKey, "middleware.Idempotency")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, options.MaxBody))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					wrapper.WriteError(w, r, http.StatusRequestEntityTooLarge, errRequestTooLarge, "middleware.Idempotency")
+				} else {
+					wrapper.WriteError(w, r, http.StatusBadRequest, err, "middleware.Idempotency")
+				}
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			record := &entity.IdempotencyKey{
+				Scope:       anonymousIdempotencyScope,
+				Key:         key[0],
+				Fingerprint: fingerprint(r, body),
+			}
+			if claims, ok := bearerClaims(r, secret); ok {
+				record.Scope = "user:" + strconv.Itoa(claims.UserID)
+			}
+			wrapper.AddLogAttrs(r.Context(), slog.String("idempotency_key", record.Key))
+
+			existing, claimed, err := idempotencyRepository.Claim(r.Context(), record, options.TTL, options.LockTimeout)
+			if err != nil {
+				wrapper.WriteError(w, r, http.StatusInternalServerError, err, "middleware.Idempotency")
+				return
+			}
+			if !claimed {
+				switch {
+				case existing.Fingerprint != record.Fingerprint:
+					wrapper.WriteError(w, r, http.StatusUnprocessableEntity, errIdempotencyKeyReused, "middleware.Idempotency")
+				case existing.CompletedAt == nil:
+					w.Header().Set("Retry-After", "1")
+					wrapper.WriteError(w, r, http.StatusConflict, errIdempotencyKeyInProgress, "middleware.Idempotency")
+				default:
+					replay(w, existing)
+				}
+				return
+			}
+
+			// Ответ записывается и после отключения клиента: именно он и придёт повторить запрос
+			ctx := context.WithoutCancel(r.Context())
+			// Без ответа ключ освобождается, например после паники обработчика
+			finished := false
+			defer func() {
+				if finished {
+					return
+				}
+				if err := idempotencyRepository.Release(ctx, record.Scope, record.Key); err != nil {
+					wrapper.LogError(ctx, err.Error(), "middleware.Idempotency")
+				}
+			}()
+
+			before := w.Header().Clone()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			var response bytes.Buffer
+			ww.Tee(&response)
+
+			next.ServeHTTP(ww, r)
+
+			record.Status = ww.Status()
+			if record.Status == 0 {
+				record.Status = http.StatusOK
+			}
+			if record.Status >= http.StatusInternalServerError {
+				return
+			}
+			// Запрос выполнен: даже если ответ не запишется, ключ останется занятым до LockTimeout,
+			// а не освободится для мгновенного повтора
+			finished = true
+			// Заголовки, выставленные до этого middleware, при повторе выставятся снова
+			record.Headers = make(map[string][]string)
+			for name, values := range w.Header() {
+				if !slices.Equal(before[name], values) {
+					record.Headers[name] = values
+				}
+			}
+			record.Body = response.Bytes()
+			if err := idempotencyRepository.Complete(ctx, record); err != nil {
+				wrapper.LogError(ctx, err.Error(), "middleware.Idempotency")
+			}
+		})
+	}
+}
+
+// fingerprint отличает повтор того же запроса от другого запроса с тем же ключом
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, record *entity.IdempotencyKey) {
+	for name, values := range record.Headers {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	_, _ = w.Write(record.Body)
+}
@@ -0,0 +1,140 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryIdempotencyRepository хранит ключи в памяти и не учитывает сроки
+type memoryIdempotencyRepository struct {
+	mu   sync.Mutex
+	keys map[string]entity.IdempotencyKey
+}
+
+func (repository *memoryIdempotencyRepository) Claim(ctx context.Context, key *entity.IdempotencyKey,
+	ttl time.Duration, lockTimeout time.Duration) (*entity.IdempotencyKey, bool, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+	if existing, ok := repository.keys[key.Scope+" "+key.Key]; ok {
+		return &existing, false, nil
+	}
+	repository.keys[key.Scope+" "+key.Key] = *key
+	return nil, true, nil
+}
+
+func (repository *memoryIdempotencyRepository) Complete(ctx context.Context, key *entity.IdempotencyKey) error {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+	completedAt := time.Now()
+	key.CompletedAt = &completedAt
+	repository.keys[key.Scope+" "+key.Key] = *key
+	return nil
+}
+
+func (repository *memoryIdempotencyRepository) Release(ctx context.Context, scope string, key string) error {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+	delete(repository.keys, scope+" "+key)
+	return nil
+}
+
+func (repository *memoryIdempotencyRepository) Purge(ctx context.Context, createdBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestIdempotency(t *testing.T) {
+	repository := &memoryIdempotencyRepository{keys: make(map[string]entity.IdempotencyKey)}
+	calls := 0
+	status := http.StatusCreated
+	r := chi.NewRouter()
+	r.Use(JsonContentType)
+	r.Use(Idempotency(repository, IdempotencyOptions{TTL: time.Hour, LockTimeout: time.Minute, MaxBody: 64},
+		rateLimitSecret))
+	r.Post("/loans", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", "/api/v1/loans/1")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	})
+	r.Get("/loans/{id}", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &entity.Claims{UserID: 7, Role: "user"}).
+		SignedString([]byte(rateLimitSecret))
+	require.NoError(t, err)
+	serve := func(method string, key string, body string, authorized bool) *httptest.ResponseRecorder {
+		path := "/loans"
+		if method == http.MethodGet {
+			path = "/loans/1"
+		}
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		if authorized {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "take-1", `{"book_id":1}`, true)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+
+	// Повтор получает тот же ответ, а обработчик не вызывается
+	w = serve(http.MethodPost, "take-1", `{"book_id":1}`, true)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"id":1}`, w.Body.String())
+	assert.Equal(t, "/api/v1/loans/1", w.Header().Get("Location"))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 1, calls)
+
+	w = serve(http.MethodPost, "take-1", `{"book_id":2}`, true)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "different request")
+
+	// Тот же ключ другого клиента - другой запрос
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "take-1", `{"book_id":2}`, false).Code)
+	assert.Equal(t, 2, calls)
+
+	// Ответ 5xx не записывается, и повтор выполняет запрос заново
+	status = http.StatusServiceUnavailable
+	assert.Equal(t, http.StatusServiceUnavailable, serve(http.MethodPost, "take-2", `{}`, true).Code)
+	status = http.StatusCreated
+	w = serve(http.MethodPost, "take-2", `{}`, true)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 4, calls)
+
+	// Первый запрос с ключом ещё выполняется
+	repository.keys["user:7 take-3"] = entity.IdempotencyKey{Scope: "user:7", Key: "take-3",
+		Fingerprint: fingerprint(httptest.NewRequest(http.MethodPost, "/loans", nil), []byte(`{}`))}
+	w = serve(http.MethodPost, "take-3", `{}`, true)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "take 4", `{}`, true).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge,
+		serve(http.MethodPost, "take-5", `{"comment":"`+strings.Repeat("x", 64)+`"}`, true).Code)
+
+	// Безопасные методы ключ не учитывают
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "take-1", "", true).Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "take-1", "", true).Code)
+	assert.Equal(t, 6, calls)
+}
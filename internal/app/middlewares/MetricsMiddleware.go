@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Metrics считает запросы по шаблону маршрута chi, а не по пути, чтобы id не раздували число серий.
+// Запросы мимо маршрутов попадают в одну серию unmatched
+func Metrics(m metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			started := time.Now()
+
+			next.ServeHTTP(ww, r)
+
+			route := "unmatched"
+			if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+				route = routeContext.RoutePattern()
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.ObserveRequest(r.Method, route, status, time.Since(started))
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type observedRequest struct {
+	method string
+	route  string
+	status int
+}
+
+type fakeMetrics struct {
+	metrics.NoopMetrics
+	requests []observedRequest
+}
+
+func (m *fakeMetrics) ObserveRequest(method string, route string, status int, _ time.Duration) {
+	m.requests = append(m.requests, observedRequest{method: method, route: route, status: status})
+}
+
+func TestMetrics(t *testing.T) {
+	m := &fakeMetrics{}
+	r := chi.NewRouter()
+	r.Use(Metrics(m))
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Get("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+		r.Post("/loans", func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	for _, target := range []string{"/api/v1/books/1", "/api/v1/books/2", "/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/loans", nil))
+
+	assert.Equal(t, []observedRequest{
+		{method: http.MethodGet, route: "/api/v1/books/{id}", status: http.StatusNotFound},
+		{method: http.MethodGet, route: "/api/v1/books/{id}", status: http.StatusNotFound},
+		{method: http.MethodGet, route: "unmatched", status: http.StatusNotFound},
+		{method: http.MethodPost, route: "/api/v1/loans", status: http.StatusOK},
+	}, m.requests)
+}
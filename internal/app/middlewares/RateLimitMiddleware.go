@@ -0,0 +1,163 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/store/ratelimit"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RateLimits - ограничения по IP, по пользователю из токена и по ключу из X-API-Key.
+// Запрос проверяется всеми ограничениями, которые к нему относятся.
+// Routes заменяет их для маршрутов вида "POST /api/v1/auth/login" и считает такие запросы отдельно.
+// Устаревший маршрут без /api/v1 делит ограничение и счётчик с версионным, как бы он ни был записан
+type RateLimits struct {
+	IP     ratelimit.Limit
+	User   ratelimit.Limit
+	APIKey ratelimit.Limit
+	Routes map[string]ratelimit.Limit
+	// TrustForwardedFor берёт IP клиента из последнего адреса X-Forwarded-For, его добавляет прокси перед сервисом
+	TrustForwardedFor bool
+}
+
+// rateLimitCheck - одно ограничение, которому подлежит запрос
+type rateLimitCheck struct {
+	kind  string
+	id    string
+	limit ratelimit.Limit
+}
+
+var errTooManyRequests = errors.New("too many requests, retry later")
+
+const apiPrefix = "/api/v1"
+
+// RateLimiter отвечает 429, когда клиент превысил любое из своих ограничений, и сообщает остаток самого строгого
+// в заголовках RateLimit-Limit, RateLimit-Remaining и RateLimit-Reset. Если лимитер недоступен, запрос пропускается.
+// Токен разбирается здесь же, а не в IsAuthorized, чтобы ограничение срабатывало раньше дорогих обработчиков
+type RateLimiter struct {
+	limiter ratelimit.Limiter
+	secret  string
+	rules   atomic.Pointer[rateLimitRules]
+}
+
+// rateLimitRules - ограничения вместе с роутером, который находит маршрут из Routes
+type rateLimitRules struct {
+	RateLimits
+	routes *chi.Mux
+}
+
+func NewRateLimiter(limiter ratelimit.Limiter, limits RateLimits, secret string) *RateLimiter {
+	rateLimiter := &RateLimiter{limiter: limiter, secret: secret}
+	rateLimiter.SetLimits(limits)
+	return rateLimiter
+}
+
+// SetLimits меняет ограничения на лету; уже накопленные счётчики клиентов сохраняются
+func (rateLimiter *RateLimiter) SetLimits(limits RateLimits) {
+	canonical := make(map[string]ratelimit.Limit, len(limits.Routes))
+	for route, limit := range limits.Routes {
+		method, pattern, _ := strings.Cut(route, " ")
+		canonical[method+" "+apiRoute(pattern)] = limit
+	}
+	routes := chi.NewRouter()
+	for route := range canonical {
+		method, pattern, _ := strings.Cut(route, " ")
+		routes.MethodFunc(method, pattern, http.NotFound)
+		if legacy := strings.TrimPrefix(pattern, apiPrefix); legacy != "" {
+			routes.MethodFunc(method, legacy, http.NotFound)
+		}
+	}
+	limits.Routes = canonical
+	rateLimiter.rules.Store(&rateLimitRules{RateLimits: limits, routes: routes})
+}
+
+func (rateLimiter *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits := rateLimiter.rules.Load()
+		scope, ipLimit, userLimit, apiKeyLimit := "all", limits.IP, limits.User, limits.APIKey
+		routeContext := chi.NewRouteContext()
+		if limits.routes.Match(routeContext, r.Method, r.URL.Path) {
+			// Иначе, чередуя /auth/login и /api/v1/auth/login, клиент получил бы вдвое больше попыток
+			scope = r.Method + " " + apiRoute(routeContext.RoutePattern())
+			ipLimit, userLimit, apiKeyLimit = limits.Routes[scope], limits.Routes[scope], limits.Routes[scope]
+		}
+
+		checks := []rateLimitCheck{{kind: "ip", id: clientIP(r, limits.TrustForwardedFor), limit: ipLimit}}
+		if claims, ok := bearerClaims(r, rateLimiter.secret); ok {
+			checks = append(checks, rateLimitCheck{kind: "user", id: strconv.Itoa(claims.UserID), limit: userLimit})
+		}
+		if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+			// Сам ключ в Redis не попадает
+			hash := sha256.Sum256([]byte(apiKey))
+			checks = append(checks, rateLimitCheck{kind: "key", id: hex.EncodeToString(hash[:16]), limit: apiKeyLimit})
+		}
+
+		var tightest *ratelimit.Result
+		for _, check := range checks {
+			if check.limit.Disabled() {
+				continue
+			}
+			result, err := rateLimiter.limiter.Allow(r.Context(), "ratelimit:"+scope+":"+check.kind+":"+check.id, check.limit)
+			if err != nil {
+				slog.WarnContext(r.Context(), "Rate limit check failed", slog.String("error", err.Error()))
+				continue
+			}
+			if tightest == nil || !result.Allowed || result.Remaining < tightest.Remaining {
+				tightest = &result
+			}
+			if !result.Allowed {
+				break
+			}
+		}
+		if tightest == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(tightest.ResetAfter)))
+		if !tightest.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(max(seconds(tightest.RetryAfter), 1)))
+			wrapper.WriteError(w, r, http.StatusTooManyRequests, errTooManyRequests, "middleware.RateLimit")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiRoute приводит шаблон устаревшего маршрута к версионному: "/auth/login" -> "/api/v1/auth/login"
+func apiRoute(pattern string) string {
+	return apiPrefix + strings.TrimPrefix(pattern, apiPrefix)
+}
+
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if forwarded := r.Header.Values("X-Forwarded-For"); trustForwardedFor && len(forwarded) > 0 {
+		addresses := strings.Split(forwarded[len(forwarded)-1], ",")
+		if ip := strings.TrimSpace(addresses[len(addresses)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// seconds округляет вверх, чтобы клиент не повторил запрос раньше времени
+func seconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/ratelimit"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rateLimitSecret = "rate-limit-test-secret-at-least-32-chars"
+
+func TestRateLimit(t *testing.T) {
+	limits := RateLimits{
+		IP:   ratelimit.Limit{Rate: 60, Period: time.Minute, Burst: 3},
+		User: ratelimit.Limit{Rate: 60, Period: time.Minute, Burst: 1},
+		Routes: map[string]ratelimit.Limit{
+			"POST /auth/login": {Rate: 1, Period: time.Minute, Burst: 1},
+		},
+		TrustForwardedFor: true,
+	}
+	r := chi.NewRouter()
+	rateLimiter := NewRateLimiter(ratelimit.NewMemoryLimiter(), limits, rateLimitSecret)
+	r.Use(rateLimiter.Handler)
+	r.Get("/books/{id}", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	r.Post("/auth/login", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	r.Post("/api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &entity.Claims{UserID: 7, Role: "user"}).
+		SignedString([]byte(rateLimitSecret))
+	require.NoError(t, err)
+	serve := func(method string, path string, ip string, authorized bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1, "+ip)
+		if authorized {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Пользователь ограничен строже, чем его IP, и после первого запроса упирается в свой лимит
+	w := serve(http.MethodGet, "/books/1", "192.0.2.1", true)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Reset"))
+
+	w = serve(http.MethodGet, "/books/2", "192.0.2.2", true)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "too many requests")
+
+	// Без токена тот же IP ограничен своим лимитом: одна попытка у него ушла на запрос выше
+	w = serve(http.MethodGet, "/books/3", "192.0.2.2", false)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+
+	// Вход считается отдельно и строже, а устаревший маршрут делит счётчик с версионным
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, "/api/v1/auth/login", "192.0.2.3", false).Code)
+	w = serve(http.MethodPost, "/auth/login", "192.0.2.3", false)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "/books/1", "192.0.2.3", false).Code)
+
+	// Новые ограничения действуют без перезапуска: вход больше не выделен, а пользователь ограничен мягче
+	limits.User.Burst = 10
+	limits.Routes = nil
+	rateLimiter.SetLimits(limits)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "/books/4", "192.0.2.4", true).Code)
+	w = serve(http.MethodPost, "/auth/login", "192.0.2.3", false)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "198.51.100.7:51234"
+	req.Header.Add("X-Forwarded-For", "203.0.113.9")
+	req.Header.Add("X-Forwarded-For", "10.0.0.1, 192.0.2.1")
+
+	assert.Equal(t, "198.51.100.7", clientIP(req, false))
+	assert.Equal(t, "192.0.2.1", clientIP(req, true))
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Чужой идентификатор принимается, только если его безопасно писать в лог и заголовки
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestLogger присваивает запросу request_id (из X-Request-ID клиента или новый),
+// возвращает его в X-Request-ID, кладёт логгер запроса в контекст и пишет access log
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := wrapper.WithRequestLogger(r.Context(), requestID)
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			wrapper.AddLogAttrs(ctx, slog.String("trace_id", spanContext.TraceID().String()))
+		}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		started := time.Now()
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		wrapper.Logger(ctx).Log(ctx, level, "Request completed",
+			slog.String("http_method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("duration", time.Since(started)),
+			slog.String("remote_addr", r.RemoteAddr),
+		)
+	})
+}
@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := wrapper.NewLogger(&output, "json", slog.LevelInfo)
+	assert.NoError(t, err)
+	defaultLogger := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(defaultLogger)
+
+	r := chi.NewRouter()
+	r.Use(RequestLogger)
+	r.With(func(next http.Handler) http.Handler {
+		// Подставляет пользователя так же, как IsAuthorized
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := &entity.Claims{UserID: 7, Role: "user"}
+			wrapper.AddLogAttrs(r.Context(), slog.Int("user_id", claims.UserID))
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+		})
+	}).Get("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		wrapper.WriteError(w, r, http.StatusNotFound, errors.New("book not found"), "test")
+	})
+
+	testCases := []struct {
+		name              string
+		requestID         string
+		expectedRequestID string
+	}{
+		{
+			name:              "Test 1: Client request ID is kept",
+			requestID:         "abc-123",
+			expectedRequestID: "abc-123",
+		},
+		{
+			name:      "Test 2: Unsafe request ID is replaced",
+			requestID: "bad id\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			output.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/books/1", nil)
+			req.Header.Set(RequestIDHeader, testCase.requestID)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(RequestIDHeader)
+			assert.NotEmpty(t, requestID)
+			if testCase.expectedRequestID != "" {
+				assert.Equal(t, testCase.expectedRequestID, requestID)
+			}
+
+			var problem wrapper.Problem
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+			assert.Equal(t, requestID, problem.RequestID)
+
+			// Ошибка и access log пишутся с одним request_id, пользователем и маршрутом
+			var lines []map[string]any
+			scanner := bufio.NewScanner(&output)
+			for scanner.Scan() {
+				var line map[string]any
+				assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+				lines = append(lines, line)
+			}
+			assert.Len(t, lines, 2)
+			for _, line := range lines {
+				assert.Equal(t, requestID, line["request_id"])
+				assert.Equal(t, float64(7), line["user_id"])
+				assert.Equal(t, "/books/{id}", line["route"])
+			}
+			assert.Equal(t, problem.ErrorID, lines[0]["error_id"])
+			assert.Equal(t, float64(http.StatusNotFound), lines[1]["status"])
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package recommendation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	similarKeyPrefix = "recommendations:similar:"
+	// Сколько последних книг пользователя используем для подбора "похожих" при холодном старте
+	fallbackSeedBooks = 3
+)
+
+type Neighbor struct {
+	BookID int     `json:"book_id"`
+	Score  float64 `json:"score"`
+}
+
+type Recommender interface {
+	Similar(ctx context.Context, bookId int, limit int) ([]entity.Book, error)
+	ForUser(ctx context.Context, userId int, limit int) ([]entity.Book, error)
+}
+
+type RecommenderImpl struct {
+	Repository  repository.RecommendationRepository
+	RedisClient *redis.Client
+}
+
+func NewRecommender(recommendationRepository repository.RecommendationRepository, redisClient *redis.Client) Recommender {
+	return &RecommenderImpl{Repository: recommendationRepository, RedisClient: redisClient}
+}
+
+func (recommender *RecommenderImpl) Similar(ctx context.Context, bookId int, limit int) ([]entity.Book, error) {
+	neighbors, err := recommender.loadNeighbors(ctx, []int{bookId})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := rankCandidates([]int{bookId}, neighbors)
+	books, err := recommender.hydrate(ctx, ids, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(books) >= limit {
+		return books, nil
+	}
+
+	// Холодный старт: книгу ещё никто не брал вместе с другими
+	like, err := recommender.Repository.GetBooksLike(ctx, bookId, limit)
+	if err != nil {
+		return nil, err
+	}
+	return appendUnique(books, like, limit, []int{bookId}), nil
+}
+
+func (recommender *RecommenderImpl) ForUser(ctx context.Context, userId int, limit int) ([]entity.Book, error) {
+	borrowed, err := recommender.Repository.GetBorrowedBookIDs(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	neighbors, err := recommender.loadNeighbors(ctx, borrowed)
+	if err != nil {
+		return nil, err
+	}
+
+	books, err := recommender.hydrate(ctx, rankCandidates(borrowed, neighbors), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(borrowed) && i < fallbackSeedBooks && len(books) < limit; i++ {
+		like, err := recommender.Repository.GetBooksLike(ctx, borrowed[i], limit)
+		if err != nil {
+			return nil, err
+		}
+		books = appendUnique(books, like, limit, borrowed)
+	}
+
+	if len(books) < limit {
+		exclude := append(slices.Clone(borrowed), bookIDs(books)...)
+		popular, err := recommender.Repository.GetPopular(ctx, exclude, limit-len(books))
+		if err != nil {
+			return nil, err
+		}
+		books = appendUnique(books, popular, limit, borrowed)
+	}
+	return books, nil
+}
+
+func (recommender *RecommenderImpl) loadNeighbors(ctx context.Context, bookIds []int) (map[int][]Neighbor, error) {
+	neighbors := make(map[int][]Neighbor, len(bookIds))
+	if len(bookIds) == 0 {
+		return neighbors, nil
+	}
+
+	keys := make([]string, len(bookIds))
+	for i, id := range bookIds {
+		keys[i] = similarKey(id)
+	}
+	values, err := recommender.RedisClient.MGet(ctx, keys...).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var bookNeighbors []Neighbor
+		if err := json.Unmarshal([]byte(data), &bookNeighbors); err == nil {
+			neighbors[bookIds[i]] = bookNeighbors
+		}
+	}
+	return neighbors, nil
+}
+
+func (recommender *RecommenderImpl) hydrate(ctx context.Context, ids []int, limit int) ([]entity.Book, error) {
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	books, err := recommender.Repository.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Возвращаем книги в порядке рейтинга рекомендаций, а не в порядке из БД
+	position := make(map[int]int, len(ids))
+	for i, id := range ids {
+		position[id] = i
+	}
+	sort.Slice(books, func(i, j int) bool {
+		return position[books[i].ID] < position[books[j].ID]
+	})
+	return books, nil
+}
+
+// rankCandidates суммирует похожесть соседей по всем исходным книгам и
+// возвращает кандидатов по убыванию суммарного веса, исключая сами исходные книги
+func rankCandidates(seeds []int, neighbors map[int][]Neighbor) []int {
+	scores := map[int]float64{}
+	for _, seed := range seeds {
+		for _, neighbor := range neighbors[seed] {
+			if !slices.Contains(seeds, neighbor.BookID) {
+				scores[neighbor.BookID] += neighbor.Score
+			}
+		}
+	}
+
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+func appendUnique(books []entity.Book, candidates []entity.Book, limit int, exclude []int) []entity.Book {
+	seen := bookIDs(books)
+	for _, candidate := range candidates {
+		if len(books) >= limit {
+			break
+		}
+		if slices.Contains(seen, candidate.ID) || slices.Contains(exclude, candidate.ID) {
+			continue
+		}
+		books = append(books, candidate)
+		seen = append(seen, candidate.ID)
+	}
+	return books
+}
+
+func bookIDs(books []entity.Book) []int {
+	ids := make([]int, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+	}
+	return ids
+}
+
+func similarKey(bookId int) string {
+	return fmt.Sprintf("%s%d", similarKeyPrefix, bookId)
+}
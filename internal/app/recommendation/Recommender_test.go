@@ -0,0 +1,42 @@
+package recommendation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankCandidates(t *testing.T) {
+	neighbors := map[int][]Neighbor{
+		1: {{BookID: 2, Score: 0.9}, {BookID: 3, Score: 0.5}, {BookID: 4, Score: 0.1}},
+		2: {{BookID: 1, Score: 0.9}, {BookID: 4, Score: 0.7}},
+	}
+
+	testCases := []struct {
+		name     string
+		seeds    []int
+		expected []int
+	}{
+		{
+			name:     "Test 1: Single book",
+			seeds:    []int{1},
+			expected: []int{2, 3, 4},
+		},
+		{
+			name:     "Test 2: Scores are summed and seeds excluded",
+			seeds:    []int{1, 2},
+			expected: []int{4, 3},
+		},
+		{
+			name:     "Test 3: Cold start",
+			seeds:    []int{5},
+			expected: []int{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, rankCandidates(testCase.seeds, neighbors))
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package recommendation
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RecomputeJob пересчитывает item-to-item модель по совместным выдачам из user_books
+// и кладёт соседей каждой книги в Redis
+type RecomputeJob struct {
+	Repository  repository.RecommendationRepository
+	RedisClient *redis.Client
+	Neighbors   int
+	TTL         time.Duration
+}
+
+func NewRecomputeJob(recommendationRepository repository.RecommendationRepository, redisClient *redis.Client,
+	neighbors int, ttl time.Duration) *RecomputeJob {
+	return &RecomputeJob{Repository: recommendationRepository, RedisClient: redisClient, Neighbors: neighbors, TTL: ttl}
+}
+
+func (job *RecomputeJob) Name() string {
+	return "recommendations.recompute"
+}
+
+func (job *RecomputeJob) Run(ctx context.Context) error {
+	pairs, err := job.Repository.GetSimilarBooks(ctx, job.Neighbors)
+	if err != nil {
+		return err
+	}
+
+	model := map[int][]Neighbor{}
+	for _, pair := range pairs {
+		model[pair.BookID] = append(model[pair.BookID], Neighbor{BookID: pair.SimilarID, Score: pair.Score})
+	}
+
+	// Ключи живут дольше интервала пересчёта, поэтому старая модель отдаётся, пока считается новая
+	pipe := job.RedisClient.Pipeline()
+	for bookId, neighbors := range model {
+		data, err := json.Marshal(neighbors)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, similarKey(bookId), data, job.TTL)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
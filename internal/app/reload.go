@@ -0,0 +1,208 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ReloadablePaths - настройки, которые безопасно менять без перезапуска.
+// Остальные изменения при перезагрузке отклоняются с предупреждением в логе
+var ReloadablePaths = []string{
+	"log.level",
+	"loans.max_active",
+	"loans.period",
+	"rate_limit.ip.rate",
+	"rate_limit.ip.period",
+	"rate_limit.ip.burst",
+	"rate_limit.user.rate",
+	"rate_limit.user.period",
+	"rate_limit.user.burst",
+	"rate_limit.api_key.rate",
+	"rate_limit.api_key.period",
+	"rate_limit.api_key.burst",
+	"rate_limit.routes",
+	"cors.allowed_origins",
+}
+
+// Редакторы и Kubernetes (ConfigMap через симлинк ..data) пишут файл в несколько событий
+const reloadDebounce = 200 * time.Millisecond
+
+// ConfigStatus - версия действующей конфигурации для админского эндпоинта
+type ConfigStatus struct {
+	Version    int64     `json:"version"`
+	Hash       string    `json:"hash"`
+	LoadedAt   time.Time `json:"loaded_at"`
+	Path       string    `json:"path,omitempty"`
+	Reloadable []string  `json:"reloadable"`
+}
+
+type configState struct {
+	config *Configuration
+	status ConfigStatus
+}
+
+// ConfigReloader перечитывает конфигурацию по SIGHUP или при изменении файла и атомарно
+// подменяет её. Подписчики получают новую конфигурацию только после успешной проверки
+type ConfigReloader struct {
+	load        func() (*Configuration, error)
+	mu          sync.Mutex
+	state       atomic.Pointer[configState]
+	subscribers []func(config *Configuration)
+}
+
+func NewConfigReloader(config *Configuration, load func() (*Configuration, error)) *ConfigReloader {
+	reloader := &ConfigReloader{load: load}
+	reloader.state.Store(&configState{config: config, status: ConfigStatus{
+		Version:    1,
+		Hash:       config.Hash(),
+		LoadedAt:   time.Now(),
+		Path:       config.Path(),
+		Reloadable: ReloadablePaths,
+	}})
+	return reloader
+}
+
+// OnReload регистрирует обработчик применённой конфигурации; вызывать до начала перезагрузок
+func (reloader *ConfigReloader) OnReload(f func(config *Configuration)) {
+	reloader.subscribers = append(reloader.subscribers, f)
+}
+
+func (reloader *ConfigReloader) Current() *Configuration {
+	return reloader.state.Load().config
+}
+
+func (reloader *ConfigReloader) Status() ConfigStatus {
+	return reloader.state.Load().status
+}
+
+// Reload читает конфигурацию заново. Невалидная конфигурация не применяется целиком,
+// из валидной применяются только ReloadablePaths
+func (reloader *ConfigReloader) Reload() error {
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	loaded, err := reloader.load()
+	if err == nil {
+		err = loaded.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("configuration not reloaded: %w", err)
+	}
+
+	current := reloader.state.Load()
+	changed := current.config.Diff(loaded)
+	var applied, rejected []string
+	for _, path := range changed {
+		if slices.Contains(ReloadablePaths, path) {
+			applied = append(applied, path)
+		} else {
+			rejected = append(rejected, path)
+		}
+	}
+	if len(rejected) > 0 {
+		slog.Warn("Settings can't be changed at runtime, restart to apply",
+			slog.String("settings", strings.Join(rejected, ",")))
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+
+	next := *current.config
+	next.copyFrom(loaded, applied)
+	status := current.status
+	status.Version++
+	status.Hash = next.Hash()
+	status.LoadedAt = time.Now()
+	reloader.state.Store(&configState{config: &next, status: status})
+
+	for _, subscriber := range reloader.subscribers {
+		subscriber(&next)
+	}
+	slog.Info("Configuration reloaded", slog.String("settings", strings.Join(applied, ",")),
+		slog.Int64("version", status.Version), slog.String("hash", status.Hash))
+	return nil
+}
+
+// Watch перезагружает конфигурацию при изменении файла, пока не отменён ctx.
+// Следит за каталогом, а не за файлом: при атомарной замене файл пересоздаётся
+func (reloader *ConfigReloader) Watch(ctx context.Context, path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		debounce := time.NewTimer(reloadDebounce)
+		debounce.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				debounce.Stop()
+				return
+			case event := <-watcher.Events:
+				if event.Has(fsnotify.Chmod) {
+					continue
+				}
+				debounce.Reset(reloadDebounce)
+			case err := <-watcher.Errors:
+				slog.Error("Watching configuration", slog.String("error", err.Error()))
+			case <-debounce.C:
+				if err := reloader.Reload(); err != nil {
+					slog.Error(err.Error())
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Diff возвращает пути настроек, значения которых отличаются; DeepEqual сравнивает и словари вроде rate_limit.routes
+func (config *Configuration) Diff(other *Configuration) []string {
+	var changed []string
+	otherFields := configFields(other)
+	for i, field := range configFields(config) {
+		if !reflect.DeepEqual(field.value.Interface(), otherFields[i].value.Interface()) {
+			changed = append(changed, field.path)
+		}
+	}
+	return changed
+}
+
+func (config *Configuration) copyFrom(other *Configuration, paths []string) {
+	otherFields := configFields(other)
+	for i, field := range configFields(config) {
+		if slices.Contains(paths, field.path) {
+			field.value.Set(otherFields[i].value)
+		}
+	}
+}
+
+// Hash - отпечаток значений, по которому видно, какая конфигурация действует.
+// Секреты в него не входят, чтобы их нельзя было подобрать по отпечатку
+func (config *Configuration) Hash() string {
+	hash := sha256.New()
+	for _, field := range configFields(config) {
+		if field.secret != "" {
+			continue
+		}
+		fmt.Fprintf(hash, "%s=%v\n", field.path, field.value.Interface())
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
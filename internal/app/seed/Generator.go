@@ -0,0 +1,243 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+)
+
+// Password - пароль всех сидовых пользователей, чтобы под любым из них можно было войти
+const Password = "seed-password"
+
+const (
+	emailDomain = "seed.test"
+	// history - за сколько дней до AsOf генерируется история займов
+	history     = 365
+	maxLoanDays = 45
+)
+
+// Options задаёт размер набора. При одинаковых Options набор получается одинаковым байт в байт
+type Options struct {
+	Seed             uint64
+	Books            int
+	Authors          int
+	Users            int
+	Admins           int
+	Loans            int
+	ActiveLoans      int
+	OverdueRatio     float64
+	MaxActivePerUser int
+	LoanPeriod       time.Duration
+	// AsOf - момент, относительно которого считаются даты займов и просрочки
+	AsOf time.Time
+}
+
+func (options Options) Validate() error {
+	var errs []error
+	check := func(ok bool, message string) {
+		if !ok {
+			errs = append(errs, errors.New(message))
+		}
+	}
+	check(options.Books >= 0 && options.Users >= 0 && options.Loans >= 0 && options.ActiveLoans >= 0,
+		"sizes must not be negative")
+	check(options.Books == 0 || options.Authors > 0, "authors must be positive when there are books")
+	check(options.Admins >= 0 && options.Admins <= options.Users, "admins must be between 0 and the number of users")
+	check(options.Loans+options.ActiveLoans == 0 || (options.Books > 0 && options.Users > 0),
+		"loans need at least one book and one user")
+	check(options.ActiveLoans <= options.Books, "active loans must not exceed books: a book is lent to one reader at a time")
+	check(options.MaxActivePerUser > 0 && options.ActiveLoans <= options.Users*options.MaxActivePerUser,
+		"active loans must not exceed users times loans.max_active")
+	check(options.OverdueRatio >= 0 && options.OverdueRatio <= 1, "overdue ratio must be between 0 and 1")
+	check(options.LoanPeriod >= 24*time.Hour, "loan period must be at least a day")
+	return errors.Join(errs...)
+}
+
+// Generate строит набор данных; passwordHash - хеш Password, он один на всех пользователей
+func Generate(options Options, passwordHash string) (repository.SeedDataset, error) {
+	if err := options.Validate(); err != nil {
+		return repository.SeedDataset{}, err
+	}
+	generator := &generator{options: options, rnd: rand.New(rand.NewPCG(options.Seed, 0x5eed))}
+	asOf := options.AsOf.UTC().Truncate(time.Second)
+	generator.options.AsOf = asOf
+
+	var dataset repository.SeedDataset
+	dataset.Users = generator.users(passwordHash)
+	dataset.Books = generator.books()
+	dataset.Loans = append(generator.returnedLoans(), generator.activeLoans()...)
+	return dataset, nil
+}
+
+type generator struct {
+	options Options
+	rnd     *rand.Rand
+}
+
+func userKey(i int) string {
+	return fmt.Sprintf("user-%06d", i+1)
+}
+
+func bookKey(i int) string {
+	return fmt.Sprintf("book-%06d", i+1)
+}
+
+func (g *generator) users(passwordHash string) []repository.SeedUser {
+	users := make([]repository.SeedUser, g.options.Users)
+	for i := range users {
+		role, email := "user", fmt.Sprintf("reader%d@%s", i+1-g.options.Admins, emailDomain)
+		if i < g.options.Admins {
+			role, email = "admin", fmt.Sprintf("admin%d@%s", i+1, emailDomain)
+		}
+		users[i] = repository.SeedUser{
+			Key:      userKey(i),
+			Name:     g.personName(),
+			Email:    email,
+			Password: passwordHash,
+			Role:     role,
+		}
+	}
+	return users
+}
+
+// authors возвращает различающиеся имена; при совпадении добавляется инициал
+func (g *generator) authors() []string {
+	authors := make([]string, 0, g.options.Authors)
+	seen := map[string]bool{}
+	for len(authors) < g.options.Authors {
+		name := g.personName()
+		for attempt := 0; seen[name]; attempt++ {
+			name = fmt.Sprintf("%s %c. %s", pick(g.rnd, firstNames), 'A'+rune(g.rnd.IntN(26)), pick(g.rnd, lastNames))
+			if attempt > 10 {
+				name = fmt.Sprintf("%s %d", name, len(authors)+1)
+			}
+		}
+		seen[name] = true
+		authors = append(authors, name)
+	}
+	return authors
+}
+
+func (g *generator) books() []repository.SeedBook {
+	if g.options.Books == 0 {
+		return nil
+	}
+	authors := g.authors()
+	books := make([]repository.SeedBook, g.options.Books)
+	for i := range books {
+		books[i] = repository.SeedBook{
+			Key:      bookKey(i),
+			Title:    g.title(),
+			Author:   authors[g.skewed(len(authors))],
+			Category: pick(g.rnd, categories),
+		}
+	}
+	return books
+}
+
+// returnedLoans - закрытые займы за последний год; часть книг вернули позже срока
+func (g *generator) returnedLoans() []repository.SeedLoan {
+	loans := make([]repository.SeedLoan, g.options.Loans)
+	for i := range loans {
+		takenDaysAgo := 2 + g.rnd.IntN(history-1)
+		taken := g.options.AsOf.Add(-time.Duration(takenDaysAgo)*24*time.Hour - g.timeOfDay())
+		length := time.Duration(1+g.rnd.IntN(min(maxLoanDays, takenDaysAgo-1)))*24*time.Hour + g.timeOfDay()
+		returned := taken.Add(length)
+		loans[i] = repository.SeedLoan{
+			UserKey:    userKey(g.rnd.IntN(g.options.Users)),
+			BookKey:    bookKey(g.skewed(g.options.Books)),
+			TakenDate:  taken,
+			DueDate:    taken.Add(g.options.LoanPeriod),
+			ReturnDate: &returned,
+		}
+	}
+	return loans
+}
+
+// activeLoans выдаёт разные книги, не больше MaxActivePerUser на читателя; первые OverdueRatio из них просрочены
+func (g *generator) activeLoans() []repository.SeedLoan {
+	bookIndexes := g.rnd.Perm(g.options.Books)[:g.options.ActiveLoans]
+	perUser := make([]int, g.options.Users)
+	overdue := int(float64(g.options.ActiveLoans)*g.options.OverdueRatio + 0.5)
+	periodDays := int(g.options.LoanPeriod / (24 * time.Hour))
+
+	loans := make([]repository.SeedLoan, len(bookIndexes))
+	for i, bookIndex := range bookIndexes {
+		user := g.rnd.IntN(g.options.Users)
+		for perUser[user] >= g.options.MaxActivePerUser {
+			user = (user + 1) % g.options.Users
+		}
+		perUser[user]++
+
+		var takenDaysAgo int
+		if i < overdue {
+			takenDaysAgo = periodDays + 1 + g.rnd.IntN(30)
+		} else {
+			takenDaysAgo = g.rnd.IntN(periodDays)
+		}
+		taken := g.options.AsOf.Add(-time.Duration(takenDaysAgo)*24*time.Hour - g.timeOfDay())
+		loans[i] = repository.SeedLoan{
+			UserKey:   userKey(user),
+			BookKey:   bookKey(bookIndex),
+			TakenDate: taken,
+			DueDate:   taken.Add(g.options.LoanPeriod),
+		}
+	}
+	return loans
+}
+
+func (g *generator) personName() string {
+	return pick(g.rnd, firstNames) + " " + pick(g.rnd, lastNames)
+}
+
+func (g *generator) title() string {
+	switch g.rnd.IntN(5) {
+	case 0:
+		return "The " + pick(g.rnd, adjectives) + " " + pick(g.rnd, nouns)
+	case 1:
+		return pick(g.rnd, nouns) + " of " + pick(g.rnd, places)
+	case 2:
+		return "The " + pick(g.rnd, nouns) + " and the " + pick(g.rnd, nouns)
+	case 3:
+		return pick(g.rnd, adjectives) + " " + pick(g.rnd, nouns) + "s"
+	default:
+		return "A " + pick(g.rnd, nouns) + " in " + pick(g.rnd, places)
+	}
+}
+
+// skewed выбирает индекс так, что первые элементы встречаются чаще: у популярных авторов и книг больше выдач
+func (g *generator) skewed(n int) int {
+	r := g.rnd.Float64()
+	return int(r * r * float64(n))
+}
+
+// timeOfDay сдвигает выдачу внутри дня, чтобы займы не начинались ровно в полночь и не совпадали с AsOf
+func (g *generator) timeOfDay() time.Duration {
+	return time.Duration(1+g.rnd.IntN(12*60*60)) * time.Second
+}
+
+func pick(rnd *rand.Rand, list []string) string {
+	return list[rnd.IntN(len(list))]
+}
+
+var (
+	firstNames = []string{"Alice", "Boris", "Clara", "Dmitry", "Elena", "Felix", "Grace", "Hugo", "Irina", "James",
+		"Katya", "Leo", "Maria", "Nikolai", "Olga", "Pavel", "Quinn", "Rosa", "Sergey", "Tanya", "Ulrich", "Vera",
+		"William", "Xenia", "Yuri", "Zoe", "Anton", "Bella", "Carlos", "Daria", "Emil", "Fatima", "Gleb", "Hanna",
+		"Ivan", "Julia", "Kirill", "Lena", "Marc", "Nadia"}
+	lastNames = []string{"Abbott", "Baranov", "Carter", "Dolgov", "Evans", "Fedorova", "Garcia", "Hoffman", "Ivanov",
+		"Jensen", "Kuznetsova", "Lebedev", "Morozova", "Novak", "Orlov", "Petrova", "Quinlan", "Romanov", "Smirnov",
+		"Turner", "Usmanov", "Volkova", "Walker", "Xu", "Yakovlev", "Zaitseva", "Bennett", "Chen", "Duncan", "Egorov",
+		"Fischer", "Gromov", "Harris", "Ilyina", "Kowalski", "Larsen", "Mironov", "Nielsen", "Osipova", "Popov"}
+	adjectives = []string{"Silent", "Hidden", "Broken", "Golden", "Last", "Forgotten", "Crimson", "Distant", "Frozen",
+		"Burning", "Quiet", "Endless", "Lost", "Secret", "Wandering", "Northern", "Hollow", "Bright", "Bitter", "Savage"}
+	nouns = []string{"River", "Garden", "Empire", "Winter", "Lighthouse", "Station", "Orchard", "Mirror", "Harbor",
+		"Kingdom", "Letter", "Forest", "Engine", "Archive", "Voyage", "Storm", "Island", "Machine", "Tower", "Promise"}
+	places = []string{"Ashes", "the North", "Glass", "the Deep", "Tomorrow", "the Steppe", "Silence", "Salt",
+		"the Old City", "Stars", "the Valley", "Iron", "the Coast", "Dust", "the Border"}
+	categories = []string{"fiction", "fantasy", "science-fiction", "mystery", "history", "biography", "science",
+		"poetry", "children", "travel"}
+)
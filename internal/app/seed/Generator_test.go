@@ -0,0 +1,91 @@
+package seed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var asOf = time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+func testOptions() Options {
+	return Options{
+		Seed:             42,
+		Books:            300,
+		Authors:          60,
+		Users:            50,
+		Admins:           2,
+		Loans:            1000,
+		ActiveLoans:      120,
+		OverdueRatio:     0.25,
+		MaxActivePerUser: 5,
+		LoanPeriod:       14 * 24 * time.Hour,
+		AsOf:             asOf,
+	}
+}
+
+func TestGenerate_IsReproducible(t *testing.T) {
+	first, err := Generate(testOptions(), "hash")
+	require.NoError(t, err)
+	second, err := Generate(testOptions(), "hash")
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	options := testOptions()
+	options.Seed = 43
+	other, err := Generate(options, "hash")
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Books, other.Books)
+	// ключи зависят только от размера набора, поэтому другой seed обновляет те же строки
+	assert.Equal(t, first.Books[7].Key, other.Books[7].Key)
+}
+
+func TestGenerate_Loans(t *testing.T) {
+	options := testOptions()
+	dataset, err := Generate(options, "hash")
+	require.NoError(t, err)
+	require.Len(t, dataset.Users, options.Users)
+	require.Len(t, dataset.Books, options.Books)
+	require.Len(t, dataset.Loans, options.Loans+options.ActiveLoans)
+
+	assert.Equal(t, "admin", dataset.Users[1].Role)
+	assert.Equal(t, "admin2@seed.test", dataset.Users[1].Email)
+	assert.Equal(t, "user", dataset.Users[2].Role)
+	assert.Equal(t, "reader1@seed.test", dataset.Users[2].Email)
+
+	activeBooks := map[string]bool{}
+	activePerUser := map[string]int{}
+	overdue := 0
+	for _, loan := range dataset.Loans {
+		assert.True(t, loan.TakenDate.Before(asOf))
+		assert.Equal(t, options.LoanPeriod, loan.DueDate.Sub(loan.TakenDate))
+		if loan.ReturnDate != nil {
+			assert.True(t, loan.ReturnDate.After(loan.TakenDate))
+			assert.True(t, loan.ReturnDate.Before(asOf))
+			continue
+		}
+		assert.False(t, activeBooks[loan.BookKey], "book %s is lent twice", loan.BookKey)
+		activeBooks[loan.BookKey] = true
+		activePerUser[loan.UserKey]++
+		if loan.DueDate.Before(asOf) {
+			overdue++
+		}
+	}
+	assert.Len(t, activeBooks, options.ActiveLoans)
+	for user, count := range activePerUser {
+		assert.LessOrEqual(t, count, options.MaxActivePerUser, user)
+	}
+	assert.Equal(t, 30, overdue)
+}
+
+func TestGenerate_InvalidOptions(t *testing.T) {
+	options := testOptions()
+	options.ActiveLoans = options.Books + 1
+	options.Admins = options.Users + 1
+	_, err := Generate(options, "hash")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "active loans must not exceed books")
+	assert.Contains(t, err.Error(), "admins must be between 0 and the number of users")
+}
@@ -6,16 +6,18 @@ import (
 	"net/http"
 
 	"github.com/Ablyamitov/simple-rest/internal/app/handlers"
+	"github.com/Ablyamitov/simple-rest/internal/app/metrics"
 	"github.com/Ablyamitov/simple-rest/internal/app/middlewares"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 type Server interface {
 	Start() error
 	Stop(ctx context.Context) error
+	// OnShutdown регистрирует функцию, вызываемую в начале Stop
+	OnShutdown(f func())
 }
 
 type HttpServer struct {
@@ -23,22 +25,48 @@ type HttpServer struct {
 	router *chi.Mux
 }
 
-func NewServer(userHandler handlers.UserHandler, bookHandler handlers.BookHandler, authHandler handlers.AuthHandler, secret string) Server {
+func NewServer(addr string, userHandler handlers.UserHandler, bookHandler handlers.BookHandler, authHandler handlers.AuthHandler,
+	coverHandler handlers.CoverHandler, reviewHandler handlers.ReviewHandler,
+	recommendationHandler handlers.RecommendationHandler, statsHandler handlers.StatsHandler,
+	loanHandler handlers.LoanHandler, healthHandler handlers.HealthHandler, configHandler handlers.ConfigHandler,
+	secret string,
+	deprecation middlewares.Deprecation, cors func(http.Handler) http.Handler, rateLimit func(http.Handler) http.Handler,
+	idempotency func(http.Handler) http.Handler, m metrics.Metrics) Server {
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
+	r.Use(middlewares.Tracing)
+	r.Use(middlewares.RequestLogger)
+	r.Use(middlewares.Metrics(m))
+	// Preflight-запросы отвечаются до ограничения запросов и авторизации
+	r.Use(cors)
 	r.Use(middlewares.JsonContentType)
-	routeUsers(r, userHandler, secret)
-	routeBooks(r, bookHandler, secret)
-	routeAuth(r, authHandler)
+	// Пробы, метрики и документация не ограничиваются
+	r.Group(func(r chi.Router) {
+		r.Use(rateLimit)
+		r.Use(idempotency)
+		r.Route("/api/v1", func(r chi.Router) {
+			routeUsers(r, userHandler, secret)
+			routeBooks(r, bookHandler, coverHandler, reviewHandler, recommendationHandler, secret)
+			routeLoans(r, loanHandler, secret)
+			routeReviews(r, reviewHandler, secret)
+			routeMe(r, recommendationHandler, secret)
+			routeStats(r, statsHandler, secret)
+			routeAdmin(r, configHandler, secret)
+			routeAuth(r, authHandler)
+		})
+		routeLegacy(r, userHandler, bookHandler, authHandler, coverHandler, reviewHandler, recommendationHandler,
+			statsHandler, secret, deprecation)
+	})
 
+	r.Handle("/metrics", m.Handler())
+	r.Get("/healthz", healthHandler.Live)
+	r.Get("/readyz", healthHandler.Ready)
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
 	r.Get("/swagger/doc.json", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./gen/api/openapi.yaml")
 	})
 	srv := &http.Server{
-		//Addr:    fmt.Sprintf("%s:%d", host, port),
-		Addr:    ":8080",
+		Addr:    addr,
 		Handler: r,
 	}
 
@@ -50,28 +78,98 @@ func routeUsers(r chi.Router, userHandler handlers.UserHandler, secret string) {
 	r.Route("/users", func(r chi.Router) {
 		r.Use(middlewares.IsAuthorized(secret))
 
-		r.Get("/", userHandler.GetAll)            //Get All Users
-		r.Get("/{id}", userHandler.GetById)       //Get User by id
-		r.Post("/add", userHandler.Create)        //Create User
-		r.Patch("/update", userHandler.Update)    //Update User
-		r.Delete("/{id}", userHandler.Delete)     //Delete User
-		r.Post("/take", userHandler.TakeBook)     //Take book to User
-		r.Post("/return", userHandler.ReturnBook) //Return book from User
+		r.Get("/", userHandler.GetAll)        //Get All Users
+		r.Post("/", userHandler.Create)       //Create User
+		r.Get("/{id}", userHandler.GetById)   //Get User by id
+		r.Put("/{id}", userHandler.Update)    //Update User
+		r.Patch("/{id}", userHandler.Patch)   //Patch User
+		r.Delete("/{id}", userHandler.Delete) //Delete User
 	})
 }
 
-func routeBooks(r chi.Router, bookHandler handlers.BookHandler, secret string) {
+func routeBooks(r chi.Router, bookHandler handlers.BookHandler, coverHandler handlers.CoverHandler,
+	reviewHandler handlers.ReviewHandler, recommendationHandler handlers.RecommendationHandler, secret string) {
 	//books
 	r.Route("/books", func(r chi.Router) {
+		// Обложки отдаются без токена, чтобы их можно было вставлять в <img>
+		r.Get("/{id}/cover", coverHandler.Get) //Get Book cover
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.IsAuthorized(secret))
+
+			r.Get("/", bookHandler.GetAll)            //Get All Books
+			r.Post("/", bookHandler.Create)           //Create Book
+			r.Get("/{id}", bookHandler.GetById)       //Get Book by id
+			r.Put("/{id}", bookHandler.Update)        //Update Book
+			r.Patch("/{id}", bookHandler.Patch)       //Patch Book
+			r.Delete("/{id}", bookHandler.Delete)     //Delete Book
+			r.Put("/{id}/cover", coverHandler.Upload) //Upload Book cover
+
+			r.With(middlewares.HasRole("admin")).Post("/{id}/restore", bookHandler.Restore) //Restore deleted Book
+
+			r.Get("/{id}/reviews", reviewHandler.GetByBook)            //Get approved Book reviews
+			r.Post("/{id}/reviews", reviewHandler.Create)              //Review Book
+			r.Patch("/{id}/reviews/{reviewId}", reviewHandler.Update)  //Edit own review
+			r.Delete("/{id}/reviews/{reviewId}", reviewHandler.Delete) //Delete own review
+
+			r.Get("/{id}/similar", recommendationHandler.GetSimilar) //Readers also borrowed
+		})
+	})
+
+}
+
+func routeLoans(r chi.Router, loanHandler handlers.LoanHandler, secret string) {
+	//loans
+	r.Route("/loans", func(r chi.Router) {
+		r.Use(middlewares.IsAuthorized(secret))
+
+		r.Post("/", loanHandler.Create)       //Take book
+		r.Get("/{id}", loanHandler.GetById)   //Get loan by id
+		r.Delete("/{id}", loanHandler.Return) //Return book
+	})
+}
+
+func routeMe(r chi.Router, recommendationHandler handlers.RecommendationHandler, secret string) {
+	//current user
+	r.Route("/me", func(r chi.Router) {
 		r.Use(middlewares.IsAuthorized(secret))
 
-		r.Get("/", bookHandler.GetAll)         //Get All Books
-		r.Get("/{id}", bookHandler.GetById)    //Get Book by id
-		r.Post("/add", bookHandler.Create)     //Create Book
-		r.Patch("/update", bookHandler.Update) //Update Book
-		r.Delete("/{id}", bookHandler.Delete)  //Delete Book
+		r.Get("/recommendations", recommendationHandler.GetForMe) //Personal recommendations
 	})
+}
+
+func routeReviews(r chi.Router, reviewHandler handlers.ReviewHandler, secret string) {
+	//reviews moderation
+	r.Route("/reviews", func(r chi.Router) {
+		r.Use(middlewares.IsAuthorized(secret))
+		r.Use(middlewares.HasRole("admin"))
 
+		r.Get("/moderation", reviewHandler.GetModerationQueue) //Get reviews by status
+		r.Post("/{reviewId}/approve", reviewHandler.Approve)   //Approve review
+		r.Post("/{reviewId}/hide", reviewHandler.Hide)         //Hide review
+	})
+}
+
+func routeStats(r chi.Router, statsHandler handlers.StatsHandler, secret string) {
+	//statistics
+	r.Route("/stats", func(r chi.Router) {
+		r.Use(middlewares.IsAuthorized(secret))
+		r.Use(middlewares.HasRole("admin"))
+
+		r.Get("/most-borrowed", statsHandler.GetMostBorrowed)   //Most borrowed books
+		r.Get("/loan-length", statsHandler.GetLoanLength)       //Average loan length by category
+		r.Get("/never-borrowed", statsHandler.GetNeverBorrowed) //Books never borrowed
+		r.Get("/active-readers", statsHandler.GetActiveReaders) //Active readers per week
+	})
+}
+
+func routeAdmin(r chi.Router, configHandler handlers.ConfigHandler, secret string) {
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(middlewares.IsAuthorized(secret))
+		r.Use(middlewares.HasRole("admin"))
+
+		r.Get("/config", configHandler.GetStatus) //Version and hash of the effective configuration
+	})
 }
 
 func routeAuth(r chi.Router, authHandler handlers.AuthHandler) {
@@ -82,11 +180,92 @@ func routeAuth(r chi.Router, authHandler handlers.AuthHandler) {
 	})
 }
 
+// routeLegacy оставляет маршруты без версии как устаревшие псевдонимы /api/v1
+func routeLegacy(r chi.Router, userHandler handlers.UserHandler, bookHandler handlers.BookHandler,
+	authHandler handlers.AuthHandler, coverHandler handlers.CoverHandler, reviewHandler handlers.ReviewHandler,
+	recommendationHandler handlers.RecommendationHandler, statsHandler handlers.StatsHandler, secret string,
+	deprecation middlewares.Deprecation) {
+	// Для /update, /take и /return id нет в пути, поэтому они ссылаются на коллекцию
+	legacy := func(successor string) func(http.Handler) http.Handler {
+		return middlewares.Deprecated(deprecation, "/api/v1"+successor)
+	}
+
+	r.Route("/users", func(r chi.Router) {
+		r.Use(middlewares.IsAuthorized(secret))
+
+		r.With(legacy("/users")).Get("/", userHandler.GetAll)
+		r.With(legacy("/users/{id}")).Get("/{id}", userHandler.GetById)
+		r.With(legacy("/users")).Post("/add", userHandler.Create)
+		r.With(legacy("/users")).Patch("/update", userHandler.Update)
+		r.With(legacy("/users/{id}")).Patch("/{id}", userHandler.Patch)
+		r.With(legacy("/users/{id}")).Delete("/{id}", userHandler.Delete)
+		r.With(legacy("/loans")).Post("/take", userHandler.TakeBook)
+		r.With(legacy("/loans")).Post("/return", userHandler.ReturnBook)
+	})
+
+	r.Route("/books", func(r chi.Router) {
+		r.With(legacy("/books/{id}/cover")).Get("/{id}/cover", coverHandler.Get)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.IsAuthorized(secret))
+
+			r.With(legacy("/books")).Get("/", bookHandler.GetAll)
+			r.With(legacy("/books/{id}")).Get("/{id}", bookHandler.GetById)
+			r.With(legacy("/books")).Post("/add", bookHandler.Create)
+			r.With(legacy("/books")).Patch("/update", bookHandler.Update)
+			r.With(legacy("/books/{id}")).Patch("/{id}", bookHandler.Patch)
+			r.With(legacy("/books/{id}")).Delete("/{id}", bookHandler.Delete)
+			r.With(legacy("/books/{id}/cover")).Post("/{id}/cover", coverHandler.Upload)
+			r.With(legacy("/books/{id}/restore"), middlewares.HasRole("admin")).Post("/{id}/restore", bookHandler.Restore)
+			r.With(legacy("/books/{id}/reviews")).Get("/{id}/reviews", reviewHandler.GetByBook)
+			r.With(legacy("/books/{id}/reviews")).Post("/{id}/reviews", reviewHandler.Create)
+			r.With(legacy("/books/{id}/reviews/{reviewId}")).Patch("/{id}/reviews/{reviewId}", reviewHandler.Update)
+			r.With(legacy("/books/{id}/reviews/{reviewId}")).Delete("/{id}/reviews/{reviewId}", reviewHandler.Delete)
+			r.With(legacy("/books/{id}/similar")).Get("/{id}/similar", recommendationHandler.GetSimilar)
+		})
+	})
+
+	r.Route("/reviews", func(r chi.Router) {
+		r.Use(middlewares.IsAuthorized(secret))
+		r.Use(middlewares.HasRole("admin"))
+
+		r.With(legacy("/reviews/moderation")).Get("/moderation", reviewHandler.GetModerationQueue)
+		r.With(legacy("/reviews/{reviewId}/approve")).Post("/{reviewId}/approve", reviewHandler.Approve)
+		r.With(legacy("/reviews/{reviewId}/hide")).Post("/{reviewId}/hide", reviewHandler.Hide)
+	})
+
+	r.Route("/me", func(r chi.Router) {
+		r.Use(middlewares.IsAuthorized(secret))
+
+		r.With(legacy("/me/recommendations")).Get("/recommendations", recommendationHandler.GetForMe)
+	})
+
+	r.Route("/stats", func(r chi.Router) {
+		r.Use(middlewares.IsAuthorized(secret))
+		r.Use(middlewares.HasRole("admin"))
+
+		r.With(legacy("/stats/most-borrowed")).Get("/most-borrowed", statsHandler.GetMostBorrowed)
+		r.With(legacy("/stats/loan-length")).Get("/loan-length", statsHandler.GetLoanLength)
+		r.With(legacy("/stats/never-borrowed")).Get("/never-borrowed", statsHandler.GetNeverBorrowed)
+		r.With(legacy("/stats/active-readers")).Get("/active-readers", statsHandler.GetActiveReaders)
+	})
+
+	r.Route("/auth", func(r chi.Router) {
+		r.With(legacy("/auth/register")).Post("/register", authHandler.Register)
+		r.With(legacy("/auth/login")).Post("/login", authHandler.Login)
+		r.With(legacy("/auth/check-auth")).Post("/check-auth", authHandler.CheckAuth)
+	})
+}
+
 func (s *HttpServer) Start() error {
 	log.Printf("Starting server on %s", s.server.Addr)
 	return s.server.ListenAndServe()
 }
 
+func (s *HttpServer) OnShutdown(f func()) {
+	s.server.RegisterOnShutdown(f)
+}
+
 func (s *HttpServer) Stop(ctx context.Context) error {
 	log.Printf("Stopping server on %s", s.server.Addr)
 	return s.server.Shutdown(ctx)
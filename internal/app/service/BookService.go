@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+)
+
+type BookService interface {
+	GetAll(ctx context.Context) ([]entity.Book, error)
+	GetByID(ctx context.Context, id int) (*entity.Book, error)
+	Create(ctx context.Context, book *entity.Book) error
+	Update(ctx context.Context, book *entity.Book) (*entity.Book, error)
+	Patch(ctx context.Context, id int, version int, patch repository.BookPatch) (*entity.Book, error)
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) (*entity.Book, error)
+}
+
+type BookServiceImpl struct {
+	BookRepository repository.BookRepository
+}
+
+func NewBookService(bookRepository repository.BookRepository) BookService {
+	return &BookServiceImpl{BookRepository: bookRepository}
+}
+
+func (bookService *BookServiceImpl) GetAll(ctx context.Context) ([]entity.Book, error) {
+	return bookService.BookRepository.GetALL(ctx)
+}
+
+func (bookService *BookServiceImpl) GetByID(ctx context.Context, id int) (*entity.Book, error) {
+	return bookService.BookRepository.GetByID(ctx, id)
+}
+
+func (bookService *BookServiceImpl) Create(ctx context.Context, book *entity.Book) error {
+	return bookService.BookRepository.Create(ctx, book)
+}
+
+func (bookService *BookServiceImpl) Update(ctx context.Context, book *entity.Book) (*entity.Book, error) {
+	return bookService.BookRepository.Update(ctx, book)
+}
+
+func (bookService *BookServiceImpl) Patch(ctx context.Context, id int, version int, patch repository.BookPatch) (*entity.Book, error) {
+	return bookService.BookRepository.Patch(ctx, id, version, patch)
+}
+
+// Delete помечает книгу удалённой; выданную книгу удалить нельзя (domain.ErrActiveLoans)
+func (bookService *BookServiceImpl) Delete(ctx context.Context, id int) error {
+	return bookService.BookRepository.Delete(ctx, id)
+}
+
+// Restore возвращает удалённую книгу в каталог и отдаёт её актуальное состояние
+func (bookService *BookServiceImpl) Restore(ctx context.Context, id int) (*entity.Book, error) {
+	if err := bookService.BookRepository.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+	return bookService.BookRepository.GetByID(ctx, id)
+}
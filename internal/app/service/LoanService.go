@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+)
+
+// LoanPolicy - правила выдачи книг. MaxActiveLoans <= 0 снимает ограничение на число книг на руках
+type LoanPolicy struct {
+	MaxActiveLoans int
+	LoanPeriod     time.Duration
+}
+
+type LoanService interface {
+	GetByID(ctx context.Context, id int) (*entity.Loan, error)
+	Take(ctx context.Context, userId int, bookId int) (*entity.Loan, error)
+	Return(ctx context.Context, id int) (*entity.Loan, error)
+	ReturnBook(ctx context.Context, userId int, bookId int) (*entity.Loan, error)
+	SetPolicy(policy LoanPolicy)
+}
+
+type LoanServiceImpl struct {
+	LoanRepository repository.LoanRepository
+	policy         atomic.Pointer[LoanPolicy]
+}
+
+func NewLoanService(loanRepository repository.LoanRepository, policy LoanPolicy) LoanService {
+	loanService := &LoanServiceImpl{LoanRepository: loanRepository}
+	loanService.SetPolicy(policy)
+	return loanService
+}
+
+// SetPolicy меняет правила на лету; уже выданные книги сохраняют свой срок возврата
+func (loanService *LoanServiceImpl) SetPolicy(policy LoanPolicy) {
+	loanService.policy.Store(&policy)
+}
+
+func (loanService *LoanServiceImpl) GetByID(ctx context.Context, id int) (*entity.Loan, error) {
+	return loanService.LoanRepository.GetByID(ctx, id)
+}
+
+// Take выдаёт книгу по действующим правилам: выданная книга - domain.ErrBookUnavailable,
+// превышение лимита - domain.ErrLimitExceeded
+func (loanService *LoanServiceImpl) Take(ctx context.Context, userId int, bookId int) (*entity.Loan, error) {
+	policy := loanService.policy.Load()
+	terms := repository.LoanTerms{
+		MaxActive: policy.MaxActiveLoans,
+		DueDate:   time.Now().Add(policy.LoanPeriod),
+	}
+	return loanService.LoanRepository.Create(ctx, userId, bookId, terms)
+}
+
+func (loanService *LoanServiceImpl) Return(ctx context.Context, id int) (*entity.Loan, error) {
+	return loanService.LoanRepository.Return(ctx, id)
+}
+
+// ReturnBook закрывает активный займ книги пользователем; нужен устаревшему POST /users/return
+func (loanService *LoanServiceImpl) ReturnBook(ctx context.Context, userId int, bookId int) (*entity.Loan, error) {
+	loan, err := loanService.LoanRepository.GetActive(ctx, userId, bookId)
+	if err != nil {
+		return nil, err
+	}
+	return loanService.LoanRepository.Return(ctx, loan.ID)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	mock "github.com/Ablyamitov/simple-rest/internal/store/db/repository/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoanService_Take(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mock.NewMockLoanRepository(ctrl)
+	loanService := NewLoanService(mockRepository, LoanPolicy{MaxActiveLoans: 3, LoanPeriod: 14 * 24 * time.Hour})
+
+	before := time.Now()
+	mockRepository.EXPECT().Create(gomock.Any(), gomock.Eq(1), gomock.Eq(2), gomock.Any()).
+		Do(func(_ context.Context, _ int, _ int, terms repository.LoanTerms) {
+			assert.Equal(t, 3, terms.MaxActive)
+			assert.WithinDuration(t, before.Add(14*24*time.Hour), terms.DueDate, time.Minute)
+		}).
+		Return(&entity.Loan{ID: 7, UserID: 1, BookID: 2}, nil)
+	loan, err := loanService.Take(context.Background(), 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, loan.ID)
+
+	// Ошибки правил выдачи возвращаются как есть, статус по ним выбирает обработчик
+	mockRepository.EXPECT().Create(gomock.Any(), gomock.Eq(1), gomock.Eq(3), gomock.Any()).
+		Return(nil, domain.ErrBookUnavailable)
+	_, err = loanService.Take(context.Background(), 1, 3)
+	assert.ErrorIs(t, err, domain.ErrBookUnavailable)
+}
+
+func TestLoanService_ReturnBook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := mock.NewMockLoanRepository(ctrl)
+	loanService := NewLoanService(mockRepository, LoanPolicy{})
+
+	mockRepository.EXPECT().GetActive(gomock.Any(), gomock.Eq(1), gomock.Eq(2)).Return(&entity.Loan{ID: 7}, nil)
+	mockRepository.EXPECT().Return(gomock.Any(), gomock.Eq(7)).Return(&entity.Loan{ID: 7}, nil)
+	_, err := loanService.ReturnBook(context.Background(), 1, 2)
+	assert.NoError(t, err)
+
+	mockRepository.EXPECT().GetActive(gomock.Any(), gomock.Eq(1), gomock.Eq(3)).Return(nil, domain.ErrLoanNotFound)
+	_, err = loanService.ReturnBook(context.Background(), 1, 3)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
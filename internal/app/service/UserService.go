@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/tracing"
+	"github.com/Ablyamitov/simple-rest/internal/app/utils"
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+)
+
+var errEmailTaken = domain.NewError(domain.ErrConflict, "user with the same email already exists")
+
+type UserService interface {
+	GetAll(ctx context.Context) ([]entity.User, error)
+	GetByID(ctx context.Context, id int) (*entity.User, error)
+	GetByEmail(ctx context.Context, email string) (*entity.User, error)
+	Create(ctx context.Context, user *entity.User) error
+	Update(ctx context.Context, user *entity.User) (*entity.User, error)
+	Patch(ctx context.Context, id int, version int, patch repository.UserPatch) (*entity.User, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type UserServiceImpl struct {
+	UserRepository repository.UserRepository
+}
+
+func NewUserService(userRepository repository.UserRepository) UserService {
+	return &UserServiceImpl{UserRepository: userRepository}
+}
+
+func (userService *UserServiceImpl) GetAll(ctx context.Context) ([]entity.User, error) {
+	return userService.UserRepository.GetAll(ctx)
+}
+
+func (userService *UserServiceImpl) GetByID(ctx context.Context, id int) (*entity.User, error) {
+	return userService.UserRepository.GetByID(ctx, id)
+}
+
+// GetByEmail возвращает domain.ErrUserNotFound, если активного пользователя с такой почтой нет
+func (userService *UserServiceImpl) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	user, err := userService.UserRepository.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, domain.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// Create хеширует пароль и сохраняет пользователя; занятая почта - domain.ErrConflict.
+// Уникальный индекс по почте закрывает гонку между проверкой и вставкой
+func (userService *UserServiceImpl) Create(ctx context.Context, user *entity.User) error {
+	existingUser, err := userService.UserRepository.GetByEmail(ctx, user.Email)
+	if err != nil {
+		return err
+	}
+	if existingUser.ID != 0 {
+		return errEmailTaken
+	}
+
+	if user.Password, err = hashPassword(ctx, user.Password); err != nil {
+		return err
+	}
+	return userService.UserRepository.Create(ctx, user)
+}
+
+func (userService *UserServiceImpl) Update(ctx context.Context, user *entity.User) (*entity.User, error) {
+	return userService.UserRepository.Update(ctx, user)
+}
+
+// Patch принимает новый пароль в открытом виде и сохраняет только его хеш
+func (userService *UserServiceImpl) Patch(ctx context.Context, id int, version int, patch repository.UserPatch) (*entity.User, error) {
+	if patch.Password != nil {
+		hash, err := hashPassword(ctx, *patch.Password)
+		if err != nil {
+			return nil, err
+		}
+		patch.Password = &hash
+	}
+	return userService.UserRepository.Patch(ctx, id, version, patch)
+}
+
+// Delete помечает пользователя удалённым; пользователя с невозвращёнными книгами удалить нельзя
+func (userService *UserServiceImpl) Delete(ctx context.Context, id int) error {
+	return userService.UserRepository.Delete(ctx, id)
+}
+
+// hashPassword выделен в отдельный спан: bcrypt намеренно медленный и заметен в трассе запроса
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Tracer().Start(ctx, "bcrypt.hash")
+	defer span.End()
+	return utils.GenerateHashPassword(password)
+}
@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTransport(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	defaultProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(defaultProvider)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	ctx, parent := Tracer().Start(context.Background(), "parent")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, server.URL+"/bucket/key?X-Amz-Signature=secret", nil)
+	assert.NoError(t, err)
+	client := &http.Client{Transport: NewTransport(nil)}
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Body.Close())
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	clientSpan := spans[0]
+	assert.Equal(t, "HTTP PUT", clientSpan.Name())
+	assert.Equal(t, parent.SpanContext().TraceID(), clientSpan.SpanContext().TraceID())
+	// Сервер получил traceparent клиентского спана
+	assert.Equal(t, "00-"+clientSpan.SpanContext().TraceID().String()+"-"+clientSpan.SpanContext().SpanID().String()+"-01",
+		traceparent)
+	for _, attr := range clientSpan.Attributes() {
+		if attr.Key == "url.full" {
+			assert.Equal(t, server.URL+"/bucket/key", attr.Value.AsString())
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const maxCoverPixels = 40_000_000
+
+var (
+	errUnsupportedImageType = errors.New("unsupported image type, expected JPEG, PNG or WebP")
+	errImageTooLarge        = errors.New("image dimensions are too large")
+)
+
+// CoverSizes - ширина миниатюр обложки в пикселях
+var CoverSizes = map[string]int{
+	"small":  96,
+	"medium": 256,
+	"large":  512,
+}
+
+var allowedCoverTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// SniffCoverType определяет тип по содержимому, а не по заголовку Content-Type клиента
+func SniffCoverType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if !allowedCoverTypes[contentType] {
+		return "", errUnsupportedImageType
+	}
+	return contentType, nil
+}
+
+func GenerateCoverThumbnails(data []byte) (map[string][]byte, error) {
+	// Проверяем размеры до полного декодирования, чтобы не распаковывать "бомбы"
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errUnsupportedImageType
+	}
+	if config.Width*config.Height > maxCoverPixels {
+		return nil, errImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnails := make(map[string][]byte, len(CoverSizes))
+	for name, width := range CoverSizes {
+		thumbnail, err := encodeThumbnail(src, width)
+		if err != nil {
+			return nil, err
+		}
+		thumbnails[name] = thumbnail
+	}
+	return thumbnails, nil
+}
+
+func encodeThumbnail(src image.Image, width int) ([]byte, error) {
+	bounds := src.Bounds()
+	if bounds.Dx() < width {
+		width = bounds.Dx()
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	// JPEG не поддерживает прозрачность, поэтому подкладываем белый фон
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrIfMatchRequired = errors.New("If-Match header with the current ETag is required")
+	ErrIfMatchInvalid  = errors.New("If-Match header does not contain a valid ETag")
+)
+
+// ETag строит сильный ETag из версии записи
+func ETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// IfMatchVersion возвращает версию из заголовка If-Match; для If-Match: * возвращается 0
+func IfMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return 0, ErrIfMatchRequired
+	}
+	if header == "*" {
+		return 0, nil
+	}
+	// If-Match использует сильное сравнение, поэтому слабые и составные ETag не подходят
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || version <= 0 || header != ETag(version) {
+		return 0, ErrIfMatchInvalid
+	}
+	return version, nil
+}
+
+// NotModified проверяет If-None-Match по слабому сравнению, как того требует RFC 9110
+func NotModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
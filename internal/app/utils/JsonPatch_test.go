@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPatch_MergePatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		document string
+		patch    string
+		expected string
+	}{
+		{
+			name:     "Test 1: Replace field",
+			document: `{"title": "Old", "author": "A"}`,
+			patch:    `{"title": "New"}`,
+			expected: `{"title": "New", "author": "A"}`,
+		},
+		{
+			name:     "Test 2: Null removes field",
+			document: `{"title": "Old", "author": "A"}`,
+			patch:    `{"author": null}`,
+			expected: `{"title": "Old"}`,
+		},
+		{
+			name:     "Test 3: Nested objects are merged, arrays replaced",
+			document: `{"a": {"b": 1, "c": 2}, "list": [1, 2]}`,
+			patch:    `{"a": {"c": null, "d": 3}, "list": [3]}`,
+			expected: `{"a": {"b": 1, "d": 3}, "list": [3]}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := ApplyPatch("application/merge-patch+json; charset=utf-8", []byte(testCase.document), []byte(testCase.patch))
+			assert.NoError(t, err)
+			assert.JSONEq(t, testCase.expected, string(result))
+		})
+	}
+}
+
+func TestApplyPatch_JSONPatch(t *testing.T) {
+	testCases := []struct {
+		name        string
+		document    string
+		patch       string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "Test 1: Add, replace and remove",
+			document: `{"title": "Old", "author": "A", "tags": ["x"]}`,
+			patch: `[{"op": "replace", "path": "/title", "value": "New"},
+				{"op": "add", "path": "/tags/0", "value": "w"},
+				{"op": "add", "path": "/tags/-", "value": "z"},
+				{"op": "remove", "path": "/author"}]`,
+			expected: `{"title": "New", "tags": ["w", "x", "z"]}`,
+		},
+		{
+			name:     "Test 2: Move and copy with escaped pointers",
+			document: `{"a/b": 1, "c~d": {"e": 2}}`,
+			patch: `[{"op": "move", "from": "/a~1b", "path": "/moved"},
+				{"op": "copy", "from": "/c~0d", "path": "/copied"}]`,
+			expected: `{"moved": 1, "c~d": {"e": 2}, "copied": {"e": 2}}`,
+		},
+		{
+			name:     "Test 3: Successful test",
+			document: `{"version": 3}`,
+			patch:    `[{"op": "test", "path": "/version", "value": 3}, {"op": "replace", "path": "/version", "value": 4}]`,
+			expected: `{"version": 4}`,
+		},
+		{
+			name:        "Test 4: Failed test",
+			document:    `{"title": "Old"}`,
+			patch:       `[{"op": "test", "path": "/title", "value": "Other"}]`,
+			expectedErr: ErrPatchTestFailed,
+		},
+		{
+			name:        "Test 5: Replace missing path",
+			document:    `{"title": "Old"}`,
+			patch:       `[{"op": "replace", "path": "/author", "value": "A"}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+		{
+			name:        "Test 6: Unknown operation",
+			document:    `{}`,
+			patch:       `[{"op": "merge", "path": "/a", "value": 1}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+		{
+			name:        "Test 7: Index out of range",
+			document:    `{"tags": []}`,
+			patch:       `[{"op": "add", "path": "/tags/1", "value": "x"}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := ApplyPatch(JSONPatchType, []byte(testCase.document), []byte(testCase.patch))
+			if testCase.expectedErr != nil {
+				assert.ErrorIs(t, err, testCase.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.JSONEq(t, testCase.expected, string(result))
+		})
+	}
+}
+
+func TestApplyPatch_UnsupportedType(t *testing.T) {
+	_, err := ApplyPatch("application/json", []byte(`{}`), []byte(`{}`))
+	assert.ErrorIs(t, err, ErrUnsupportedPatchType)
+}
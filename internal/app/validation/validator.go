@@ -1,26 +1,78 @@
 package validation
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
 
-	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
-
 	"github.com/go-playground/validator/v10"
 )
 
-var validate = validator.New()
+var validate = newValidator()
 
-func Validate(obj interface{}) error {
+// FieldError описывает ошибку проверки одного поля в терминах JSON, а не Go-структуры
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
 
-	if err := validate.RegisterValidation("notblank", NotBlank); err != nil {
-		wrapper.LogError(fmt.Sprintf("Error register notblank validation: %v", err),
-			"validation.Validate")
+func newValidator() *validator.Validate {
+	v := validator.New()
+	if err := v.RegisterValidation("notblank", NotBlank); err != nil {
+		panic(fmt.Sprintf("register notblank validation: %v", err))
 	}
+	// В ошибках используются имена полей из json-тегов
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+func Validate(obj interface{}) error {
 	return validate.Struct(obj)
 }
 
+// FieldErrors раскладывает ошибку Validate по полям; для прочих ошибок возвращает nil
+func FieldErrors(err error) []FieldError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+	fieldErrors := make([]FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		fieldErrors = append(fieldErrors, FieldError{Field: fieldError.Field(), Message: message(fieldError)})
+	}
+	return fieldErrors
+}
+
+func message(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "email":
+		return "must be a valid email"
+	case "max":
+		return "must be at most " + fieldError.Param()
+	case "min":
+		return "must be at least " + fieldError.Param()
+	case "gt":
+		return "must be greater than " + fieldError.Param()
+	case "gte":
+		return "must be greater than or equal to " + fieldError.Param()
+	case "lte":
+		return "must be less than or equal to " + fieldError.Param()
+	default:
+		return "failed on the " + fieldError.Tag() + " rule"
+	}
+}
+
 func NotBlank(fl validator.FieldLevel) bool {
 	field := fl.Field()
 
@@ -1,13 +1,21 @@
 package wrapper
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
-	"os"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/Ablyamitov/simple-rest/internal/app/validation"
+
 	"github.com/google/uuid"
 )
 
+const ProblemContentType = "application/problem+json"
+
 type Response struct {
 	ErrorID   string `json:"error_id"`
 	Message   string `json:"message"`
@@ -15,6 +23,18 @@ type Response struct {
 	Timestamp string `json:"timestamp"`
 }
 
+// Problem - тело ошибки по RFC 7807; error_id и request_id совпадают с записью в логе
+type Problem struct {
+	Type      string                  `json:"type"`
+	Title     string                  `json:"title"`
+	Status    int                     `json:"status"`
+	Detail    string                  `json:"detail,omitempty"`
+	Instance  string                  `json:"instance,omitempty"`
+	ErrorID   string                  `json:"error_id"`
+	RequestID string                  `json:"request_id,omitempty"`
+	Errors    []validation.FieldError `json:"errors,omitempty"`
+}
+
 func NewErrorResponse(err string, method string) *Response {
 	return &Response{
 		ErrorID:   uuid.New().String(),
@@ -24,17 +44,47 @@ func NewErrorResponse(err string, method string) *Response {
 	}
 }
 
-func LogError(err string, method string) {
-	logger := setupLogger()
+// LogError пишет ошибку в лог запроса из ctx и возвращает её error_id
+func LogError(ctx context.Context, err string, method string) string {
 	response := NewErrorResponse(err, method)
-	logger.Error("Error occurred",
+	Logger(ctx).Error("Error occurred",
 		slog.String("error_id", response.ErrorID),
 		slog.String("message", response.Message),
 		slog.String("method", response.Method),
 		slog.String("timestamp", response.Timestamp),
 	)
+	return response.ErrorID
 }
 
-func setupLogger() *slog.Logger {
-	return slog.New(slog.NewTextHandler(os.Stdout, nil))
+// WriteError логирует err и отвечает клиенту application/problem+json.
+// Для 5xx текст ошибки остаётся только в логе, клиент получает error_id
+func WriteError(w http.ResponseWriter, r *http.Request, status int, err error, method string) {
+	problem := &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    err.Error(),
+		Instance:  r.URL.Path,
+		ErrorID:   LogError(r.Context(), err.Error(), method),
+		RequestID: RequestID(r.Context()),
+	}
+
+	if fieldErrors := validation.FieldErrors(err); fieldErrors != nil {
+		details := make([]string, 0, len(fieldErrors))
+		for _, fieldError := range fieldErrors {
+			details = append(details, fmt.Sprintf("%s %s", fieldError.Field, fieldError.Message))
+		}
+		problem.Detail = strings.Join(details, "; ")
+		problem.Errors = fieldErrors
+	}
+	if status >= http.StatusInternalServerError {
+		problem.Detail = "unexpected error, see error_id in the server log"
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		LogError(r.Context(), err.Error(), "wrapper.WriteError")
+	}
 }
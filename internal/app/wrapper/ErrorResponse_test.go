@@ -0,0 +1,61 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteError(t *testing.T) {
+	testCases := []struct {
+		name           string
+		status         int
+		err            error
+		expectedDetail string
+	}{
+		{
+			name:           "Test 1: Client error keeps the message",
+			status:         http.StatusConflict,
+			err:            errors.New("record has active loans"),
+			expectedDetail: "record has active loans",
+		},
+		{
+			name:           "Test 2: Domain error keeps its message",
+			status:         http.StatusNotFound,
+			err:            domain.ErrBookNotFound,
+			expectedDetail: "book not found",
+		},
+		{
+			name:           "Test 3: Server error is hidden",
+			status:         http.StatusInternalServerError,
+			err:            errors.New(`pq: relation "books" does not exist`),
+			expectedDetail: "unexpected error, see error_id in the server log",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/books/1", nil)
+			w := httptest.NewRecorder()
+			WriteError(w, req, testCase.status, testCase.err, "TestWriteError")
+
+			assert.Equal(t, testCase.status, w.Code)
+			assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+
+			var problem Problem
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+			assert.Equal(t, "about:blank", problem.Type)
+			assert.Equal(t, http.StatusText(testCase.status), problem.Title)
+			assert.Equal(t, testCase.status, problem.Status)
+			assert.Equal(t, testCase.expectedDetail, problem.Detail)
+			assert.Equal(t, "/api/v1/books/1", problem.Instance)
+			assert.NotEmpty(t, problem.ErrorID)
+		})
+	}
+}
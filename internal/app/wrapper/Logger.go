@@ -0,0 +1,119 @@
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type requestLogContextKey struct{}
+
+// requestLog - атрибуты запроса, общие для всех его записей в логе.
+// Хранится по указателю, чтобы внутренние middleware (например, авторизация)
+// могли дополнить логгер, который потом увидит и внешний access log
+type requestLog struct {
+	mu        sync.RWMutex
+	requestID string
+	logger    *slog.Logger
+}
+
+// ParseLogLevel разбирает уровень debug, info, warn или error
+func ParseLogLevel(level string) (slog.Level, error) {
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		return logLevel, fmt.Errorf("unknown log level %q", level)
+	}
+	return logLevel, nil
+}
+
+// NewLogger создаёт логгер в формате json или text. Чтобы менять уровень на лету, передайте *slog.LevelVar
+func NewLogger(output io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(output, options)
+	case "text", "":
+		handler = slog.NewTextHandler(output, options)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected json or text", format)
+	}
+	return slog.New(contextHandler{Handler: handler}), nil
+}
+
+// contextHandler дописывает request_id к записям slog.*Context, сделанным там,
+// где логгера запроса нет, например в репозиториях. Логгер запроса уже содержит
+// request_id, поэтому для него атрибут не дублируется
+type contextHandler struct {
+	slog.Handler
+	hasRequestID bool
+}
+
+func (handler contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" && !handler.hasRequestID {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hasRequestID := handler.hasRequestID
+	for _, attr := range attrs {
+		hasRequestID = hasRequestID || attr.Key == "request_id"
+	}
+	return contextHandler{Handler: handler.Handler.WithAttrs(attrs), hasRequestID: hasRequestID}
+}
+
+func (handler contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: handler.Handler.WithGroup(name), hasRequestID: handler.hasRequestID}
+}
+
+// WithRequestLogger кладёт в контекст логгер запроса с его request_id
+func WithRequestLogger(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestLogContextKey{}, &requestLog{
+		requestID: requestID,
+		logger:    slog.Default().With(slog.String("request_id", requestID)),
+	})
+}
+
+// AddLogAttrs дополняет логгер запроса атрибутами, например пользователем после авторизации
+func AddLogAttrs(ctx context.Context, args ...any) {
+	entry, ok := ctx.Value(requestLogContextKey{}).(*requestLog)
+	if !ok {
+		return
+	}
+	entry.mu.Lock()
+	entry.logger = entry.logger.With(args...)
+	entry.mu.Unlock()
+}
+
+// Logger возвращает логгер запроса с маршрутом chi, а вне запроса - логгер по умолчанию
+func Logger(ctx context.Context) *slog.Logger {
+	entry, ok := ctx.Value(requestLogContextKey{}).(*requestLog)
+	if !ok {
+		return slog.Default()
+	}
+	entry.mu.RLock()
+	logger := entry.logger
+	entry.mu.RUnlock()
+
+	if routeContext := chi.RouteContext(ctx); routeContext != nil {
+		if route := routeContext.RoutePattern(); route != "" {
+			logger = logger.With(slog.String("route", route))
+		}
+	}
+	return logger
+}
+
+// RequestID возвращает идентификатор текущего запроса или пустую строку
+func RequestID(ctx context.Context) string {
+	if entry, ok := ctx.Value(requestLogContextKey{}).(*requestLog); ok {
+		return entry.requestID
+	}
+	return ""
+}
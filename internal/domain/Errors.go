@@ -0,0 +1,37 @@
+package domain
+
+import "errors"
+
+// Виды доменных ошибок. Конкретные ошибки создаются через NewError и
+// сравниваются с видом через errors.Is, а их текст можно показывать клиенту
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrBookUnavailable = errors.New("book is not available")
+	ErrLimitExceeded   = errors.New("limit exceeded")
+	ErrVersionMismatch = errors.New("record was modified by another request")
+)
+
+var (
+	ErrActiveLoans  = NewError(ErrConflict, "record has active loans")
+	ErrUserNotFound = NewError(ErrNotFound, "user not found")
+	ErrBookNotFound = NewError(ErrNotFound, "book not found")
+	ErrLoanNotFound = NewError(ErrNotFound, "loan not found")
+)
+
+type Error struct {
+	kind    error
+	message string
+}
+
+func NewError(kind error, message string) error {
+	return &Error{kind: kind, message: message}
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) Unwrap() error {
+	return e.kind
+}
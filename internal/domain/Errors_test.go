@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	err := fmt.Errorf("take book: %w", NewError(ErrLimitExceeded, "user already has 5 active loans"))
+
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+	assert.False(t, errors.Is(err, ErrConflict))
+	assert.Equal(t, "take book: user already has 5 active loans", err.Error())
+	assert.True(t, errors.Is(ErrActiveLoans, ErrConflict))
+}
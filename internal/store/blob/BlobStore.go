@@ -0,0 +1,22 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+type Object struct {
+	Data         []byte
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+}
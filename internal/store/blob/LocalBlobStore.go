@@ -0,0 +1,91 @@
+package blob
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type LocalBlobStore struct {
+	Root string
+}
+
+func NewLocalBlobStore(root string) BlobStore {
+	return &LocalBlobStore{Root: root}
+}
+
+func (localBlobStore *LocalBlobStore) Put(_ context.Context, key string, data []byte, _ string) error {
+	path, err := localBlobStore.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы читатели не увидели недописанный объект
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (localBlobStore *LocalBlobStore) Get(_ context.Context, key string) (*Object, error) {
+	path, err := localBlobStore.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{
+		Data:         data,
+		ContentType:  http.DetectContentType(data),
+		ETag:         fmt.Sprintf(`"%x"`, md5.Sum(data)),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+func (localBlobStore *LocalBlobStore) Delete(_ context.Context, key string) error {
+	path, err := localBlobStore.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (localBlobStore *LocalBlobStore) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if strings.Contains(key, "..") || cleaned == "/" {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(localBlobStore.Root, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,93 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Локальная замена S3: хранит объекты в памяти и проверяет подпись запроса
+func newFakeS3(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	types := map[string]string{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") || !strings.Contains(auth, "Signature=") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPut:
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatalf("could not read body: %v", err)
+			}
+			sum := sha256.Sum256(body)
+			if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = body
+			types[r.URL.Path] = r.Header.Get("Content-Type")
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", types[r.URL.Path])
+			w.Header().Set("ETag", `"etag"`)
+			_, _ = w.Write(body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestS3BlobStore(t *testing.T) {
+	server := newFakeS3(t)
+	defer server.Close()
+
+	store := NewS3BlobStore(server.URL, "us-east-1", "covers", "access", "secret")
+	ctx := context.Background()
+
+	err := store.Put(ctx, "covers/1/small.jpg", []byte("jpeg data"), "image/jpeg")
+	assert.NoError(t, err)
+
+	object, err := store.Get(ctx, "covers/1/small.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("jpeg data"), object.Data)
+	assert.Equal(t, "image/jpeg", object.ContentType)
+	assert.Equal(t, `"etag"`, object.ETag)
+
+	err = store.Delete(ctx, "covers/1/small.jpg")
+	assert.NoError(t, err)
+
+	_, err = store.Get(ctx, "covers/1/small.jpg")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestS3BlobStore_Forbidden(t *testing.T) {
+	server := newFakeS3(t)
+	defer server.Close()
+
+	store := NewS3BlobStore(server.URL, "us-east-1", "covers", "wrong", "secret")
+
+	err := store.Put(context.Background(), "covers/1/small.jpg", []byte("jpeg data"), "image/jpeg")
+	assert.Error(t, err)
+}
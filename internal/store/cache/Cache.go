@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss - ключа нет в кеше или его срок истёк
+var ErrMiss = errors.New("cache miss")
+
+// tagPrefix - пространство ключей, под которыми хранятся множества ключей тега
+const tagPrefix = "tag:"
+
+// Cache хранит готовые байты с TTL; как их кодировать, решает EntityCache
+type Cache interface {
+	// Get возвращает ErrMiss, если ключа нет, и другую ошибку, если хранилище недоступно
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Tag связывает key с тегами на ttl, чтобы DeleteTagged удалил его вместе с ними
+	Tag(ctx context.Context, key string, ttl time.Duration, tags ...string) error
+	// DeleteTagged удаляет ключи, связанные с тегами, и сами теги
+	DeleteTagged(ctx context.Context, tags ...string) error
+}
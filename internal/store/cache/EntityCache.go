@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Policy - сроки хранения одного вида сущностей.
+// Запись свежая TTL, ещё StaleTTL она отдаётся как есть, пока в фоне читается новая.
+// Отсутствие сущности запоминается на NegativeTTL; 0 отключает отрицательное кеширование
+type Policy struct {
+	TTL         time.Duration
+	StaleTTL    time.Duration
+	NegativeTTL time.Duration
+}
+
+// Observer получает попадания и промахи; metrics.Metrics ему удовлетворяет
+type Observer interface {
+	CacheHit(cache string)
+	CacheMiss(cache string)
+}
+
+// entry - то, что лежит в кеше. Missing - запомненное отсутствие сущности
+type entry[T any] struct {
+	Value      *T        `json:"value,omitempty"`
+	Missing    bool      `json:"missing,omitempty"`
+	FreshUntil time.Time `json:"fresh_until"`
+}
+
+// EntityCache кеширует сущности по id под ключами "<name>:<id>".
+// Одновременные промахи по одному ключу объединяются в одно чтение из базы
+type EntityCache[T any] struct {
+	Cache    Cache
+	Name     string
+	Policy   Policy
+	NotFound error
+	Observer Observer
+	// Dependencies возвращает теги сущности - ключи других сущностей, копии которых она содержит.
+	// InvalidateTagged с таким тегом сбрасывает и её
+	Dependencies func(value *T) []string
+	group        singleflight.Group
+	// generation растёт при каждой инвалидации: чтение, начатое до неё, не записывает результат в кеш
+	generation atomic.Uint64
+	now        func() time.Time
+}
+
+// NewEntityCache создаёт кеш сущностей; load, вернувший notFound, кешируется как отсутствие сущности
+func NewEntityCache[T any](cache Cache, name string, policy Policy, notFound error, observer Observer) *EntityCache[T] {
+	return &EntityCache[T]{Cache: cache, Name: name, Policy: policy, NotFound: notFound, Observer: observer, now: time.Now}
+}
+
+func (entityCache *EntityCache[T]) Key(id int) string {
+	return fmt.Sprintf("%s:%d", entityCache.Name, id)
+}
+
+// Get отдаёт сущность из кеша или читает её через load. Каждый вызывающий получает собственную копию
+func (entityCache *EntityCache[T]) Get(ctx context.Context, id int, load func(ctx context.Context) (*T, error)) (*T, error) {
+	key := entityCache.Key(id)
+	if cached, ok := entityCache.lookup(ctx, key); ok {
+		entityCache.Observer.CacheHit(entityCache.Name)
+		if !entityCache.now().Before(cached.FreshUntil) {
+			entityCache.refresh(ctx, key, load)
+		}
+		return entityCache.result(cached)
+	}
+	entityCache.Observer.CacheMiss(entityCache.Name)
+
+	// Чтение не отменяется вместе с запросом, который его начал: его результата могут ждать другие
+	loaded, err, _ := entityCache.group.Do(key, func() (any, error) {
+		return entityCache.load(context.WithoutCancel(ctx), key, load)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var cached entry[T]
+	if err := json.Unmarshal(loaded.([]byte), &cached); err != nil {
+		return nil, err
+	}
+	return entityCache.result(cached)
+}
+
+// Invalidate удаляет сущности из кеша после изменения в базе
+func (entityCache *EntityCache[T]) Invalidate(ctx context.Context, ids ...int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	entityCache.generation.Add(1)
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = entityCache.Key(id)
+		entityCache.group.Forget(keys[i])
+	}
+	return entityCache.Cache.Delete(ctx, keys...)
+}
+
+// InvalidateTagged удаляет из кеша сущности, зависящие от тегов
+func (entityCache *EntityCache[T]) InvalidateTagged(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	entityCache.generation.Add(1)
+	return entityCache.Cache.DeleteTagged(ctx, tags...)
+}
+
+// lookup считает промахом и недоступность кеша, и записи старого формата без fresh_until
+func (entityCache *EntityCache[T]) lookup(ctx context.Context, key string) (entry[T], bool) {
+	var cached entry[T]
+	data, err := entityCache.Cache.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrMiss) {
+			slog.DebugContext(ctx, "Cache read failed", slog.String("key", key), slog.String("error", err.Error()))
+		}
+		return cached, false
+	}
+	if err := json.Unmarshal(data, &cached); err != nil || cached.FreshUntil.IsZero() {
+		return cached, false
+	}
+	return cached, true
+}
+
+// refresh перечитывает устаревшую запись в фоне; повторные вызовы присоединяются к уже идущему чтению
+func (entityCache *EntityCache[T]) refresh(ctx context.Context, key string, load func(ctx context.Context) (*T, error)) {
+	entityCache.group.DoChan(key, func() (any, error) {
+		return entityCache.load(context.WithoutCancel(ctx), key, load)
+	})
+}
+
+// load читает сущность и кладёт её в кеш; возвращает закодированную запись, чтобы каждый получил свою копию
+func (entityCache *EntityCache[T]) load(ctx context.Context, key string, load func(ctx context.Context) (*T, error)) ([]byte, error) {
+	generation := entityCache.generation.Load()
+	value, err := load(ctx)
+
+	cached := entry[T]{Value: value}
+	ttl := entityCache.Policy.TTL
+	switch {
+	case errors.Is(err, entityCache.NotFound) && entityCache.Policy.NegativeTTL > 0:
+		cached = entry[T]{Missing: true}
+		ttl = entityCache.Policy.NegativeTTL
+	case err != nil:
+		return nil, err
+	}
+	cached.FreshUntil = entityCache.now().Add(ttl)
+
+	data, marshalErr := json.Marshal(cached)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	if entityCache.generation.Load() == generation {
+		// Ошибка записи не мешает ответу: сущность уже прочитана из базы
+		if storeErr := entityCache.store(ctx, key, data, ttl+entityCache.Policy.StaleTTL, value); storeErr != nil {
+			slog.DebugContext(ctx, "Cache write failed", slog.String("key", key), slog.String("error", storeErr.Error()))
+		}
+		// Инвалидация между проверкой и записью не увидела новую запись, поэтому запись удаляется здесь
+		if entityCache.generation.Load() != generation {
+			if deleteErr := entityCache.Cache.Delete(ctx, key); deleteErr != nil {
+				slog.DebugContext(ctx, "Cache delete failed", slog.String("key", key), slog.String("error", deleteErr.Error()))
+			}
+		}
+	}
+	return data, nil
+}
+
+// store записывает теги раньше значения: значение без тегов нельзя было бы сбросить вместе с зависимостями
+func (entityCache *EntityCache[T]) store(ctx context.Context, key string, data []byte, ttl time.Duration, value *T) error {
+	if value != nil && entityCache.Dependencies != nil {
+		if err := entityCache.Cache.Tag(ctx, key, ttl, entityCache.Dependencies(value)...); err != nil {
+			return err
+		}
+	}
+	return entityCache.Cache.Set(ctx, key, data, ttl)
+}
+
+func (entityCache *EntityCache[T]) result(cached entry[T]) (*T, error) {
+	if cached.Missing || cached.Value == nil {
+		return nil, entityCache.NotFound
+	}
+	return cached.Value, nil
+}
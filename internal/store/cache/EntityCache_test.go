@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type book struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+var errNotFound = errors.New("not found")
+
+type countingObserver struct {
+	hits, misses atomic.Int64
+}
+
+func (observer *countingObserver) CacheHit(string)  { observer.hits.Add(1) }
+func (observer *countingObserver) CacheMiss(string) { observer.misses.Add(1) }
+
+func newTestCache(policy Policy) (*EntityCache[book], *countingObserver, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	memoryCache := NewMemoryCache(10)
+	memoryCache.now = func() time.Time { return now }
+	observer := &countingObserver{}
+	entityCache := NewEntityCache[book](memoryCache, "book", policy, errNotFound, observer)
+	entityCache.now = func() time.Time { return now }
+	return entityCache, observer, &now
+}
+
+func TestEntityCache_CoalescesConcurrentMisses(t *testing.T) {
+	entityCache, observer, _ := newTestCache(Policy{TTL: time.Minute})
+	var loads atomic.Int64
+	release := make(chan struct{})
+	load := func(ctx context.Context) (*book, error) {
+		loads.Add(1)
+		<-release
+		return &book{ID: 1, Title: "Dune"}, nil
+	}
+
+	const callers = 20
+	var wg sync.WaitGroup
+	results := make([]*book, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = entityCache.Get(context.Background(), 1, load)
+		}(i)
+	}
+	// ждём, пока все вызовы зафиксируют промах и встанут в очередь за одним чтением
+	require.Eventually(t, func() bool { return observer.misses.Load() == callers }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), loads.Load())
+	for _, result := range results {
+		require.NotNil(t, result)
+		assert.Equal(t, "Dune", result.Title)
+	}
+	// каждый получил свою копию
+	results[0].Title = "changed"
+	assert.Equal(t, "Dune", results[1].Title)
+}
+
+func TestEntityCache_CachesMissingEntities(t *testing.T) {
+	entityCache, _, now := newTestCache(Policy{TTL: time.Minute, NegativeTTL: 10 * time.Second})
+	var loads int
+	load := func(ctx context.Context) (*book, error) {
+		loads++
+		return nil, errNotFound
+	}
+
+	_, err := entityCache.Get(context.Background(), 1, load)
+	assert.ErrorIs(t, err, errNotFound)
+	_, err = entityCache.Get(context.Background(), 1, load)
+	assert.ErrorIs(t, err, errNotFound)
+	assert.Equal(t, 1, loads)
+
+	*now = now.Add(10 * time.Second)
+	_, err = entityCache.Get(context.Background(), 1, load)
+	assert.ErrorIs(t, err, errNotFound)
+	assert.Equal(t, 2, loads)
+}
+
+func TestEntityCache_ServesStaleWhileRefreshing(t *testing.T) {
+	entityCache, _, now := newTestCache(Policy{TTL: time.Minute, StaleTTL: time.Minute})
+	var title atomic.Value
+	title.Store("Dune")
+	load := func(ctx context.Context) (*book, error) {
+		return &book{ID: 1, Title: title.Load().(string)}, nil
+	}
+
+	_, err := entityCache.Get(context.Background(), 1, load)
+	require.NoError(t, err)
+
+	title.Store("Dune Messiah")
+	*now = now.Add(90 * time.Second)
+	stale, err := entityCache.Get(context.Background(), 1, load)
+	require.NoError(t, err)
+	assert.Equal(t, "Dune", stale.Title)
+
+	// фоновое чтение обновляет запись, и следующий запрос получает новую версию
+	require.Eventually(t, func() bool {
+		fresh, err := entityCache.Get(context.Background(), 1, load)
+		return err == nil && fresh.Title == "Dune Messiah"
+	}, time.Second, time.Millisecond)
+}
+
+func TestEntityCache_InvalidateDuringLoadSkipsWrite(t *testing.T) {
+	entityCache, _, _ := newTestCache(Policy{TTL: time.Minute})
+	var loads int
+	load := func(ctx context.Context) (*book, error) {
+		loads++
+		if loads == 1 {
+			// изменение в базе произошло, пока читалась старая версия
+			require.NoError(t, entityCache.Invalidate(ctx, 1))
+			return &book{ID: 1, Title: "old"}, nil
+		}
+		return &book{ID: 1, Title: "new"}, nil
+	}
+
+	first, err := entityCache.Get(context.Background(), 1, load)
+	require.NoError(t, err)
+	assert.Equal(t, "old", first.Title)
+
+	second, err := entityCache.Get(context.Background(), 1, load)
+	require.NoError(t, err)
+	assert.Equal(t, "new", second.Title)
+	assert.Equal(t, 2, loads)
+}
+
+type reader struct {
+	ID    int     `json:"id"`
+	Books []*book `json:"books"`
+}
+
+func TestEntityCache_InvalidateTagged(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	memoryCache := NewMemoryCache(10)
+	memoryCache.now = func() time.Time { return now }
+	readers := NewEntityCache[reader](memoryCache, "user", Policy{TTL: time.Minute}, errNotFound, &countingObserver{})
+	readers.Dependencies = func(value *reader) []string {
+		tags := make([]string, len(value.Books))
+		for i, book := range value.Books {
+			tags[i] = "book:" + strconv.Itoa(book.ID)
+		}
+		return tags
+	}
+	loads := map[int]int{}
+	load := func(id int, bookId int) func(ctx context.Context) (*reader, error) {
+		return func(ctx context.Context) (*reader, error) {
+			loads[id]++
+			return &reader{ID: id, Books: []*book{{ID: bookId}}}, nil
+		}
+	}
+
+	_, err := readers.Get(context.Background(), 1, load(1, 5))
+	require.NoError(t, err)
+	_, err = readers.Get(context.Background(), 2, load(2, 6))
+	require.NoError(t, err)
+
+	require.NoError(t, readers.InvalidateTagged(context.Background(), "book:5"))
+	_, err = readers.Get(context.Background(), 1, load(1, 5))
+	require.NoError(t, err)
+	_, err = readers.Get(context.Background(), 2, load(2, 6))
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 2, 2: 1}, loads)
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// FallbackCache работает с Primary (Redis), а пока он недоступен - с Fallback (память процесса).
+// После ошибки Primary не опрашивается RetryAfter, чтобы запросы не ждали таймаутов подключения.
+// Удаление, в том числе по тегам, всегда выполняется и в Fallback, чтобы при следующем отказе Primary он не отдал устаревшие значения.
+// Удаления, не дошедшие до Primary во время отказа, теряются: такие ключи живут до конца своего TTL
+type FallbackCache struct {
+	Primary    Cache
+	Fallback   Cache
+	RetryAfter time.Duration
+	downUntil  atomic.Int64
+	now        func() time.Time
+}
+
+func NewFallbackCache(primary Cache, fallback Cache, retryAfter time.Duration) *FallbackCache {
+	return &FallbackCache{Primary: primary, Fallback: fallback, RetryAfter: retryAfter, now: time.Now}
+}
+
+func (fallbackCache *FallbackCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if fallbackCache.primaryUp() {
+		value, err := fallbackCache.Primary.Get(ctx, key)
+		if !fallbackCache.failed(ctx, err) {
+			return value, err
+		}
+	}
+	return fallbackCache.Fallback.Get(ctx, key)
+}
+
+func (fallbackCache *FallbackCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if fallbackCache.primaryUp() {
+		err := fallbackCache.Primary.Set(ctx, key, value, ttl)
+		if !fallbackCache.failed(ctx, err) {
+			return err
+		}
+	}
+	return fallbackCache.Fallback.Set(ctx, key, value, ttl)
+}
+
+// Delete удаляет ключи из Fallback, даже если Primary ответил ошибкой, в том числе из-за отмены ctx
+func (fallbackCache *FallbackCache) Delete(ctx context.Context, keys ...string) error {
+	var primaryErr error
+	if fallbackCache.primaryUp() {
+		if err := fallbackCache.Primary.Delete(ctx, keys...); err != nil && !fallbackCache.failed(ctx, err) {
+			primaryErr = err
+		}
+	}
+	return errors.Join(primaryErr, fallbackCache.Fallback.Delete(ctx, keys...))
+}
+
+func (fallbackCache *FallbackCache) Tag(ctx context.Context, key string, ttl time.Duration, tags ...string) error {
+	if fallbackCache.primaryUp() {
+		err := fallbackCache.Primary.Tag(ctx, key, ttl, tags...)
+		if !fallbackCache.failed(ctx, err) {
+			return err
+		}
+	}
+	return fallbackCache.Fallback.Tag(ctx, key, ttl, tags...)
+}
+
+func (fallbackCache *FallbackCache) DeleteTagged(ctx context.Context, tags ...string) error {
+	var primaryErr error
+	if fallbackCache.primaryUp() {
+		if err := fallbackCache.Primary.DeleteTagged(ctx, tags...); err != nil && !fallbackCache.failed(ctx, err) {
+			primaryErr = err
+		}
+	}
+	return errors.Join(primaryErr, fallbackCache.Fallback.DeleteTagged(ctx, tags...))
+}
+
+func (fallbackCache *FallbackCache) primaryUp() bool {
+	return fallbackCache.now().UnixNano() >= fallbackCache.downUntil.Load()
+}
+
+// failed отмечает Primary недоступным, если err - ошибка хранилища, а не промах или отмена запроса
+func (fallbackCache *FallbackCache) failed(ctx context.Context, err error) bool {
+	if err == nil || errors.Is(err, ErrMiss) || ctx.Err() != nil {
+		return false
+	}
+	downUntil := fallbackCache.now().Add(fallbackCache.RetryAfter).UnixNano()
+	if previous := fallbackCache.downUntil.Swap(downUntil); previous < fallbackCache.now().UnixNano() {
+		slog.WarnContext(ctx, "Cache is unavailable, using the in-memory cache",
+			slog.String("error", err.Error()), slog.Duration("retry_after", fallbackCache.RetryAfter))
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenCache имитирует Redis, который можно выключить
+type brokenCache struct {
+	*MemoryCache
+	down  bool
+	calls int
+}
+
+var errConnectionRefused = errors.New("connection refused")
+
+func (broken *brokenCache) Get(ctx context.Context, key string) ([]byte, error) {
+	broken.calls++
+	if broken.down {
+		return nil, errConnectionRefused
+	}
+	return broken.MemoryCache.Get(ctx, key)
+}
+
+func (broken *brokenCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	broken.calls++
+	if broken.down {
+		return errConnectionRefused
+	}
+	return broken.MemoryCache.Set(ctx, key, value, ttl)
+}
+
+func (broken *brokenCache) Delete(ctx context.Context, keys ...string) error {
+	broken.calls++
+	if broken.down {
+		return errConnectionRefused
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return broken.MemoryCache.Delete(ctx, keys...)
+}
+
+func TestFallbackCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	primary := &brokenCache{MemoryCache: NewMemoryCache(10)}
+	fallback := NewMemoryCache(10)
+	fallbackCache := NewFallbackCache(primary, fallback, 5*time.Second)
+	fallbackCache.now = func() time.Time { return now }
+
+	require.NoError(t, fallbackCache.Set(ctx, "book:1", []byte("redis"), 0))
+	assert.Zero(t, fallback.Len())
+
+	// Redis упал: значения пишутся в память, а Redis не опрашивается до истечения RetryAfter
+	primary.down = true
+	_, err := fallbackCache.Get(ctx, "book:1")
+	assert.ErrorIs(t, err, ErrMiss)
+	require.NoError(t, fallbackCache.Set(ctx, "book:1", []byte("memory"), 0))
+	value, err := fallbackCache.Get(ctx, "book:1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("memory"), value)
+	assert.Equal(t, 2, primary.calls)
+
+	// Redis вернулся: после RetryAfter чтения снова идут в него, а удаления доходят до обоих кешей
+	primary.down = false
+	now = now.Add(5 * time.Second)
+	value, err = fallbackCache.Get(ctx, "book:1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("redis"), value)
+
+	require.NoError(t, fallbackCache.Delete(ctx, "book:1"))
+	_, err = fallback.Get(ctx, "book:1")
+	assert.ErrorIs(t, err, ErrMiss)
+	_, err = primary.MemoryCache.Get(ctx, "book:1")
+	assert.ErrorIs(t, err, ErrMiss)
+}
+
+// Отменённое удаление не доходит до Redis, но из памяти ключ всё равно удаляется
+func TestFallbackCache_DeleteWithCancelledContext(t *testing.T) {
+	primary := &brokenCache{MemoryCache: NewMemoryCache(10)}
+	fallback := NewMemoryCache(10)
+	fallbackCache := NewFallbackCache(primary, fallback, 5*time.Second)
+	require.NoError(t, fallback.Set(context.Background(), "book:1", []byte("memory"), 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, fallbackCache.Delete(ctx, "book:1"), context.Canceled)
+	_, err := fallback.Get(context.Background(), "book:1")
+	assert.ErrorIs(t, err, ErrMiss)
+	// Отмена не считается отказом Redis
+	assert.True(t, fallbackCache.primaryUp())
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryCache - LRU в памяти процесса: при переполнении вытесняется ключ, который дольше всех не читали.
+// Теги хранятся в том же LRU; вытесненный тег уносит с собой свои ключи, иначе их было бы нечем сбросить
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+	now        func() time.Time
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	// members - ключи тега; nil у обычных значений
+	members map[string]struct{}
+}
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+func (memoryCache *MemoryCache) Get(_ context.Context, key string) ([]byte, error) {
+	memoryCache.mu.Lock()
+	defer memoryCache.mu.Unlock()
+
+	element, ok := memoryCache.entries[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && !memoryCache.now().Before(entry.expiresAt) {
+		memoryCache.remove(element)
+		return nil, ErrMiss
+	}
+	memoryCache.order.MoveToFront(element)
+	return entry.value, nil
+}
+
+// Set копирует value, поэтому вызывающий может переиспользовать свой буфер; ttl <= 0 - без срока
+func (memoryCache *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	memoryCache.mu.Lock()
+	defer memoryCache.mu.Unlock()
+
+	entry := &memoryEntry{key: key, value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expiresAt = memoryCache.now().Add(ttl)
+	}
+	if element, ok := memoryCache.entries[key]; ok {
+		element.Value = entry
+		memoryCache.order.MoveToFront(element)
+		return nil
+	}
+	memoryCache.push(entry)
+	return nil
+}
+
+func (memoryCache *MemoryCache) Delete(_ context.Context, keys ...string) error {
+	memoryCache.mu.Lock()
+	defer memoryCache.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := memoryCache.entries[key]; ok {
+			memoryCache.remove(element)
+		}
+	}
+	return nil
+}
+
+func (memoryCache *MemoryCache) Tag(_ context.Context, key string, ttl time.Duration, tags ...string) error {
+	memoryCache.mu.Lock()
+	defer memoryCache.mu.Unlock()
+
+	now := memoryCache.now()
+	for _, tag := range tags {
+		element, ok := memoryCache.entries[tagPrefix+tag]
+		if !ok {
+			entry := &memoryEntry{key: tagPrefix + tag, members: map[string]struct{}{key: {}}}
+			if ttl > 0 {
+				entry.expiresAt = now.Add(ttl)
+			}
+			memoryCache.push(entry)
+			continue
+		}
+		// Тег живёт, пока жив самый долгий из его ключей
+		entry := element.Value.(*memoryEntry)
+		entry.members[key] = struct{}{}
+		if ttl <= 0 {
+			entry.expiresAt = time.Time{}
+		} else if expiresAt := now.Add(ttl); !entry.expiresAt.IsZero() && expiresAt.After(entry.expiresAt) {
+			entry.expiresAt = expiresAt
+		}
+		memoryCache.order.MoveToFront(element)
+	}
+	return nil
+}
+
+func (memoryCache *MemoryCache) DeleteTagged(_ context.Context, tags ...string) error {
+	memoryCache.mu.Lock()
+	defer memoryCache.mu.Unlock()
+
+	for _, tag := range tags {
+		if element, ok := memoryCache.entries[tagPrefix+tag]; ok {
+			memoryCache.remove(element)
+		}
+	}
+	return nil
+}
+
+func (memoryCache *MemoryCache) Len() int {
+	memoryCache.mu.Lock()
+	defer memoryCache.mu.Unlock()
+	return memoryCache.order.Len()
+}
+
+// push добавляет новый ключ и вытесняет лишние
+func (memoryCache *MemoryCache) push(entry *memoryEntry) {
+	memoryCache.entries[entry.key] = memoryCache.order.PushFront(entry)
+	for memoryCache.maxEntries > 0 && memoryCache.order.Len() > memoryCache.maxEntries {
+		memoryCache.remove(memoryCache.order.Back())
+	}
+}
+
+// remove удаляет ключ, а у тега - и все его ключи
+func (memoryCache *MemoryCache) remove(element *list.Element) {
+	entry := element.Value.(*memoryEntry)
+	memoryCache.order.Remove(element)
+	delete(memoryCache.entries, entry.key)
+	for member := range entry.members {
+		if memberElement, ok := memoryCache.entries[member]; ok {
+			memoryCache.remove(memberElement)
+		}
+	}
+}
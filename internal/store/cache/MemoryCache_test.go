@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := NewMemoryCache(2)
+	require.NoError(t, memoryCache.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, memoryCache.Set(ctx, "b", []byte("2"), 0))
+
+	// чтение делает a самым свежим, поэтому при переполнении вытесняется b
+	_, err := memoryCache.Get(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, memoryCache.Set(ctx, "c", []byte("3"), 0))
+
+	assert.Equal(t, 2, memoryCache.Len())
+	_, err = memoryCache.Get(ctx, "b")
+	assert.ErrorIs(t, err, ErrMiss)
+	value, err := memoryCache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), value)
+}
+
+func TestMemoryCache_Expires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	memoryCache := NewMemoryCache(10)
+	memoryCache.now = func() time.Time { return now }
+
+	buffer := []byte("value")
+	require.NoError(t, memoryCache.Set(ctx, "key", buffer, time.Minute))
+	buffer[0] = 'X'
+
+	now = now.Add(59 * time.Second)
+	value, err := memoryCache.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	now = now.Add(time.Second)
+	_, err = memoryCache.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrMiss)
+	assert.Zero(t, memoryCache.Len())
+}
+
+func TestMemoryCache_DeleteTagged(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := NewMemoryCache(10)
+	require.NoError(t, memoryCache.Set(ctx, "user:1", []byte("1"), 0))
+	require.NoError(t, memoryCache.Set(ctx, "user:2", []byte("2"), 0))
+	require.NoError(t, memoryCache.Tag(ctx, "user:1", 0, "book:5", "book:6"))
+	require.NoError(t, memoryCache.Tag(ctx, "user:2", 0, "book:6"))
+
+	require.NoError(t, memoryCache.DeleteTagged(ctx, "book:5"))
+	_, err := memoryCache.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, ErrMiss)
+	_, err = memoryCache.Get(ctx, "user:2")
+	assert.NoError(t, err)
+
+	require.NoError(t, memoryCache.DeleteTagged(ctx, "book:6"))
+	assert.Zero(t, memoryCache.Len())
+}
+
+// Вытесненный тег больше не может сбросить свои ключи, поэтому они вытесняются вместе с ним
+func TestMemoryCache_EvictedTagTakesItsKeys(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := NewMemoryCache(3)
+	require.NoError(t, memoryCache.Tag(ctx, "user:1", 0, "book:5"))
+	require.NoError(t, memoryCache.Set(ctx, "user:1", []byte("1"), 0))
+	require.NoError(t, memoryCache.Set(ctx, "user:2", []byte("2"), 0))
+	require.NoError(t, memoryCache.Set(ctx, "user:3", []byte("3"), 0))
+
+	_, err := memoryCache.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, ErrMiss)
+	assert.Equal(t, 2, memoryCache.Len())
+}
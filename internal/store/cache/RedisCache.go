@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisCache struct {
+	RedisClient *redis.Client
+}
+
+func NewRedisCache(redisClient *redis.Client) Cache {
+	return &RedisCache{RedisClient: redisClient}
+}
+
+func (redisCache *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := redisCache.RedisClient.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+func (redisCache *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return redisCache.RedisClient.Set(ctx, key, value, ttl).Err()
+}
+
+func (redisCache *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return redisCache.RedisClient.Del(ctx, keys...).Err()
+}
+
+// Tag хранит ключи тега в множестве Redis; срок множества продлевается каждым ключом
+func (redisCache *RedisCache) Tag(ctx context.Context, key string, ttl time.Duration, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err := redisCache.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, tag := range tags {
+			pipe.SAdd(ctx, tagPrefix+tag, key)
+			pipe.Expire(ctx, tagPrefix+tag, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// DeleteTagged забирает и удаляет множество тега одной транзакцией,
+// поэтому ключ, добавленный к тегу во время удаления, не теряется вместе с множеством
+func (redisCache *RedisCache) DeleteTagged(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	members := make([]*redis.StringSliceCmd, len(tags))
+	_, err := redisCache.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, tag := range tags {
+			members[i] = pipe.SMembers(ctx, tagPrefix+tag)
+			pipe.Del(ctx, tagPrefix+tag)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, cmd := range members {
+		keys = append(keys, cmd.Val()...)
+	}
+	return redisCache.Delete(ctx, keys...)
+}
@@ -5,16 +5,25 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Ablyamitov/simple-rest/internal/app/tracing"
 	"github.com/Ablyamitov/simple-rest/internal/app/wrapper"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func Connect(connectionUrl string) *pgx.Conn {
+func Connect(connectionUrl string) *pgxpool.Pool {
 
-	conn, err := pgx.Connect(context.Background(), connectionUrl)
+	config, err := pgxpool.ParseConfig(connectionUrl)
 	if err != nil {
-		wrapper.LogError(fmt.Sprintf("Could not connect to the database: %v", err),
+		wrapper.LogError(context.Background(), fmt.Sprintf("Could not parse the database URL: %v", err),
+			"main")
+		os.Exit(1)
+	}
+	config.ConnConfig.Tracer = tracing.NewPgxTracer()
+
+	conn, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		wrapper.LogError(context.Background(), fmt.Sprintf("Could not connect to the database: %v", err),
 			"main")
 		os.Exit(1)
 	}
@@ -1,8 +1,15 @@
 package entity
 
+import "time"
+
 type Book struct {
-	ID        int    `json:"id"`
-	Title     string `json:"title" validate:"required,notblank"`
-	Author    string `json:"author" validate:"required,notblank"`
-	Available bool   `json:"available"`
+	ID             int        `json:"id"`
+	Title          string     `json:"title" validate:"required,notblank"`
+	Author         string     `json:"author" validate:"required,notblank"`
+	Category       string     `json:"category"`
+	Available      bool       `json:"available"`
+	CoverUpdatedAt *time.Time `json:"cover_updated_at"`
+	RatingCount    int        `json:"rating_count"`
+	RatingSum      int        `json:"rating_sum"`
+	Version        int        `json:"version"`
 }
@@ -3,6 +3,7 @@ package entity
 import "github.com/dgrijalva/jwt-go"
 
 type Claims struct {
-	Role string `json:"role"`
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
 	jwt.StandardClaims
 }
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// IdempotencyKey - запрос, отправленный с заголовком Idempotency-Key, и записанный ответ на него.
+// Пока запрос выполняется, Status равен 0 и CompletedAt пуст
+type IdempotencyKey struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	Status      int
+	Headers     map[string][]string
+	Body        []byte
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+}
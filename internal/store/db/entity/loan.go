@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+type Loan struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	BookID     int        `json:"book_id"`
+	TakenDate  time.Time  `json:"taken_date"`
+	DueDate    time.Time  `json:"due_date"`
+	ReturnDate *time.Time `json:"return_date"`
+}
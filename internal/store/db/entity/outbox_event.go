@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Типы доменных событий, которые уходят во внешние системы через outbox
+const (
+	EventBookCreated    = "BookCreated"
+	EventBookUpdated    = "BookUpdated"
+	EventLoanStarted    = "LoanStarted"
+	EventLoanReturned   = "LoanReturned"
+	EventUserRegistered = "UserRegistered"
+)
+
+// OutboxEvent - доменное событие. AggregateID - id книги, займа или пользователя, которого событие касается,
+// Payload - книга или займ после изменения либо RegisteredUser
+type OutboxEvent struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID int             `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// RegisteredUser - пользователь в событии UserRegistered, без пароля
+type RegisteredUser struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
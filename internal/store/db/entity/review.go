@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusHidden   = "hidden"
+)
+
+type Review struct {
+	ID        int       `json:"id"`
+	BookID    int       `json:"book_id"`
+	UserID    int       `json:"user_id"`
+	Rating    int       `json:"rating"`
+	Text      string    `json:"text"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
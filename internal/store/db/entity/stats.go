@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+type BookLoans struct {
+	Book  Book `json:"book"`
+	Loans int  `json:"loans"`
+}
+
+type LoanLength struct {
+	Category    string  `json:"category"`
+	Returned    int     `json:"returned"`
+	AverageDays float64 `json:"average_days"`
+}
+
+type WeeklyReaders struct {
+	Week    time.Time `json:"week"`
+	Readers int       `json:"readers"`
+}
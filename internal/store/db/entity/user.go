@@ -7,4 +7,5 @@ type User struct {
 	Books    []*Book `json:"books"`
 	Password string  `json:"password" validate:"required,notblank"`
 	Role     string  `json:"role"`
+	Version  int     `json:"version"`
 }
@@ -2,65 +2,117 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/Ablyamitov/simple-rest/internal/domain"
 	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/redis/go-redis/v9"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const (
 	SELECT_ALL_BOOKS = `
-				  SELECT * 
-				  FROM books`
+				  SELECT id, title, author, category, available, cover_updated_at, rating_count, rating_sum, version 
+				  FROM books
+				  WHERE deleted_at IS NULL`
 	SELECT_BOOK_BY_ID = `
-				  SELECT id, title, author, available 
+				  SELECT id, title, author, category, available, cover_updated_at, rating_count, rating_sum, version 
 				  FROM books 
-				  WHERE id=$1`
+				  WHERE id=$1 AND deleted_at IS NULL`
 	INSERT_BOOK = `
-				  INSERT INTO books (title, author) 
-				  VALUES ($1, $2) 
-				  RETURNING books.id,books.available`
+				  INSERT INTO books (title, author, category) 
+				  VALUES ($1, $2, $3) 
+				  RETURNING books.id,books.available,books.version`
+	// Версия 0 означает If-Match: *, то есть обновление без проверки версии
 	UPDATE_BOOK = `
 				  UPDATE books 
-				  SET title = $1, author = $2 
-				  WHERE id = $3`
+				  SET title = $1, author = $2, category = $3, version = version + 1 
+				  WHERE id = $4 AND deleted_at IS NULL AND ($5 = 0 OR version = $5)
+				  RETURNING id, title, author, category, available, cover_updated_at, rating_count, rating_sum, version`
+	// Список колонок подставляется из BookPatch, значения передаются параметрами
+	PATCH_BOOK = `
+				  UPDATE books 
+				  SET %s, version = version + 1 
+				  WHERE id = $%d AND deleted_at IS NULL AND version = $%d
+				  RETURNING id, title, author, category, available, cover_updated_at, rating_count, rating_sum, version`
+	SELECT_BOOK_EXISTS = `
+				  SELECT EXISTS(
+				      SELECT 1
+				      FROM books
+				      WHERE id = $1 AND deleted_at IS NULL)`
+	UPDATE_BOOK_COVER = `
+				  UPDATE books 
+				  SET cover_updated_at = $1, version = version + 1 
+				  WHERE id = $2 AND deleted_at IS NULL
+				  RETURNING id, title, author, category, available, cover_updated_at, rating_count, rating_sum, version`
+	SELECT_BOOK_ON_LOAN_FOR_UPDATE = `
+				  SELECT EXISTS(
+				      SELECT 1
+				      FROM user_books
+				      WHERE book_id = books.id AND return_date IS NULL)
+				  FROM books
+				  WHERE id = $1 AND deleted_at IS NULL
+				  FOR UPDATE`
 	DELETE_BOOK = `
+				  UPDATE books 
+				  SET deleted_at = NOW() 
+				  WHERE id = $1`
+	RESTORE_BOOK = `
+				  UPDATE books 
+				  SET deleted_at = NULL, version = version + 1 
+				  WHERE id = $1 AND deleted_at IS NOT NULL
+				  RETURNING id, title, author, category, available, cover_updated_at, rating_count, rating_sum, version`
+	PURGE_BOOKS = `
 				  DELETE 
 				  FROM books 
-				  WHERE id = $1`
+				  WHERE deleted_at < $1`
 )
 
+// BookPatch содержит только изменённые поля книги
+type BookPatch struct {
+	Title    *string
+	Author   *string
+	Category *string
+}
+
+//go:generate mockgen -source=BookRepository.go -destination=mock/BookRepository.go -package=repository
 type BookRepository interface {
 	GetALL(ctx context.Context) ([]entity.Book, error)
 	GetByID(ctx context.Context, id int) (*entity.Book, error)
 	Create(ctx context.Context, book *entity.Book) error
 	Update(ctx context.Context, book *entity.Book) (*entity.Book, error)
+	Patch(ctx context.Context, id int, version int, patch BookPatch) (*entity.Book, error)
 	Delete(ctx context.Context, id int) error
+	UpdateCover(ctx context.Context, id int, updatedAt time.Time) error
+	Restore(ctx context.Context, id int) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 }
 
 type BookRepositoryImpl struct {
-	Conn        *pgx.Conn
-	RedisClient *redis.Client
+	Conn   *pgxpool.Pool
+	Caches *Caches
 }
 
-func NewBookRepository(conn *pgx.Conn, redisClient *redis.Client) BookRepository {
-	return &BookRepositoryImpl{Conn: conn, RedisClient: redisClient}
+func NewBookRepository(conn *pgxpool.Pool, caches *Caches) BookRepository {
+	return &BookRepositoryImpl{Conn: conn, Caches: caches}
 }
 
 func (bookRepository *BookRepositoryImpl) GetALL(ctx context.Context) ([]entity.Book, error) {
 	rows, err := bookRepository.Conn.Query(ctx, SELECT_ALL_BOOKS)
 	if err != nil {
-		return nil, err
+		return nil, dbError(err)
 	}
 	var books []entity.Book
 	for rows.Next() {
 		var book entity.Book
-		err = rows.Scan(&book.ID, &book.Title, &book.Author, &book.Available)
+		err = rows.Scan(&book.ID, &book.Title, &book.Author, &book.Category, &book.Available, &book.CoverUpdatedAt, &book.RatingCount, &book.RatingSum, &book.Version)
 		if err != nil {
-			return nil, err
+			return nil, dbError(err)
 		}
 		books = append(books, book)
 	}
@@ -68,69 +120,200 @@ func (bookRepository *BookRepositoryImpl) GetALL(ctx context.Context) ([]entity.
 }
 
 func (bookRepository *BookRepositoryImpl) GetByID(ctx context.Context, id int) (*entity.Book, error) {
-	cacheKey := fmt.Sprintf("book:%d", id)
-	cachedUser, err := bookRepository.RedisClient.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var book entity.Book
-		err = json.Unmarshal([]byte(cachedUser), &book)
-		if err == nil {
-			return &book, nil
+	return bookRepository.Caches.Books.Get(ctx, id, func(ctx context.Context) (*entity.Book, error) {
+		book := &entity.Book{}
+		err := bookRepository.Conn.QueryRow(ctx, SELECT_BOOK_BY_ID, id).Scan(&book.ID, &book.Title, &book.Author, &book.Category, &book.Available, &book.CoverUpdatedAt, &book.RatingCount, &book.RatingSum, &book.Version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrBookNotFound
 		}
+		if err != nil {
+			return nil, dbError(err)
+		}
+		return book, nil
+	})
+}
+
+func (bookRepository *BookRepositoryImpl) Create(ctx context.Context, book *entity.Book) error {
+	err := withTx(ctx, bookRepository.Conn, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx,
+			INSERT_BOOK,
+			book.Title, book.Author, book.Category).Scan(&book.ID, &book.Available, &book.Version)
+		if err != nil {
+			return dbError(err)
+		}
+		return insertEvent(ctx, tx, entity.EventBookCreated, book.ID, book)
+	})
+	if err != nil {
+		return err
 	}
+	// Запрос к ещё не созданному id мог запомнить, что книги нет
+	bookRepository.Caches.Invalidate(ctx, Changes{Books: []int{book.ID}})
+	return nil
+}
 
-	book := &entity.Book{}
-	err = bookRepository.Conn.QueryRow(ctx, SELECT_BOOK_BY_ID, id).Scan(&book.ID, &book.Title, &book.Author, &book.Available)
+// Update изменяет книгу, только если её версия совпадает с book.Version, иначе возвращает domain.ErrVersionMismatch
+func (bookRepository *BookRepositoryImpl) Update(ctx context.Context, book *entity.Book) (*entity.Book, error) {
+	updatedBook := &entity.Book{}
+	err := withTx(ctx, bookRepository.Conn, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx,
+			UPDATE_BOOK, book.Title, book.Author, book.Category, book.ID, book.Version).
+			Scan(&updatedBook.ID, &updatedBook.Title, &updatedBook.Author, &updatedBook.Category, &updatedBook.Available,
+				&updatedBook.CoverUpdatedAt, &updatedBook.RatingCount, &updatedBook.RatingSum, &updatedBook.Version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return bookRepository.missingOrStale(ctx, book.ID)
+		}
+		if err != nil {
+			return dbError(err)
+		}
+		return insertEvent(ctx, tx, entity.EventBookUpdated, updatedBook.ID, updatedBook)
+	})
 	if err != nil {
 		return nil, err
 	}
-	//Сохранение кеша
-	userData, err := json.Marshal(book)
-	if err == nil {
-		bookRepository.RedisClient.Set(ctx, cacheKey, userData, 0)
-	}
-	return book, nil
 
-}
+	// Удаление книги с кеша
+	bookRepository.Caches.Invalidate(ctx, Changes{Books: []int{book.ID}})
 
-func (bookRepository *BookRepositoryImpl) Create(ctx context.Context, book *entity.Book) error {
-	err := bookRepository.Conn.QueryRow(ctx,
-		INSERT_BOOK,
-		book.Title, book.Author).Scan(&book.ID, &book.Available)
-	return err
+	return updatedBook, nil
 }
 
-func (bookRepository *BookRepositoryImpl) Update(ctx context.Context, book *entity.Book) (*entity.Book, error) {
+// Patch записывает только заданные в patch колонки, если версия книги совпадает с version
+func (bookRepository *BookRepositoryImpl) Patch(ctx context.Context, id int, version int, patch BookPatch) (*entity.Book, error) {
+	var columns []string
+	var args []any
+	set := func(column string, value *string) {
+		if value != nil {
+			args = append(args, *value)
+			columns = append(columns, fmt.Sprintf("%s = $%d", column, len(args)))
+		}
+	}
+	set("title", patch.Title)
+	set("author", patch.Author)
+	set("category", patch.Category)
+	if len(columns) == 0 {
+		return bookRepository.GetByID(ctx, id)
+	}
+	args = append(args, id, version)
 
-	_, err := bookRepository.Conn.Exec(ctx,
-		UPDATE_BOOK, book.Title, book.Author, book.ID)
+	book := &entity.Book{}
+	err := withTx(ctx, bookRepository.Conn, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, fmt.Sprintf(PATCH_BOOK, strings.Join(columns, ", "), len(args)-1, len(args)), args...).
+			Scan(&book.ID, &book.Title, &book.Author, &book.Category, &book.Available, &book.CoverUpdatedAt, &book.RatingCount, &book.RatingSum, &book.Version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return bookRepository.missingOrStale(ctx, id)
+		}
+		if err != nil {
+			return dbError(err)
+		}
+		return insertEvent(ctx, tx, entity.EventBookUpdated, book.ID, book)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	err = bookRepository.Conn.QueryRow(ctx,
-		SELECT_BOOK_BY_ID, book.ID).
-		Scan(&book.ID, &book.Title, &book.Author, &book.Available)
+	// Удаление книги с кеша
+	bookRepository.Caches.Invalidate(ctx, Changes{Books: []int{id}})
+	return book, nil
+}
+
+// missingOrStale объясняет, почему условное обновление не затронуло строк: книга удалена или её версия уже изменилась
+func (bookRepository *BookRepositoryImpl) missingOrStale(ctx context.Context, id int) error {
+	var exists bool
+	if err := bookRepository.Conn.QueryRow(ctx, SELECT_BOOK_EXISTS, id).Scan(&exists); err != nil {
+		return dbError(err)
+	}
+	if exists {
+		return domain.ErrVersionMismatch
+	}
+	return domain.ErrBookNotFound
+}
 
+// Delete помечает книгу удалённой; выданную книгу удалить нельзя
+func (bookRepository *BookRepositoryImpl) Delete(ctx context.Context, id int) error {
+	tx, err := bookRepository.Conn.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return dbError(err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				slog.ErrorContext(ctx, fmt.Sprintf("tx.Rollback failed: %v", rollbackErr))
+			}
+		}
+	}()
+
+	// Строка книги блокируется, поэтому параллельная выдача дождётся удаления и не найдёт книгу
+	var onLoan bool
+	if err = tx.QueryRow(ctx, SELECT_BOOK_ON_LOAN_FOR_UPDATE, id).Scan(&onLoan); errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrBookNotFound
+	}
+	if err != nil {
+		return dbError(err)
+	}
+	if onLoan {
+		err = domain.ErrActiveLoans
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, DELETE_BOOK, id); err != nil {
+		return dbError(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return dbError(err)
 	}
 	// Удаление книги с кеша
-	bookCacheKey := fmt.Sprintf("book:%d", book.ID)
-	err = bookRepository.RedisClient.Del(ctx, bookCacheKey).Err()
+	bookRepository.Caches.Invalidate(ctx, Changes{Books: []int{id}})
+	return nil
+}
+
+func (bookRepository *BookRepositoryImpl) Restore(ctx context.Context, id int) error {
+	err := withTx(ctx, bookRepository.Conn, func(tx pgx.Tx) error {
+		var book entity.Book
+		err := tx.QueryRow(ctx, RESTORE_BOOK, id).
+			Scan(&book.ID, &book.Title, &book.Author, &book.Category, &book.Available, &book.CoverUpdatedAt, &book.RatingCount, &book.RatingSum, &book.Version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrBookNotFound
+		}
+		if err != nil {
+			return dbError(err)
+		}
+		return insertEvent(ctx, tx, entity.EventBookUpdated, book.ID, book)
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
+	// В кеше могло остаться, что книги нет
+	bookRepository.Caches.Invalidate(ctx, Changes{Books: []int{id}})
+	return nil
+}
 
-	return book, nil
+// Purge окончательно удаляет книги, помеченные удалёнными раньше deletedBefore, вместе с их займами и отзывами.
+// Кеш не сбрасывается: удалённая книга уже закеширована как отсутствующая, а на руках её быть не может
+func (bookRepository *BookRepositoryImpl) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tag, err := bookRepository.Conn.Exec(ctx, PURGE_BOOKS, deletedBefore)
+	if err != nil {
+		return 0, dbError(err)
+	}
+	return tag.RowsAffected(), nil
 }
 
-func (bookRepository *BookRepositoryImpl) Delete(ctx context.Context, id int) error {
-	_, err := bookRepository.Conn.Exec(ctx, DELETE_BOOK, id)
-	// Удаление книги с кеша
-	bookCacheKey := fmt.Sprintf("book:%d", id)
-	err = bookRepository.RedisClient.Del(ctx, bookCacheKey).Err()
+func (bookRepository *BookRepositoryImpl) UpdateCover(ctx context.Context, id int, updatedAt time.Time) error {
+	err := withTx(ctx, bookRepository.Conn, func(tx pgx.Tx) error {
+		var book entity.Book
+		err := tx.QueryRow(ctx, UPDATE_BOOK_COVER, updatedAt, id).
+			Scan(&book.ID, &book.Title, &book.Author, &book.Category, &book.Available, &book.CoverUpdatedAt, &book.RatingCount, &book.RatingSum, &book.Version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrBookNotFound
+		}
+		if err != nil {
+			return dbError(err)
+		}
+		return insertEvent(ctx, tx, entity.EventBookUpdated, book.ID, book)
+	})
 	if err != nil {
 		return err
 	}
-	return err
+	// Удаление книги с кеша
+	bookRepository.Caches.Invalidate(ctx, Changes{Books: []int{id}})
+	return nil
 }
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+	"github.com/Ablyamitov/simple-rest/internal/store/cache"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+)
+
+// Caches - кеши сущностей, общие для репозиториев: книгу и пользователя меняют ещё займы и отзывы
+type Caches struct {
+	Books *cache.EntityCache[entity.Book]
+	Users *cache.EntityCache[entity.User]
+}
+
+// Changes - сущности, изменённые записью в базу
+type Changes struct {
+	Books []int
+	Users []int
+}
+
+func NewCaches(store cache.Cache, books cache.Policy, users cache.Policy, observer cache.Observer) *Caches {
+	caches := &Caches{
+		Books: cache.NewEntityCache[entity.Book](store, "book", books, domain.ErrBookNotFound, observer),
+		Users: cache.NewEntityCache[entity.User](store, "user", users, domain.ErrUserNotFound, observer),
+	}
+	// Пользователь хранит копии книг, которые у него на руках
+	caches.Users.Dependencies = func(user *entity.User) []string {
+		tags := make([]string, len(user.Books))
+		for i, book := range user.Books {
+			tags[i] = caches.Books.Key(book.ID)
+		}
+		return tags
+	}
+	return caches
+}
+
+// Invalidate сбрасывает изменённые сущности и всё, что от них зависит.
+// Вызывается только после коммита: сброс до него позволил бы параллельному чтению снова закешировать старые данные.
+// Запись к этому моменту уже сохранена, поэтому сброс не прерывается отключением клиента,
+// а его ошибка только пишется в лог: клиент не должен получить 500 и повторить выполненную запись
+func (caches *Caches) Invalidate(ctx context.Context, changes Changes) {
+	ctx = context.WithoutCancel(ctx)
+	tags := make([]string, len(changes.Books))
+	for i, id := range changes.Books {
+		tags[i] = caches.Books.Key(id)
+	}
+	err := errors.Join(
+		caches.Books.Invalidate(ctx, changes.Books...),
+		caches.Users.Invalidate(ctx, changes.Users...),
+		caches.Users.InvalidateTagged(ctx, tags...))
+	if err != nil {
+		slog.ErrorContext(ctx, "Cache invalidation failed", slog.String("error", err.Error()),
+			slog.Any("books", changes.Books), slog.Any("users", changes.Users))
+	}
+}
@@ -0,0 +1,416 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/metrics"
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+	"github.com/Ablyamitov/simple-rest/internal/store"
+	"github.com/Ablyamitov/simple-rest/internal/store/cache"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryCaches() *Caches {
+	policy := cache.Policy{TTL: time.Hour, NegativeTTL: time.Hour}
+	return NewCaches(cache.NewMemoryCache(0), policy, policy, metrics.NewNoopMetrics())
+}
+
+// Изменение книги сбрасывает только тех пользователей, у которых она на руках
+func TestCaches_InvalidateBookDropsItsReaders(t *testing.T) {
+	ctx := context.Background()
+	caches := newMemoryCaches()
+	loads := map[int]int{}
+	reader := func(id int, bookIds ...int) func(ctx context.Context) (*entity.User, error) {
+		return func(ctx context.Context) (*entity.User, error) {
+			loads[id]++
+			user := &entity.User{ID: id}
+			for _, bookId := range bookIds {
+				user.Books = append(user.Books, &entity.Book{ID: bookId})
+			}
+			return user, nil
+		}
+	}
+	warm := func() {
+		for id, load := range map[int]func(ctx context.Context) (*entity.User, error){
+			1: reader(1, 10), 2: reader(2, 10, 20), 3: reader(3, 20), 4: reader(4),
+		} {
+			_, err := caches.Users.Get(ctx, id, load)
+			require.NoError(t, err)
+		}
+	}
+
+	warm()
+	caches.Invalidate(ctx, Changes{Books: []int{10}})
+	warm()
+	assert.Equal(t, map[int]int{1: 2, 2: 2, 3: 1, 4: 1}, loads)
+
+	caches.Invalidate(ctx, Changes{Users: []int{4}, Books: []int{20}})
+	warm()
+	assert.Equal(t, map[int]int{1: 2, 2: 3, 3: 2, 4: 2}, loads)
+}
+
+// coherence выполняет запись через репозитории с кешем и сравнивает закешированные сущности с базой
+type coherence struct {
+	t       *testing.T
+	ctx     context.Context
+	conn    *pgxpool.Pool
+	books   BookRepository
+	users   UserRepository
+	loans   LoanRepository
+	reviews ReviewRepository
+	seeds   SeedRepository
+	run     int64
+	count   int
+}
+
+// Клиент отключился сразу после коммита: сброс всё равно доходит и до Redis, и до памяти
+func TestCaches_InvalidateWithCancelledContext(t *testing.T) {
+	policy := cache.Policy{TTL: time.Hour, NegativeTTL: time.Hour}
+	caches := NewCaches(newRedisLikeCache(), policy, policy, metrics.NewNoopMetrics())
+	loads := 0
+	load := func(ctx context.Context) (*entity.Book, error) {
+		loads++
+		return &entity.Book{ID: 1, Version: loads}, nil
+	}
+	_, err := caches.Books.Get(context.Background(), 1, load)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	caches.Invalidate(ctx, Changes{Books: []int{1}})
+
+	book, err := caches.Books.Get(context.Background(), 1, load)
+	require.NoError(t, err)
+	assert.Equal(t, 2, book.Version)
+}
+
+// contextCache, как клиент Redis, не выполняет команды с отменённым контекстом
+type contextCache struct {
+	cache.Cache
+}
+
+func (c contextCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Cache.Get(ctx, key)
+}
+
+func (c contextCache) Delete(ctx context.Context, keys ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Cache.Delete(ctx, keys...)
+}
+
+func (c contextCache) DeleteTagged(ctx context.Context, tags ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Cache.DeleteTagged(ctx, tags...)
+}
+
+// newRedisLikeCache собирает кеш как в cmd/app, но с Redis в памяти
+func newRedisLikeCache() cache.Cache {
+	return cache.NewFallbackCache(contextCache{cache.NewMemoryCache(0)}, cache.NewMemoryCache(0), time.Second)
+}
+
+// disconnectingCache отменяет запрос на первом удалении после armed, как клиент, ушедший сразу после коммита
+type disconnectingCache struct {
+	cache.Cache
+	disconnect func()
+	armed      bool
+}
+
+func (c *disconnectingCache) Delete(ctx context.Context, keys ...string) error {
+	if c.armed {
+		c.armed = false
+		c.disconnect()
+	}
+	return c.Cache.Delete(ctx, keys...)
+}
+
+// TestCacheCoherence проходит по всем путям записи. Нужна отдельная база в SIMPLE_REST_TEST_DB_URL:
+// тест применяет к ней миграции, а очистка и сид затрагивают все удалённые и сидовые строки
+func TestCacheCoherence(t *testing.T) {
+	dbURL := os.Getenv("SIMPLE_REST_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("SIMPLE_REST_TEST_DB_URL is not set")
+	}
+	require.NoError(t, store.ApplyMigrations(dbURL))
+	ctx := context.Background()
+	conn, err := pgxpool.New(ctx, dbURL)
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+
+	newCoherenceWith := func(t *testing.T, caches *Caches) *coherence {
+		return &coherence{
+			t: t, ctx: ctx, conn: conn, run: time.Now().UnixNano(),
+			books:   NewBookRepository(conn, caches),
+			users:   NewUserRepository(conn, caches),
+			loans:   NewLoanRepository(conn, caches),
+			reviews: NewReviewRepository(conn, caches),
+			seeds:   NewSeedRepository(conn, caches),
+		}
+	}
+	newCoherence := func(t *testing.T) *coherence {
+		return newCoherenceWith(t, newMemoryCaches())
+	}
+
+	t.Run("book create", func(t *testing.T) {
+		c := newCoherence(t)
+		// Следующий id запоминается как отсутствующий до создания книги
+		next := c.book() + 1
+		c.warm(nil, []int{next})
+		c.assertCoherent(nil, []int{c.book()})
+	})
+	t.Run("book update", func(t *testing.T) {
+		c := newCoherence(t)
+		bookId := c.book()
+		reader := c.reader(bookId)
+		c.warm([]int{reader}, []int{bookId})
+		_, err := c.books.Update(ctx, &entity.Book{ID: bookId, Title: "Updated", Author: "Author"})
+		require.NoError(t, err)
+		c.assertCoherent([]int{reader}, []int{bookId})
+	})
+	t.Run("book patch", func(t *testing.T) {
+		c := newCoherence(t)
+		bookId := c.book()
+		reader := c.reader(bookId)
+		c.warm([]int{reader}, []int{bookId})
+		title := "Patched"
+		_, err := c.books.Patch(ctx, bookId, c.bookVersion(bookId), BookPatch{Title: &title})
+		require.NoError(t, err)
+		c.assertCoherent([]int{reader}, []int{bookId})
+	})
+	t.Run("book cover", func(t *testing.T) {
+		c := newCoherence(t)
+		bookId := c.book()
+		c.warm(nil, []int{bookId})
+		require.NoError(t, c.books.UpdateCover(ctx, bookId, time.Now()))
+		c.assertCoherent(nil, []int{bookId})
+	})
+	t.Run("book delete, restore and purge", func(t *testing.T) {
+		c := newCoherence(t)
+		bookId := c.book()
+		c.warm(nil, []int{bookId})
+		require.NoError(t, c.books.Delete(ctx, bookId))
+		c.assertCoherent(nil, []int{bookId})
+		require.NoError(t, c.books.Restore(ctx, bookId))
+		c.assertCoherent(nil, []int{bookId})
+		require.NoError(t, c.books.Delete(ctx, bookId))
+		_, err := c.books.Purge(ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		c.assertCoherent(nil, []int{bookId})
+	})
+	t.Run("user create", func(t *testing.T) {
+		c := newCoherence(t)
+		next := c.reader() + 1
+		c.warm([]int{next}, nil)
+		c.assertCoherent([]int{c.reader()}, nil)
+	})
+	t.Run("user update and patch", func(t *testing.T) {
+		c := newCoherence(t)
+		reader := c.reader(c.book())
+		c.warm([]int{reader}, nil)
+		_, err := c.users.Update(ctx, &entity.User{ID: reader, Name: "Updated", Email: c.email()})
+		require.NoError(t, err)
+		c.assertCoherent([]int{reader}, nil)
+		name := "Patched"
+		user, err := c.users.GetByID(ctx, reader)
+		require.NoError(t, err)
+		_, err = c.users.Patch(ctx, reader, user.Version, UserPatch{Name: &name})
+		require.NoError(t, err)
+		c.assertCoherent([]int{reader}, nil)
+	})
+	t.Run("user delete and purge", func(t *testing.T) {
+		c := newCoherence(t)
+		bookId := c.book()
+		reviewer := c.reviewer(bookId, entity.ReviewStatusApproved)
+		reader := c.reader(bookId)
+		c.warm([]int{reviewer, reader}, []int{bookId})
+		require.NoError(t, c.users.Delete(ctx, reviewer))
+		c.assertCoherent([]int{reviewer, reader}, []int{bookId})
+		_, err := c.users.Purge(ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		c.assertCoherent([]int{reviewer, reader}, []int{bookId})
+	})
+	t.Run("loan create and return", func(t *testing.T) {
+		c := newCoherence(t)
+		bookId := c.book()
+		reader := c.reader()
+		c.warm([]int{reader}, []int{bookId})
+		loan, err := c.loans.Create(ctx, reader, bookId, LoanTerms{DueDate: time.Now().Add(24 * time.Hour)})
+		require.NoError(t, err)
+		c.assertCoherent([]int{reader}, []int{bookId})
+		_, err = c.loans.Return(ctx, loan.ID)
+		require.NoError(t, err)
+		c.assertCoherent([]int{reader}, []int{bookId})
+	})
+	t.Run("client disconnects after commit", func(t *testing.T) {
+		requestCtx, disconnect := context.WithCancel(ctx)
+		defer disconnect()
+		store := &disconnectingCache{Cache: newRedisLikeCache(), disconnect: disconnect}
+		policy := cache.Policy{TTL: time.Hour, NegativeTTL: time.Hour}
+		c := newCoherenceWith(t, NewCaches(store, policy, policy, metrics.NewNoopMetrics()))
+		bookId := c.book()
+		reader := c.reader()
+		c.warm([]int{reader}, []int{bookId})
+
+		// Запись сохранена, и ответ на неё не должен стать ошибкой из-за сброса кеша
+		store.armed = true
+		loan, err := c.loans.Create(requestCtx, reader, bookId, LoanTerms{DueDate: time.Now().Add(24 * time.Hour)})
+		require.NoError(t, err)
+		require.Error(t, requestCtx.Err())
+		c.assertCoherent([]int{reader}, []int{bookId})
+		_, err = c.loans.Return(ctx, loan.ID)
+		require.NoError(t, err)
+	})
+	t.Run("review moderation", func(t *testing.T) {
+		c := newCoherence(t)
+		bookId := c.book()
+		c.reviewer(bookId, entity.ReviewStatusPending)
+		reviews, err := c.reviews.GetByBook(ctx, bookId)
+		require.NoError(t, err)
+		require.Len(t, reviews, 1)
+		review := reviews[0]
+		reader := c.reader(bookId)
+		c.warm([]int{reader}, []int{bookId})
+
+		_, err = c.reviews.SetStatus(ctx, review.ID, entity.ReviewStatusApproved)
+		require.NoError(t, err)
+		c.assertCoherent([]int{reader}, []int{bookId})
+		review.Rating = 1
+		_, err = c.reviews.Update(ctx, &review)
+		require.NoError(t, err)
+		c.assertCoherent([]int{reader}, []int{bookId})
+		require.NoError(t, c.reviews.Delete(ctx, review.ID))
+		c.assertCoherent([]int{reader}, []int{bookId})
+	})
+	t.Run("seed", func(t *testing.T) {
+		c := newCoherence(t)
+		dataset := func(title string) SeedDataset {
+			return SeedDataset{
+				Users: []SeedUser{{Key: "coherence-user", Name: "Seed", Email: "coherence@seed.test", Password: "hash", Role: "user"}},
+				Books: []SeedBook{{Key: "coherence-book", Title: title, Author: "Seed"}},
+			}
+		}
+		_, err := c.seeds.Apply(ctx, dataset("First"))
+		require.NoError(t, err)
+		var bookId int
+		require.NoError(t, conn.QueryRow(ctx, "SELECT id FROM books WHERE seed_key = 'coherence-book'").Scan(&bookId))
+		// Пользователь не из сида держит сидовую книгу: её изменение и удаление сидом должны до него дойти
+		reader := c.reader(bookId)
+		c.warm([]int{reader}, []int{bookId})
+
+		_, err = c.seeds.Apply(ctx, dataset("Second"))
+		require.NoError(t, err)
+		c.assertCoherent([]int{reader}, []int{bookId})
+		_, err = c.seeds.Apply(ctx, SeedDataset{})
+		require.NoError(t, err)
+		c.assertCoherent([]int{reader}, []int{bookId})
+	})
+}
+
+func (c *coherence) email() string {
+	c.count++
+	return fmt.Sprintf("coherence-%d-%d@test.local", c.run, c.count)
+}
+
+func (c *coherence) book() int {
+	book := &entity.Book{Title: "Title", Author: "Author", Category: "Category"}
+	require.NoError(c.t, c.books.Create(c.ctx, book))
+	return book.ID
+}
+
+func (c *coherence) bookVersion(id int) int {
+	book, err := c.books.GetByID(c.ctx, id)
+	require.NoError(c.t, err)
+	return book.Version
+}
+
+// reader создаёт пользователя и выдаёт ему книги
+func (c *coherence) reader(bookIds ...int) int {
+	user := &entity.User{Name: "Reader", Email: c.email(), Password: "hash", Role: "user"}
+	require.NoError(c.t, c.users.Create(c.ctx, user))
+	for _, bookId := range bookIds {
+		_, err := c.loans.Create(c.ctx, user.ID, bookId, LoanTerms{DueDate: time.Now().Add(24 * time.Hour)})
+		require.NoError(c.t, err)
+	}
+	return user.ID
+}
+
+// reviewer создаёт пользователя, который прочитал книгу и оставил отзыв со статусом status
+func (c *coherence) reviewer(bookId int, status string) int {
+	userId := c.reader(bookId)
+	loan, err := c.loans.GetActive(c.ctx, userId, bookId)
+	require.NoError(c.t, err)
+	_, err = c.loans.Return(c.ctx, loan.ID)
+	require.NoError(c.t, err)
+	review := &entity.Review{BookID: bookId, UserID: userId, Rating: 5, Text: "Good"}
+	require.NoError(c.t, c.reviews.Create(c.ctx, review))
+	if status != review.Status {
+		_, err = c.reviews.SetStatus(c.ctx, review.ID, status)
+		require.NoError(c.t, err)
+	}
+	return userId
+}
+
+func (c *coherence) warm(userIds []int, bookIds []int) {
+	for _, id := range userIds {
+		_, _ = c.users.GetByID(c.ctx, id)
+	}
+	for _, id := range bookIds {
+		_, _ = c.books.GetByID(c.ctx, id)
+	}
+}
+
+// assertCoherent сравнивает чтение через общий кеш с чтением через пустой
+func (c *coherence) assertCoherent(userIds []int, bookIds []int) {
+	c.t.Helper()
+	fresh := newMemoryCaches()
+	for _, id := range userIds {
+		cached, cachedErr := c.users.GetByID(c.ctx, id)
+		actual, err := NewUserRepository(c.conn, fresh).GetByID(c.ctx, id)
+		assertSameError(c.t, err, cachedErr, domain.ErrUserNotFound)
+		if err == nil && cachedErr == nil {
+			sortBooks(cached.Books)
+			sortBooks(actual.Books)
+			assert.Equal(c.t, actual, cached, "user %d", id)
+		}
+	}
+	for _, id := range bookIds {
+		cached, cachedErr := c.books.GetByID(c.ctx, id)
+		actual, err := NewBookRepository(c.conn, fresh).GetByID(c.ctx, id)
+		assertSameError(c.t, err, cachedErr, domain.ErrBookNotFound)
+		if err == nil && cachedErr == nil {
+			assert.Equal(c.t, actual.Version, cached.Version, "book %d", id)
+			assert.Equal(c.t, actual.Title, cached.Title, "book %d", id)
+			assert.Equal(c.t, actual.Available, cached.Available, "book %d", id)
+			assert.Equal(c.t, actual.RatingSum, cached.RatingSum, "book %d", id)
+		}
+	}
+}
+
+func assertSameError(t *testing.T, expected error, actual error, notFound error) {
+	t.Helper()
+	if expected == nil {
+		assert.NoError(t, actual)
+		return
+	}
+	require.ErrorIs(t, expected, notFound)
+	assert.ErrorIs(t, actual, notFound)
+}
+
+func sortBooks(books []*entity.Book) {
+	slices.SortFunc(books, func(a, b *entity.Book) int { return a.ID - b.ID })
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Коды ошибок PostgreSQL, которые имеют смысл для клиента
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// constraintErrors уточняет нарушения известных ограничений
+var constraintErrors = map[string]error{
+	"users_email_active_idx":     domain.NewError(domain.ErrConflict, "user with the same email already exists"),
+	"user_books_active_book_idx": domain.ErrBookUnavailable,
+}
+
+// dbError переводит ошибки pgx и коды PostgreSQL в доменные ошибки, чтобы детали хранилища не уходили выше репозитория
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if constraintErr, ok := constraintErrors[pgErr.ConstraintName]; ok {
+			return constraintErr
+		}
+		switch pgErr.Code {
+		case uniqueViolation:
+			return domain.ErrConflict
+		case foreignKeyViolation:
+			return domain.NewError(domain.ErrNotFound, "referenced record not found")
+		}
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Ключ занимается заново, если запись старше $4 секунд или запрос бросили незавершённым дольше $5 секунд
+const (
+	CLAIM_IDEMPOTENCY_KEY = `
+				  INSERT INTO idempotency_keys (scope, key, fingerprint)
+				  VALUES ($1, $2, $3)
+				  ON CONFLICT (scope, key) DO UPDATE
+				  SET fingerprint = EXCLUDED.fingerprint, status = NULL, headers = NULL, body = NULL,
+				      created_at = NOW(), completed_at = NULL
+				  WHERE idempotency_keys.created_at < NOW() - MAKE_INTERVAL(secs => $4)
+				     OR (idempotency_keys.completed_at IS NULL
+				         AND idempotency_keys.created_at < NOW() - MAKE_INTERVAL(secs => $5))
+				  RETURNING created_at`
+
+	SELECT_IDEMPOTENCY_KEY = `
+				  SELECT fingerprint, COALESCE(status, 0), headers, body, created_at, completed_at
+				  FROM idempotency_keys
+				  WHERE scope = $1 AND key = $2`
+
+	COMPLETE_IDEMPOTENCY_KEY = `
+				  UPDATE idempotency_keys
+				  SET status = $3, headers = $4, body = $5, completed_at = NOW()
+				  WHERE scope = $1 AND key = $2 AND completed_at IS NULL`
+
+	RELEASE_IDEMPOTENCY_KEY = `
+				  DELETE
+				  FROM idempotency_keys
+				  WHERE scope = $1 AND key = $2 AND completed_at IS NULL`
+
+	PURGE_IDEMPOTENCY_KEYS = `
+				  DELETE
+				  FROM idempotency_keys
+				  WHERE created_at < $1`
+)
+
+//go:generate mockgen -source=IdempotencyRepository.go -destination=mock/IdempotencyRepository.go -package=repository
+type IdempotencyRepository interface {
+	// Claim занимает key.Scope/key.Key под новый запрос и возвращает true. Если ключ занят запросом,
+	// который ещё выполняется или уже записал ответ, возвращает эту запись и false.
+	// ttl - сколько живёт запись, lockTimeout - через сколько незавершённый запрос считается брошенным
+	Claim(ctx context.Context, key *entity.IdempotencyKey, ttl time.Duration,
+		lockTimeout time.Duration) (*entity.IdempotencyKey, bool, error)
+	// Complete записывает ответ на занятый ключ
+	Complete(ctx context.Context, key *entity.IdempotencyKey) error
+	// Release освобождает занятый ключ без ответа, чтобы повтор выполнил запрос заново
+	Release(ctx context.Context, scope string, key string) error
+	// Purge удаляет записи, созданные раньше createdBefore
+	Purge(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+type IdempotencyRepositoryImpl struct {
+	Conn *pgxpool.Pool
+}
+
+func NewIdempotencyRepository(conn *pgxpool.Pool) IdempotencyRepository {
+	return &IdempotencyRepositoryImpl{Conn: conn}
+}
+
+func (idempotencyRepository *IdempotencyRepositoryImpl) Claim(ctx context.Context, key *entity.IdempotencyKey,
+	ttl time.Duration, lockTimeout time.Duration) (*entity.IdempotencyKey, bool, error) {
+	// Запись могут освободить между двумя запросами, тогда ключ занимается со второй попытки
+	for attempt := 0; attempt < 2; attempt++ {
+		err := idempotencyRepository.Conn.QueryRow(ctx, CLAIM_IDEMPOTENCY_KEY, key.Scope, key.Key, key.Fingerprint,
+			ttl.Seconds(), lockTimeout.Seconds()).Scan(&key.CreatedAt)
+		if err == nil {
+			return nil, true, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, dbError(err)
+		}
+
+		existing := &entity.IdempotencyKey{Scope: key.Scope, Key: key.Key}
+		err = idempotencyRepository.Conn.QueryRow(ctx, SELECT_IDEMPOTENCY_KEY, key.Scope, key.Key).Scan(
+			&existing.Fingerprint, &existing.Status, &existing.Headers, &existing.Body, &existing.CreatedAt,
+			&existing.CompletedAt)
+		if err == nil {
+			return existing, false, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, dbError(err)
+		}
+	}
+	return nil, false, errors.New("idempotency key changed concurrently")
+}
+
+func (idempotencyRepository *IdempotencyRepositoryImpl) Complete(ctx context.Context, key *entity.IdempotencyKey) error {
+	_, err := idempotencyRepository.Conn.Exec(ctx, COMPLETE_IDEMPOTENCY_KEY, key.Scope, key.Key, key.Status,
+		key.Headers, key.Body)
+	return dbError(err)
+}
+
+func (idempotencyRepository *IdempotencyRepositoryImpl) Release(ctx context.Context, scope string, key string) error {
+	_, err := idempotencyRepository.Conn.Exec(ctx, RELEASE_IDEMPOTENCY_KEY, scope, key)
+	return dbError(err)
+}
+
+func (idempotencyRepository *IdempotencyRepositoryImpl) Purge(ctx context.Context, createdBefore time.Time) (int64, error) {
+	tag, err := idempotencyRepository.Conn.Exec(ctx, PURGE_IDEMPOTENCY_KEYS, createdBefore)
+	if err != nil {
+		return 0, dbError(err)
+	}
+	return tag.RowsAffected(), nil
+}
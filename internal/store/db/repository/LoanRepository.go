@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	SELECT_LOAN_BY_ID = `
+				  SELECT id, user_id, book_id, taken_date, due_date, return_date
+				  FROM user_books
+				  WHERE id = $1`
+
+	SELECT_ACTIVE_LOAN = `
+				  SELECT id, user_id, book_id, taken_date, due_date, return_date
+				  FROM user_books
+				  WHERE user_id = $1 AND book_id = $2 AND return_date IS NULL`
+
+	COUNT_ACTIVE_LOANS = `
+				  SELECT COUNT(*)
+				  FROM user_books
+				  WHERE user_id = $1 AND return_date IS NULL`
+
+	COUNT_OPEN_LOANS = `
+				  SELECT COUNT(*), COUNT(*) FILTER (WHERE due_date < NOW())
+				  FROM user_books
+				  WHERE return_date IS NULL`
+
+	// Выдача и возврат меняют список книг пользователя, поэтому увеличивают и его версию
+	UPDATE_ACTIVE_USER_VERSION = `
+				  UPDATE users
+				  SET version = version + 1
+				  WHERE id = $1 AND deleted_at IS NULL`
+
+	UPDATE_USER_VERSION = `
+				  UPDATE users
+				  SET version = version + 1
+				  WHERE id = $1`
+
+	UPDATE_BOOK_TAKEN = `
+				  UPDATE books
+				  SET available = FALSE, version = version + 1
+				  WHERE id = $1 AND deleted_at IS NULL AND available`
+
+	UPDATE_BOOK_RETURNED = `
+				  UPDATE books
+				  SET available = TRUE, version = version + 1
+				  WHERE id = $1`
+
+	INSERT_LOAN = `
+				  INSERT INTO user_books (user_id, book_id, due_date)
+				  VALUES ($1, $2, $3)
+				  RETURNING id, user_id, book_id, taken_date, due_date, return_date`
+
+	// Займ не удаляется, а закрывается: история нужна для отзывов и статистики
+	RETURN_LOAN = `
+				  UPDATE user_books
+				  SET return_date = NOW()
+				  WHERE id = $1 AND return_date IS NULL
+				  RETURNING id, user_id, book_id, taken_date, due_date, return_date`
+)
+
+// LoanTerms - условия выдачи, рассчитанные по правилам библиотеки; MaxActive <= 0 снимает ограничение
+type LoanTerms struct {
+	MaxActive int
+	DueDate   time.Time
+}
+
+// LoanCounts - число невозвращённых книг, из них просроченных
+type LoanCounts struct {
+	Active  int
+	Overdue int
+}
+
+//go:generate mockgen -source=LoanRepository.go -destination=mock/LoanRepository.go -package=repository
+type LoanRepository interface {
+	GetByID(ctx context.Context, id int) (*entity.Loan, error)
+	GetActive(ctx context.Context, userId int, bookId int) (*entity.Loan, error)
+	Create(ctx context.Context, userId int, bookId int, terms LoanTerms) (*entity.Loan, error)
+	Return(ctx context.Context, id int) (*entity.Loan, error)
+	CountOpen(ctx context.Context) (LoanCounts, error)
+}
+
+type LoanRepositoryImpl struct {
+	Conn   *pgxpool.Pool
+	Caches *Caches
+}
+
+func NewLoanRepository(conn *pgxpool.Pool, caches *Caches) LoanRepository {
+	return &LoanRepositoryImpl{Conn: conn, Caches: caches}
+}
+
+func (loanRepository *LoanRepositoryImpl) GetByID(ctx context.Context, id int) (*entity.Loan, error) {
+	loan, err := scanLoan(loanRepository.Conn.QueryRow(ctx, SELECT_LOAN_BY_ID, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrLoanNotFound
+	}
+	return loan, dbError(err)
+}
+
+// GetActive ищет невозвращённый займ книги пользователем
+func (loanRepository *LoanRepositoryImpl) GetActive(ctx context.Context, userId int, bookId int) (*entity.Loan, error) {
+	loan, err := scanLoan(loanRepository.Conn.QueryRow(ctx, SELECT_ACTIVE_LOAN, userId, bookId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrLoanNotFound
+	}
+	return loan, dbError(err)
+}
+
+func (loanRepository *LoanRepositoryImpl) CountOpen(ctx context.Context) (LoanCounts, error) {
+	var counts LoanCounts
+	err := loanRepository.Conn.QueryRow(ctx, COUNT_OPEN_LOANS).Scan(&counts.Active, &counts.Overdue)
+	return counts, dbError(err)
+}
+
+// Create выдаёт книгу пользователю. Строка пользователя блокируется первой,
+// поэтому лимит займов проверяется без гонок, а параллельное удаление пользователя или книги дождётся выдачи
+func (loanRepository *LoanRepositoryImpl) Create(ctx context.Context, userId int, bookId int, terms LoanTerms) (*entity.Loan, error) {
+	tx, err := loanRepository.Conn.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				slog.ErrorContext(ctx, fmt.Sprintf("tx.Rollback failed: %v", rollbackErr))
+			}
+		}
+	}()
+
+	tag, err := tx.Exec(ctx, UPDATE_ACTIVE_USER_VERSION, userId)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		err = domain.ErrUserNotFound
+		return nil, err
+	}
+
+	if terms.MaxActive > 0 {
+		var active int
+		if err = tx.QueryRow(ctx, COUNT_ACTIVE_LOANS, userId).Scan(&active); err != nil {
+			return nil, dbError(err)
+		}
+		if active >= terms.MaxActive {
+			err = domain.NewError(domain.ErrLimitExceeded, fmt.Sprintf("user already has %d active loans", active))
+			return nil, err
+		}
+	}
+
+	tag, err = tx.Exec(ctx, UPDATE_BOOK_TAKEN, bookId)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		var exists bool
+		if err = tx.QueryRow(ctx, SELECT_BOOK_EXISTS, bookId).Scan(&exists); err != nil {
+			return nil, dbError(err)
+		}
+		err = domain.ErrBookNotFound
+		if exists {
+			err = domain.ErrBookUnavailable
+		}
+		return nil, err
+	}
+
+	loan, err := scanLoan(tx.QueryRow(ctx, INSERT_LOAN, userId, bookId, terms.DueDate))
+	if err != nil {
+		return nil, dbError(err)
+	}
+	if err = insertEvent(ctx, tx, entity.EventLoanStarted, loan.ID, loan); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err)
+	}
+	loanRepository.invalidateCache(ctx, loan)
+	return loan, nil
+}
+
+// Return закрывает активный займ; для закрытого или несуществующего займа возвращает domain.ErrLoanNotFound
+func (loanRepository *LoanRepositoryImpl) Return(ctx context.Context, id int) (*entity.Loan, error) {
+	tx, err := loanRepository.Conn.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				slog.ErrorContext(ctx, fmt.Sprintf("tx.Rollback failed: %v", rollbackErr))
+			}
+		}
+	}()
+
+	loan, err := scanLoan(tx.QueryRow(ctx, RETURN_LOAN, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = domain.ErrLoanNotFound
+		return nil, err
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	if _, err = tx.Exec(ctx, UPDATE_BOOK_RETURNED, loan.BookID); err != nil {
+		return nil, dbError(err)
+	}
+	if _, err = tx.Exec(ctx, UPDATE_USER_VERSION, loan.UserID); err != nil {
+		return nil, dbError(err)
+	}
+	if err = insertEvent(ctx, tx, entity.EventLoanReturned, loan.ID, loan); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err)
+	}
+	loanRepository.invalidateCache(ctx, loan)
+	return loan, nil
+}
+
+// Выдача и возврат меняют доступность книги и список книг пользователя
+func (loanRepository *LoanRepositoryImpl) invalidateCache(ctx context.Context, loan *entity.Loan) {
+	loanRepository.Caches.Invalidate(ctx, Changes{Users: []int{loan.UserID}, Books: []int{loan.BookID}})
+}
+
+func scanLoan(row pgx.Row) (*entity.Loan, error) {
+	loan := &entity.Loan{}
+	err := row.Scan(&loan.ID, &loan.UserID, &loan.BookID, &loan.TakenDate, &loan.DueDate, &loan.ReturnDate)
+	if err != nil {
+		return nil, err
+	}
+	return loan, nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	INSERT_OUTBOX_EVENT = `
+				  INSERT INTO outbox (type, aggregate_id, payload)
+				  VALUES ($1, $2, $3)`
+
+	// Пачка занимается до locked_until, а SKIP LOCKED не даёт двум экземплярам занять одни и те же события.
+	// Блокировка строк держится только до конца этого запроса, отправка идёт уже без транзакции
+	CLAIM_UNPUBLISHED_EVENTS = `
+				  UPDATE outbox
+				  SET locked_until = NOW() + MAKE_INTERVAL(secs => $2)
+				  WHERE id IN (SELECT id
+				               FROM outbox
+				               WHERE published_at IS NULL
+				                 AND (locked_until IS NULL OR locked_until < NOW())
+				               ORDER BY id
+				               LIMIT $1
+				               FOR UPDATE SKIP LOCKED)
+				  RETURNING id, type, aggregate_id, payload, created_at`
+
+	UPDATE_EVENTS_PUBLISHED = `
+				  UPDATE outbox
+				  SET published_at = NOW(), locked_until = NULL
+				  WHERE id = ANY($1)`
+
+	RELEASE_EVENTS = `
+				  UPDATE outbox
+				  SET locked_until = NULL
+				  WHERE id = ANY($1) AND published_at IS NULL`
+
+	PURGE_OUTBOX_EVENTS = `
+				  DELETE
+				  FROM outbox
+				  WHERE published_at < $1`
+)
+
+//go:generate mockgen -source=OutboxRepository.go -destination=mock/OutboxRepository.go -package=repository
+type OutboxRepository interface {
+	// Relay занимает до limit неотправленных событий на срок lease, передаёт их publish по порядку
+	// и помечает отправленными те, что он принял. На первой ошибке publish останавливается, освобождает
+	// неотправленные события и возвращает ошибку вместе с числом отправленных событий
+	Relay(ctx context.Context, limit int, lease time.Duration,
+		publish func(ctx context.Context, event entity.OutboxEvent) error) (int, error)
+	// Purge удаляет события, отправленные раньше publishedBefore
+	Purge(ctx context.Context, publishedBefore time.Time) (int64, error)
+}
+
+type OutboxRepositoryImpl struct {
+	Conn *pgxpool.Pool
+}
+
+func NewOutboxRepository(conn *pgxpool.Pool) OutboxRepository {
+	return &OutboxRepositoryImpl{Conn: conn}
+}
+
+func (outboxRepository *OutboxRepositoryImpl) Relay(ctx context.Context, limit int, lease time.Duration,
+	publish func(ctx context.Context, event entity.OutboxEvent) error) (int, error) {
+	rows, err := outboxRepository.Conn.Query(ctx, CLAIM_UNPUBLISHED_EVENTS, limit, lease.Seconds())
+	if err != nil {
+		return 0, dbError(err)
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (entity.OutboxEvent, error) {
+		var event entity.OutboxEvent
+		err := row.Scan(&event.ID, &event.Type, &event.AggregateID, &event.Payload, &event.CreatedAt)
+		return event, err
+	})
+	if err != nil {
+		return 0, dbError(err)
+	}
+	// RETURNING не сохраняет порядок подзапроса
+	slices.SortFunc(events, func(a, b entity.OutboxEvent) int { return cmp.Compare(a.ID, b.ID) })
+
+	var published, unpublished []int64
+	var publishErr error
+	for i, event := range events {
+		if publishErr = publish(ctx, event); publishErr != nil {
+			for _, event := range events[i:] {
+				unpublished = append(unpublished, event.ID)
+			}
+			break
+		}
+		published = append(published, event.ID)
+	}
+
+	// События уже ушли, поэтому отметка не прерывается отменой ctx. Если она не запишется,
+	// события уйдут ещё раз после lease: доставка гарантируется хотя бы однократная
+	ctx = context.WithoutCancel(ctx)
+	if len(published) > 0 {
+		if _, err := outboxRepository.Conn.Exec(ctx, UPDATE_EVENTS_PUBLISHED, published); err != nil {
+			return 0, dbError(err)
+		}
+	}
+	// Неотправленные события освобождаются сразу, а не по истечении lease
+	if len(unpublished) > 0 {
+		if _, err := outboxRepository.Conn.Exec(ctx, RELEASE_EVENTS, unpublished); err != nil {
+			publishErr = errors.Join(publishErr, dbError(err))
+		}
+	}
+	return len(published), publishErr
+}
+
+func (outboxRepository *OutboxRepositoryImpl) Purge(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	tag, err := outboxRepository.Conn.Exec(ctx, PURGE_OUTBOX_EVENTS, publishedBefore)
+	if err != nil {
+		return 0, dbError(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// insertEvent записывает событие в транзакции изменения, чтобы оно ушло тогда и только тогда, когда изменение сохранено
+func insertEvent(ctx context.Context, tx pgx.Tx, eventType string, aggregateID int, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, INSERT_OUTBOX_EVENT, eventType, aggregateID, data)
+	return dbError(err)
+}
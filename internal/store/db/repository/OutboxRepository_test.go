@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+	"github.com/Ablyamitov/simple-rest/internal/store"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOutbox проверяет, что изменения пишут события в своей транзакции, а Relay отдаёт их по порядку вне транзакции,
+// не отдаёт занятую пачку другим и оставляет в очереди то, что получатель не принял.
+// Нужна отдельная база в SIMPLE_REST_TEST_DB_URL
+func TestOutbox(t *testing.T) {
+	dbURL := os.Getenv("SIMPLE_REST_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("SIMPLE_REST_TEST_DB_URL is not set")
+	}
+	require.NoError(t, store.ApplyMigrations(dbURL))
+	ctx := context.Background()
+	conn, err := pgxpool.New(ctx, dbURL)
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+
+	caches := newMemoryCaches()
+	books, users, loans := NewBookRepository(conn, caches), NewUserRepository(conn, caches), NewLoanRepository(conn, caches)
+	outbox := NewOutboxRepository(conn)
+	drain := func() []entity.OutboxEvent {
+		var events []entity.OutboxEvent
+		for {
+			published, err := outbox.Relay(ctx, 100, time.Minute, func(ctx context.Context, event entity.OutboxEvent) error {
+				events = append(events, event)
+				return nil
+			})
+			require.NoError(t, err)
+			if published < 100 {
+				return events
+			}
+		}
+	}
+	types := func(events []entity.OutboxEvent) []string {
+		var result []string
+		for _, event := range events {
+			result = append(result, event.Type)
+		}
+		return result
+	}
+	drain()
+
+	book := &entity.Book{Title: "Outbox", Author: "Author"}
+	require.NoError(t, books.Create(ctx, book))
+	user := &entity.User{Name: "Outbox", Email: fmt.Sprintf("outbox-%d@example.com", time.Now().UnixNano()),
+		Password: "hash", Role: "user"}
+	require.NoError(t, users.Create(ctx, user))
+	loan, err := loans.Create(ctx, user.ID, book.ID, LoanTerms{DueDate: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = loans.Return(ctx, loan.ID)
+	require.NoError(t, err)
+	current, err := books.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	title := "Outbox patched"
+	_, err = books.Patch(ctx, book.ID, current.Version, BookPatch{Title: &title})
+	require.NoError(t, err)
+	// Отклонённое изменение события не оставляет
+	_, err = books.Update(ctx, &entity.Book{ID: book.ID, Title: "Stale", Author: "Author", Version: current.Version})
+	require.ErrorIs(t, err, domain.ErrVersionMismatch)
+	coverUpdatedAt := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, books.UpdateCover(ctx, book.ID, coverUpdatedAt))
+	require.NoError(t, books.Delete(ctx, book.ID))
+	require.NoError(t, books.Restore(ctx, book.ID))
+	require.ErrorIs(t, books.Restore(ctx, book.ID), domain.ErrBookNotFound)
+
+	sinkDown := errors.New("sink is down")
+	var accepted []entity.OutboxEvent
+	concurrent := -1
+	published, err := outbox.Relay(ctx, 100, time.Minute, func(ctx context.Context, event entity.OutboxEvent) error {
+		// Пока пачка отправляется, другой экземпляр её не получит
+		if concurrent < 0 {
+			concurrent = len(drain())
+		}
+		if event.Type == entity.EventLoanStarted {
+			return sinkDown
+		}
+		accepted = append(accepted, event)
+		return nil
+	})
+	require.ErrorIs(t, err, sinkDown)
+	assert.Equal(t, 0, concurrent)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{entity.EventBookCreated, entity.EventUserRegistered}, types(accepted))
+	assert.Equal(t, book.ID, accepted[0].AggregateID)
+	assert.Equal(t, user.ID, accepted[1].AggregateID)
+	assert.NotContains(t, string(accepted[1].Payload), "password")
+
+	// Неотправленные события освобождены сразу, не дожидаясь lease
+	events := drain()
+	// Обложка и восстановление тоже меняют версию книги, поэтому тоже пишут BookUpdated
+	assert.Equal(t, []string{entity.EventLoanStarted, entity.EventLoanReturned, entity.EventBookUpdated,
+		entity.EventBookUpdated, entity.EventBookUpdated}, types(events))
+	assert.Equal(t, loan.ID, events[0].AggregateID)
+	assert.Contains(t, string(events[2].Payload), title)
+	var covered, restored entity.Book
+	require.NoError(t, json.Unmarshal(events[3].Payload, &covered))
+	require.NoError(t, json.Unmarshal(events[4].Payload, &restored))
+	require.NotNil(t, covered.CoverUpdatedAt)
+	assert.True(t, coverUpdatedAt.Equal(*covered.CoverUpdatedAt))
+	assert.Equal(t, current.Version+2, covered.Version)
+	assert.Equal(t, current.Version+3, restored.Version)
+	assert.Empty(t, drain())
+}
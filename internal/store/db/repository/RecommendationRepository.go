@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// Косинусная мера по совместным выдачам: co / sqrt(readers(a) * readers(b)),
+	// для каждой книги оставляем limit самых похожих
+	SELECT_SIMILAR_BOOKS = `
+				  WITH borrowed AS (
+				      SELECT DISTINCT user_id, book_id
+				      FROM user_books),
+				  readers AS (
+				      SELECT book_id, COUNT(*) AS cnt
+				      FROM borrowed
+				      GROUP BY book_id),
+				  pairs AS (
+				      SELECT a.book_id, b.book_id AS similar_id, COUNT(*) AS co
+				      FROM borrowed AS a
+				          JOIN borrowed AS b
+				              ON a.user_id = b.user_id AND a.book_id <> b.book_id
+				      GROUP BY a.book_id, b.book_id),
+				  scored AS (
+				      SELECT p.book_id, p.similar_id, p.co / SQRT(ra.cnt * rb.cnt) AS score
+				      FROM pairs AS p
+				          JOIN readers AS ra ON ra.book_id = p.book_id
+				          JOIN readers AS rb ON rb.book_id = p.similar_id),
+				  ranked AS (
+				      SELECT book_id, similar_id, score,
+				             ROW_NUMBER() OVER (PARTITION BY book_id ORDER BY score DESC, similar_id) AS rank
+				      FROM scored)
+				  SELECT book_id, similar_id, score
+				  FROM ranked
+				  WHERE rank <= $1
+				  ORDER BY book_id, rank`
+
+	SELECT_USER_BORROWED_BOOK_IDS = `
+				  SELECT DISTINCT book_id
+				  FROM user_books
+				  WHERE user_id = $1`
+
+	SELECT_BOOKS_BY_IDS = `
+				  SELECT id, title, author, category, available, cover_updated_at, rating_count, rating_sum, version
+				  FROM books
+				  WHERE id = ANY($1) AND deleted_at IS NULL`
+
+	SELECT_BOOKS_LIKE = `
+				  SELECT b.id, b.title, b.author, b.category, b.available, b.cover_updated_at, b.rating_count, b.rating_sum, b.version
+				  FROM books AS b
+				      JOIN books AS src ON src.id = $1
+				  WHERE b.id <> src.id
+				    AND b.deleted_at IS NULL
+				    AND (b.author = src.author OR (src.category <> '' AND b.category = src.category))
+				  ORDER BY (b.author = src.author) DESC, b.rating_count DESC, b.id
+				  LIMIT $2`
+
+	SELECT_POPULAR_BOOKS = `
+				  SELECT b.id, b.title, b.author, b.category, b.available, b.cover_updated_at, b.rating_count, b.rating_sum, b.version
+				  FROM books AS b
+				      LEFT JOIN user_books AS ub ON ub.book_id = b.id
+				  WHERE NOT b.id = ANY($1)
+				    AND b.deleted_at IS NULL
+				  GROUP BY b.id
+				  ORDER BY COUNT(ub.book_id) DESC, b.rating_count DESC, b.id
+				  LIMIT $2`
+)
+
+type SimilarBook struct {
+	BookID    int     `json:"book_id"`
+	SimilarID int     `json:"similar_id"`
+	Score     float64 `json:"score"`
+}
+
+//go:generate mockgen -source=RecommendationRepository.go -destination=mock/RecommendationRepository.go -package=repository
+type RecommendationRepository interface {
+	GetSimilarBooks(ctx context.Context, limit int) ([]SimilarBook, error)
+	GetBorrowedBookIDs(ctx context.Context, userId int) ([]int, error)
+	GetByIDs(ctx context.Context, ids []int) ([]entity.Book, error)
+	GetBooksLike(ctx context.Context, bookId int, limit int) ([]entity.Book, error)
+	GetPopular(ctx context.Context, excludeIds []int, limit int) ([]entity.Book, error)
+}
+
+type RecommendationRepositoryImpl struct {
+	Conn *pgxpool.Pool
+}
+
+func NewRecommendationRepository(conn *pgxpool.Pool) RecommendationRepository {
+	return &RecommendationRepositoryImpl{Conn: conn}
+}
+
+func (recommendationRepository *RecommendationRepositoryImpl) GetSimilarBooks(ctx context.Context, limit int) ([]SimilarBook, error) {
+	rows, err := recommendationRepository.Conn.Query(ctx, SELECT_SIMILAR_BOOKS, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var similar []SimilarBook
+	for rows.Next() {
+		var pair SimilarBook
+		if err = rows.Scan(&pair.BookID, &pair.SimilarID, &pair.Score); err != nil {
+			return nil, err
+		}
+		similar = append(similar, pair)
+	}
+	return similar, rows.Err()
+}
+
+func (recommendationRepository *RecommendationRepositoryImpl) GetBorrowedBookIDs(ctx context.Context, userId int) ([]int, error) {
+	rows, err := recommendationRepository.Conn.Query(ctx, SELECT_USER_BORROWED_BOOK_IDS, userId)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
+
+func (recommendationRepository *RecommendationRepositoryImpl) GetByIDs(ctx context.Context, ids []int) ([]entity.Book, error) {
+	return recommendationRepository.queryBooks(ctx, SELECT_BOOKS_BY_IDS, ids)
+}
+
+func (recommendationRepository *RecommendationRepositoryImpl) GetBooksLike(ctx context.Context, bookId int, limit int) ([]entity.Book, error) {
+	return recommendationRepository.queryBooks(ctx, SELECT_BOOKS_LIKE, bookId, limit)
+}
+
+func (recommendationRepository *RecommendationRepositoryImpl) GetPopular(ctx context.Context, excludeIds []int, limit int) ([]entity.Book, error) {
+	if excludeIds == nil {
+		excludeIds = []int{}
+	}
+	return recommendationRepository.queryBooks(ctx, SELECT_POPULAR_BOOKS, excludeIds, limit)
+}
+
+func (recommendationRepository *RecommendationRepositoryImpl) queryBooks(ctx context.Context, sql string, args ...any) ([]entity.Book, error) {
+	rows, err := recommendationRepository.Conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []entity.Book
+	for rows.Next() {
+		var book entity.Book
+		err = rows.Scan(&book.ID, &book.Title, &book.Author, &book.Category, &book.Available, &book.CoverUpdatedAt, &book.RatingCount, &book.RatingSum, &book.Version)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	return books, rows.Err()
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Ablyamitov/simple-rest/internal/domain"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	SELECT_REVIEWS_BY_BOOK = `
+				  SELECT id, book_id, user_id, rating, text, status, created_at, updated_at
+				  FROM reviews
+				  WHERE book_id = $1 AND status = 'approved'
+				  ORDER BY created_at DESC`
+
+	SELECT_REVIEWS_BY_STATUS = `
+				  SELECT id, book_id, user_id, rating, text, status, created_at, updated_at
+				  FROM reviews
+				  WHERE status = $1
+				  ORDER BY created_at`
+
+	SELECT_REVIEW_BY_ID = `
+				  SELECT id, book_id, user_id, rating, text, status, created_at, updated_at
+				  FROM reviews
+				  WHERE id = $1`
+
+	SELECT_REVIEW_FOR_UPDATE = `
+				  SELECT book_id, rating, status
+				  FROM reviews
+				  WHERE id = $1
+				  FOR UPDATE`
+
+	SELECT_COMPLETED_LOAN_EXISTS = `
+				  SELECT EXISTS(
+				      SELECT 1
+				      FROM user_books
+				      WHERE user_id = $1 AND book_id = $2 AND return_date IS NOT NULL)`
+
+	INSERT_REVIEW = `
+				  INSERT INTO reviews (book_id, user_id, rating, text)
+				  VALUES ($1, $2, $3, $4)
+				  ON CONFLICT (book_id, user_id) DO NOTHING
+				  RETURNING id, status, created_at, updated_at`
+
+	UPDATE_REVIEW = `
+				  UPDATE reviews
+				  SET rating = $1, text = $2, status = 'pending', updated_at = NOW()
+				  WHERE id = $3
+				  RETURNING id, book_id, user_id, rating, text, status, created_at, updated_at`
+
+	UPDATE_REVIEW_STATUS = `
+				  UPDATE reviews
+				  SET status = $1, updated_at = NOW()
+				  WHERE id = $2
+				  RETURNING id, book_id, user_id, rating, text, status, created_at, updated_at`
+
+	DELETE_REVIEW = `
+				  DELETE
+				  FROM reviews
+				  WHERE id = $1`
+
+	UPDATE_BOOK_RATING = `
+				  UPDATE books
+				  SET rating_count = rating_count + $1, rating_sum = rating_sum + $2, version = version + 1
+				  WHERE id = $3`
+)
+
+var (
+	ErrReviewExists     = domain.NewError(domain.ErrConflict, "user has already reviewed this book")
+	ErrLoanNotCompleted = domain.NewError(domain.ErrConflict, "book can be reviewed only after a completed loan")
+	ErrReviewNotFound   = domain.NewError(domain.ErrNotFound, "review not found")
+)
+
+//go:generate mockgen -source=ReviewRepository.go -destination=mock/ReviewRepository.go -package=repository
+type ReviewRepository interface {
+	GetByBook(ctx context.Context, bookId int) ([]entity.Review, error)
+	GetByStatus(ctx context.Context, status string) ([]entity.Review, error)
+	GetByID(ctx context.Context, id int) (*entity.Review, error)
+	Create(ctx context.Context, review *entity.Review) error
+	Update(ctx context.Context, review *entity.Review) (*entity.Review, error)
+	SetStatus(ctx context.Context, id int, status string) (*entity.Review, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type ReviewRepositoryImpl struct {
+	Conn   *pgxpool.Pool
+	Caches *Caches
+}
+
+func NewReviewRepository(conn *pgxpool.Pool, caches *Caches) ReviewRepository {
+	return &ReviewRepositoryImpl{Conn: conn, Caches: caches}
+}
+
+func (reviewRepository *ReviewRepositoryImpl) GetByBook(ctx context.Context, bookId int) ([]entity.Review, error) {
+	return reviewRepository.query(ctx, SELECT_REVIEWS_BY_BOOK, bookId)
+}
+
+func (reviewRepository *ReviewRepositoryImpl) GetByStatus(ctx context.Context, status string) ([]entity.Review, error) {
+	return reviewRepository.query(ctx, SELECT_REVIEWS_BY_STATUS, status)
+}
+
+func (reviewRepository *ReviewRepositoryImpl) GetByID(ctx context.Context, id int) (*entity.Review, error) {
+	review := &entity.Review{}
+	err := reviewRepository.Conn.QueryRow(ctx, SELECT_REVIEW_BY_ID, id).
+		Scan(&review.ID, &review.BookID, &review.UserID, &review.Rating, &review.Text, &review.Status, &review.CreatedAt, &review.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+	return review, nil
+}
+
+func (reviewRepository *ReviewRepositoryImpl) Create(ctx context.Context, review *entity.Review) error {
+	var completed bool
+	err := reviewRepository.Conn.QueryRow(ctx, SELECT_COMPLETED_LOAN_EXISTS, review.UserID, review.BookID).Scan(&completed)
+	if err != nil {
+		return dbError(err)
+	}
+	if !completed {
+		return ErrLoanNotCompleted
+	}
+
+	// Новый отзыв попадает в очередь модерации и не влияет на рейтинг до одобрения
+	err = reviewRepository.Conn.QueryRow(ctx, INSERT_REVIEW, review.BookID, review.UserID, review.Rating, review.Text).
+		Scan(&review.ID, &review.Status, &review.CreatedAt, &review.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrReviewExists
+	}
+	return dbError(err)
+}
+
+func (reviewRepository *ReviewRepositoryImpl) Update(ctx context.Context, review *entity.Review) (*entity.Review, error) {
+	return reviewRepository.change(ctx, review.ID, func(tx pgx.Tx) (*entity.Review, error) {
+		return scanReview(tx.QueryRow(ctx, UPDATE_REVIEW, review.Rating, review.Text, review.ID))
+	})
+}
+
+func (reviewRepository *ReviewRepositoryImpl) SetStatus(ctx context.Context, id int, status string) (*entity.Review, error) {
+	return reviewRepository.change(ctx, id, func(tx pgx.Tx) (*entity.Review, error) {
+		return scanReview(tx.QueryRow(ctx, UPDATE_REVIEW_STATUS, status, id))
+	})
+}
+
+func (reviewRepository *ReviewRepositoryImpl) Delete(ctx context.Context, id int) error {
+	_, err := reviewRepository.change(ctx, id, func(tx pgx.Tx) (*entity.Review, error) {
+		_, err := tx.Exec(ctx, DELETE_REVIEW, id)
+		return nil, dbError(err)
+	})
+	return dbError(err)
+}
+
+// change выполняет изменение отзыва в транзакции и инкрементально пересчитывает
+// rating_count/rating_sum книги: в рейтинге учитываются только одобренные отзывы.
+func (reviewRepository *ReviewRepositoryImpl) change(ctx context.Context, id int, apply func(tx pgx.Tx) (*entity.Review, error)) (*entity.Review, error) {
+	tx, err := reviewRepository.Conn.Begin(ctx)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				slog.ErrorContext(ctx, fmt.Sprintf("tx.Rollback failed: %v", rollbackErr))
+			}
+		}
+	}()
+
+	var bookId, oldRating int
+	var oldStatus string
+	err = tx.QueryRow(ctx, SELECT_REVIEW_FOR_UPDATE, id).Scan(&bookId, &oldRating, &oldStatus)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	review, err := apply(tx)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	countDelta, sumDelta := 0, 0
+	if oldStatus == entity.ReviewStatusApproved {
+		countDelta--
+		sumDelta -= oldRating
+	}
+	if review != nil && review.Status == entity.ReviewStatusApproved {
+		countDelta++
+		sumDelta += review.Rating
+	}
+	if countDelta != 0 || sumDelta != 0 {
+		_, err = tx.Exec(ctx, UPDATE_BOOK_RATING, countDelta, sumDelta, bookId)
+		if err != nil {
+			return nil, dbError(err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, dbError(err)
+	}
+
+	if countDelta != 0 || sumDelta != 0 {
+		// Удаление книги с кеша
+		reviewRepository.Caches.Invalidate(ctx, Changes{Books: []int{bookId}})
+	}
+	return review, nil
+}
+
+func (reviewRepository *ReviewRepositoryImpl) query(ctx context.Context, sql string, arg any) ([]entity.Review, error) {
+	rows, err := reviewRepository.Conn.Query(ctx, sql, arg)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	var reviews []entity.Review
+	for rows.Next() {
+		var review entity.Review
+		err = rows.Scan(&review.ID, &review.BookID, &review.UserID, &review.Rating, &review.Text, &review.Status, &review.CreatedAt, &review.UpdatedAt)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+func scanReview(row pgx.Row) (*entity.Review, error) {
+	review := &entity.Review{}
+	err := row.Scan(&review.ID, &review.BookID, &review.UserID, &review.Rating, &review.Text, &review.Status, &review.CreatedAt, &review.UpdatedAt)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	return review, nil
+}
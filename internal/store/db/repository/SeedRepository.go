@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// Строки обновляются, только если что-то изменилось, поэтому повторный запуск не меняет их версии.
+	// Пароль существующих пользователей не трогается: хеш bcrypt каждый раз разный
+	UPSERT_SEED_USERS = `
+				  INSERT INTO users (seed_key, name, email, password, role)
+				  SELECT * FROM UNNEST($1::varchar[], $2::varchar[], $3::varchar[], $4::varchar[], $5::varchar[])
+				  ON CONFLICT (seed_key) DO UPDATE
+				  SET name = EXCLUDED.name, email = EXCLUDED.email, role = EXCLUDED.role, deleted_at = NULL,
+				      version = users.version + 1
+				  WHERE (users.name, users.email, users.role, users.deleted_at)
+				      IS DISTINCT FROM (EXCLUDED.name, EXCLUDED.email, EXCLUDED.role, NULL)`
+
+	SELECT_SEED_USERS = `
+				  SELECT seed_key, id
+				  FROM users
+				  WHERE seed_key IS NOT NULL`
+
+	SELECT_STALE_SEED_USERS = `
+				  SELECT id
+				  FROM users
+				  WHERE seed_key IS NOT NULL AND NOT (seed_key = ANY($1))
+				  FOR UPDATE`
+
+	UPSERT_SEED_BOOKS = `
+				  INSERT INTO books (seed_key, title, author, category)
+				  SELECT * FROM UNNEST($1::varchar[], $2::varchar[], $3::varchar[], $4::varchar[])
+				  ON CONFLICT (seed_key) DO UPDATE
+				  SET title = EXCLUDED.title, author = EXCLUDED.author, category = EXCLUDED.category, deleted_at = NULL,
+				      version = books.version + 1
+				  WHERE (books.title, books.author, books.category, books.deleted_at)
+				      IS DISTINCT FROM (EXCLUDED.title, EXCLUDED.author, EXCLUDED.category, NULL)`
+
+	SELECT_SEED_BOOKS = `
+				  SELECT seed_key, id
+				  FROM books
+				  WHERE seed_key IS NOT NULL`
+
+	DELETE_STALE_SEED_BOOKS = `
+				  DELETE
+				  FROM books
+				  WHERE seed_key IS NOT NULL AND NOT (seed_key = ANY($1))
+				  RETURNING id`
+
+	// История займов сидовых пользователей пересоздаётся целиком, займы остальных пользователей не трогаются
+	DELETE_SEED_LOANS = `
+				  DELETE
+				  FROM user_books AS ub
+				  USING users AS u
+				  WHERE u.id = ub.user_id AND u.seed_key IS NOT NULL`
+
+	// Книги, которые сейчас на руках у пользователей не из сида: сидовые активные займы на них пропускаются
+	SELECT_BOOKS_ON_LOAN = `
+				  SELECT book_id
+				  FROM user_books
+				  WHERE return_date IS NULL AND book_id = ANY($1)`
+
+	// Выданная книга недоступна: флаг переключается только там, где он расходится с займами
+	UPDATE_SEED_BOOKS_AVAILABILITY = `
+				  UPDATE books AS b
+				  SET available = NOT b.available, version = b.version + 1
+				  WHERE b.seed_key IS NOT NULL
+				    AND b.available = EXISTS(
+				        SELECT 1
+				        FROM user_books
+				        WHERE book_id = b.id AND return_date IS NULL)`
+)
+
+// SeedUser - пользователь набора данных; Password - уже захешированный пароль
+type SeedUser struct {
+	Key      string
+	Name     string
+	Email    string
+	Password string
+	Role     string
+}
+
+type SeedBook struct {
+	Key      string
+	Title    string
+	Author   string
+	Category string
+}
+
+// SeedLoan ссылается на пользователя и книгу по ключам сида; ReturnDate == nil - книга ещё на руках
+type SeedLoan struct {
+	UserKey    string
+	BookKey    string
+	TakenDate  time.Time
+	DueDate    time.Time
+	ReturnDate *time.Time
+}
+
+type SeedDataset struct {
+	Users []SeedUser
+	Books []SeedBook
+	Loans []SeedLoan
+}
+
+// SeedResult - сколько строк набора оказалось в базе и сколько лишних сидовых строк удалено
+type SeedResult struct {
+	Users        int
+	Books        int
+	Loans        int
+	SkippedLoans int
+	RemovedUsers int64
+	RemovedBooks int64
+}
+
+//go:generate mockgen -source=SeedRepository.go -destination=mock/SeedRepository.go -package=repository
+type SeedRepository interface {
+	Apply(ctx context.Context, dataset SeedDataset) (SeedResult, error)
+}
+
+type SeedRepositoryImpl struct {
+	Conn   *pgxpool.Pool
+	Caches *Caches
+}
+
+func NewSeedRepository(conn *pgxpool.Pool, caches *Caches) SeedRepository {
+	return &SeedRepositoryImpl{Conn: conn, Caches: caches}
+}
+
+// Apply приводит сидовые строки к dataset в одной транзакции: пользователи и книги обновляются по ключу
+// и сохраняют id, строки, которых больше нет в наборе, удаляются, история займов пересоздаётся
+func (seedRepository *SeedRepositoryImpl) Apply(ctx context.Context, dataset SeedDataset) (SeedResult, error) {
+	var result SeedResult
+	tx, err := seedRepository.Conn.Begin(ctx)
+	if err != nil {
+		return result, dbError(err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				slog.ErrorContext(ctx, fmt.Sprintf("tx.Rollback failed: %v", rollbackErr))
+			}
+		}
+	}()
+
+	// Кроме сидовых строк меняются рейтинги книг удалённых пользователей, а удалённые книги пропадают у их читателей
+	var changes Changes
+	userIds, err := seedRepository.upsertUsers(ctx, tx, dataset.Users, &result, &changes)
+	if err != nil {
+		return result, err
+	}
+	bookIds, err := seedRepository.upsertBooks(ctx, tx, dataset.Books, &result, &changes)
+	if err != nil {
+		return result, err
+	}
+
+	if _, err = tx.Exec(ctx, DELETE_SEED_LOANS); err != nil {
+		return result, dbError(err)
+	}
+	rows, err := tx.Query(ctx, SELECT_BOOKS_ON_LOAN, mapValues(bookIds))
+	if err != nil {
+		return result, dbError(err)
+	}
+	onLoan, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return result, dbError(err)
+	}
+	busy := make(map[int]bool, len(onLoan))
+	for _, bookId := range onLoan {
+		busy[bookId] = true
+	}
+
+	loans := make([][]any, 0, len(dataset.Loans))
+	for _, loan := range dataset.Loans {
+		userId, bookId := userIds[loan.UserKey], bookIds[loan.BookKey]
+		if userId == 0 || bookId == 0 {
+			err = fmt.Errorf("seed loan references unknown user %q or book %q", loan.UserKey, loan.BookKey)
+			return result, err
+		}
+		if loan.ReturnDate == nil && busy[bookId] {
+			result.SkippedLoans++
+			continue
+		}
+		loans = append(loans, []any{userId, bookId, loan.TakenDate, loan.DueDate, loan.ReturnDate})
+	}
+	copied, err := tx.CopyFrom(ctx, pgx.Identifier{"user_books"},
+		[]string{"user_id", "book_id", "taken_date", "due_date", "return_date"}, pgx.CopyFromRows(loans))
+	if err != nil {
+		return result, dbError(err)
+	}
+	result.Loans = int(copied)
+
+	if _, err = tx.Exec(ctx, UPDATE_SEED_BOOKS_AVAILABILITY); err != nil {
+		return result, dbError(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return result, dbError(err)
+	}
+
+	// Удаление сидовых пользователей и книг с кеша
+	changes.Users = append(changes.Users, mapValues(userIds)...)
+	changes.Books = append(changes.Books, mapValues(bookIds)...)
+	seedRepository.Caches.Invalidate(ctx, changes)
+	return result, nil
+}
+
+// upsertUsers возвращает id сидовых пользователей по ключу; лишние удаляются так же, как при окончательной очистке
+func (seedRepository *SeedRepositoryImpl) upsertUsers(ctx context.Context, tx pgx.Tx, users []SeedUser,
+	result *SeedResult, changes *Changes) (map[string]int, error) {
+	keys := make([]string, len(users))
+	names := make([]string, len(users))
+	emails := make([]string, len(users))
+	passwords := make([]string, len(users))
+	roles := make([]string, len(users))
+	for i, user := range users {
+		keys[i], names[i], emails[i], passwords[i], roles[i] = user.Key, user.Name, user.Email, user.Password, user.Role
+	}
+
+	rows, err := tx.Query(ctx, SELECT_STALE_SEED_USERS, keys)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	staleIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, dbError(err)
+	}
+	if len(staleIds) > 0 {
+		rows, err := tx.Query(ctx, UPDATE_RATINGS_OF_PURGED_USERS, staleIds)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		ratedIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return nil, dbError(err)
+		}
+		changes.Books = append(changes.Books, ratedIds...)
+		changes.Users = append(changes.Users, staleIds...)
+
+		tag, err := tx.Exec(ctx, PURGE_USERS, staleIds)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		result.RemovedUsers = tag.RowsAffected()
+	}
+
+	if _, err := tx.Exec(ctx, UPSERT_SEED_USERS, keys, names, emails, passwords, roles); err != nil {
+		return nil, dbError(err)
+	}
+	ids, err := selectSeedIds(ctx, tx, SELECT_SEED_USERS)
+	result.Users = len(ids)
+	return ids, err
+}
+
+func (seedRepository *SeedRepositoryImpl) upsertBooks(ctx context.Context, tx pgx.Tx, books []SeedBook,
+	result *SeedResult, changes *Changes) (map[string]int, error) {
+	keys := make([]string, len(books))
+	titles := make([]string, len(books))
+	authors := make([]string, len(books))
+	categories := make([]string, len(books))
+	for i, book := range books {
+		keys[i], titles[i], authors[i], categories[i] = book.Key, book.Title, book.Author, book.Category
+	}
+
+	rows, err := tx.Query(ctx, DELETE_STALE_SEED_BOOKS, keys)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	removedIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, dbError(err)
+	}
+	result.RemovedBooks = int64(len(removedIds))
+	changes.Books = append(changes.Books, removedIds...)
+
+	if _, err := tx.Exec(ctx, UPSERT_SEED_BOOKS, keys, titles, authors, categories); err != nil {
+		return nil, dbError(err)
+	}
+	ids, err := selectSeedIds(ctx, tx, SELECT_SEED_BOOKS)
+	result.Books = len(ids)
+	return ids, err
+}
+
+func selectSeedIds(ctx context.Context, tx pgx.Tx, query string) (map[string]int, error) {
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	ids := map[string]int{}
+	for rows.Next() {
+		var key string
+		var id int
+		if err := rows.Scan(&key, &id); err != nil {
+			return nil, dbError(err)
+		}
+		ids[key] = id
+	}
+	return ids, dbError(rows.Err())
+}
+
+func mapValues(m map[string]int) []int {
+	values := make([]int, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+	return values
+}
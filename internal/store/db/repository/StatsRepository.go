@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Во всех запросах $1/$2 - границы диапазона дат (NULL - без границы), $3 - категория (” - все)
+const (
+	SELECT_MOST_BORROWED = `
+				  SELECT b.id, b.title, b.author, b.category, b.available, b.cover_updated_at, b.rating_count, b.rating_sum, b.version,
+				         SUM(s.loans)::int AS loans
+				  FROM stats_daily_loans AS s
+				      JOIN books AS b ON b.id = s.book_id
+				  WHERE b.deleted_at IS NULL
+				    AND ($1::date IS NULL OR s.day >= $1)
+				    AND ($2::date IS NULL OR s.day <= $2)
+				    AND ($3 = '' OR s.category = $3)
+				  GROUP BY b.id
+				  ORDER BY loans DESC, b.id
+				  LIMIT $4`
+
+	SELECT_LOAN_LENGTH = `
+				  SELECT s.category, SUM(s.returned)::int AS returned,
+				         SUM(s.returned_seconds)::float8 / NULLIF(SUM(s.returned), 0) / 86400 AS average_days
+				  FROM stats_daily_loans AS s
+				  WHERE ($1::date IS NULL OR s.day >= $1)
+				    AND ($2::date IS NULL OR s.day <= $2)
+				    AND ($3 = '' OR s.category = $3)
+				  GROUP BY s.category
+				  HAVING SUM(s.returned) > 0
+				  ORDER BY s.category`
+
+	SELECT_NEVER_BORROWED = `
+				  SELECT b.id, b.title, b.author, b.category, b.available, b.cover_updated_at, b.rating_count, b.rating_sum, b.version
+				  FROM books AS b
+				  WHERE b.deleted_at IS NULL
+				    AND ($3 = '' OR b.category = $3)
+				    AND NOT EXISTS(
+				        SELECT 1
+				        FROM stats_daily_loans AS s
+				        WHERE s.book_id = b.id
+				          AND ($1::date IS NULL OR s.day >= $1)
+				          AND ($2::date IS NULL OR s.day <= $2))
+				  ORDER BY b.id`
+
+	SELECT_ACTIVE_READERS = `
+				  SELECT s.week, COUNT(DISTINCT s.user_id) AS readers
+				  FROM stats_weekly_readers AS s
+				  WHERE ($1::date IS NULL OR s.week >= DATE_TRUNC('week', $1::date))
+				    AND ($2::date IS NULL OR s.week <= $2)
+				    AND ($3 = '' OR s.category = $3)
+				  GROUP BY s.week
+				  ORDER BY s.week`
+
+	REFRESH_STATS_DAILY_LOANS    = `REFRESH MATERIALIZED VIEW CONCURRENTLY stats_daily_loans`
+	REFRESH_STATS_WEEKLY_READERS = `REFRESH MATERIALIZED VIEW CONCURRENTLY stats_weekly_readers`
+)
+
+type StatsFilter struct {
+	From     *time.Time
+	To       *time.Time
+	Category string
+}
+
+//go:generate mockgen -source=StatsRepository.go -destination=mock/StatsRepository.go -package=repository
+type StatsRepository interface {
+	GetMostBorrowed(ctx context.Context, filter StatsFilter, limit int) ([]entity.BookLoans, error)
+	GetLoanLength(ctx context.Context, filter StatsFilter) ([]entity.LoanLength, error)
+	GetNeverBorrowed(ctx context.Context, filter StatsFilter) ([]entity.Book, error)
+	GetActiveReaders(ctx context.Context, filter StatsFilter) ([]entity.WeeklyReaders, error)
+	Refresh(ctx context.Context) error
+}
+
+type StatsRepositoryImpl struct {
+	Conn *pgxpool.Pool
+}
+
+func NewStatsRepository(conn *pgxpool.Pool) StatsRepository {
+	return &StatsRepositoryImpl{Conn: conn}
+}
+
+func (statsRepository *StatsRepositoryImpl) GetMostBorrowed(ctx context.Context, filter StatsFilter, limit int) ([]entity.BookLoans, error) {
+	rows, err := statsRepository.Conn.Query(ctx, SELECT_MOST_BORROWED, filter.From, filter.To, filter.Category, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []entity.BookLoans
+	for rows.Next() {
+		var bookLoans entity.BookLoans
+		book := &bookLoans.Book
+		err = rows.Scan(&book.ID, &book.Title, &book.Author, &book.Category, &book.Available, &book.CoverUpdatedAt,
+			&book.RatingCount, &book.RatingSum, &book.Version, &bookLoans.Loans)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, bookLoans)
+	}
+	return result, rows.Err()
+}
+
+func (statsRepository *StatsRepositoryImpl) GetLoanLength(ctx context.Context, filter StatsFilter) ([]entity.LoanLength, error) {
+	rows, err := statsRepository.Conn.Query(ctx, SELECT_LOAN_LENGTH, filter.From, filter.To, filter.Category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []entity.LoanLength
+	for rows.Next() {
+		var loanLength entity.LoanLength
+		if err = rows.Scan(&loanLength.Category, &loanLength.Returned, &loanLength.AverageDays); err != nil {
+			return nil, err
+		}
+		result = append(result, loanLength)
+	}
+	return result, rows.Err()
+}
+
+func (statsRepository *StatsRepositoryImpl) GetNeverBorrowed(ctx context.Context, filter StatsFilter) ([]entity.Book, error) {
+	rows, err := statsRepository.Conn.Query(ctx, SELECT_NEVER_BORROWED, filter.From, filter.To, filter.Category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []entity.Book
+	for rows.Next() {
+		var book entity.Book
+		err = rows.Scan(&book.ID, &book.Title, &book.Author, &book.Category, &book.Available, &book.CoverUpdatedAt,
+			&book.RatingCount, &book.RatingSum, &book.Version)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	return books, rows.Err()
+}
+
+func (statsRepository *StatsRepositoryImpl) GetActiveReaders(ctx context.Context, filter StatsFilter) ([]entity.WeeklyReaders, error) {
+	rows, err := statsRepository.Conn.Query(ctx, SELECT_ACTIVE_READERS, filter.From, filter.To, filter.Category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []entity.WeeklyReaders
+	for rows.Next() {
+		var weeklyReaders entity.WeeklyReaders
+		if err = rows.Scan(&weeklyReaders.Week, &weeklyReaders.Readers); err != nil {
+			return nil, err
+		}
+		result = append(result, weeklyReaders)
+	}
+	return result, rows.Err()
+}
+
+// Refresh пересчитывает материализованные представления, не блокируя чтение статистики
+func (statsRepository *StatsRepositoryImpl) Refresh(ctx context.Context) error {
+	if _, err := statsRepository.Conn.Exec(ctx, REFRESH_STATS_DAILY_LOANS); err != nil {
+		return err
+	}
+	_, err := statsRepository.Conn.Exec(ctx, REFRESH_STATS_WEEKLY_READERS)
+	return err
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// withTx выполняет apply в транзакции: фиксирует её, если apply вернул nil, и откатывает иначе
+func withTx(ctx context.Context, conn *pgxpool.Pool, apply func(tx pgx.Tx) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return dbError(err)
+	}
+	if err = apply(tx); err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			slog.ErrorContext(ctx, fmt.Sprintf("tx.Rollback failed: %v", rollbackErr))
+		}
+		return err
+	}
+	return dbError(tx.Commit(ctx))
+}
@@ -2,102 +2,162 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/Ablyamitov/simple-rest/internal/domain"
 	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/redis/go-redis/v9"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const (
 	SELECT_ALL_USERS = `
-				  SELECT id, name, email, password, role
-				  FROM users`
+				  SELECT id, name, email, password, role, version
+				  FROM users
+				  WHERE deleted_at IS NULL`
 
 	SELECT_ALL_USERS_BOOKS = `
 			 	  SELECT ub.user_id, ub.book_id, b.title, b.author, b.available 
 			 	  FROM books AS b 
-				  JOIN user_books AS ub ON b.id = ub.book_id`
+				  JOIN user_books AS ub ON b.id = ub.book_id
+				  WHERE ub.return_date IS NULL`
 
 	SELECT_USER_BY_ID = `
-				  SELECT id, name, email, password, role
+				  SELECT id, name, email, password, role, version
 				  FROM users 
-				  WHERE id=$1`
+				  WHERE id=$1 AND deleted_at IS NULL`
 
 	SELECT_USER_BY_EMAIL = `
-				  SELECT id, name, email, password, role
+				  SELECT id, name, email, password, role, version
 				  FROM users 
-				  WHERE email=$1`
+				  WHERE email=$1 AND deleted_at IS NULL`
 
 	SELECT_ALL_USER_BOOKS_BY_ID = `
 				  SELECT b.id, b.title, b.author, b.available 
 				  FROM books AS b 
 				      JOIN user_books AS ub 
 				          ON b.id = ub.book_id 
-				  WHERE ub.user_id = $1`
+				  WHERE ub.user_id = $1 AND ub.return_date IS NULL`
 
 	INSERT_USER = `
 				  INSERT INTO users (name, email, password, role) 
 				  VALUES ($1, $2, $3, $4) 
 				  RETURNING id`
 
+	// Версия 0 означает If-Match: *, то есть обновление без проверки версии
 	UPDATE_USER = `
 				  UPDATE users 
-				  SET name = $1, email=$2
-				  WHERE id = $3`
+				  SET name = $1, email=$2, version = version + 1
+				  WHERE id = $3 AND deleted_at IS NULL AND ($4 = 0 OR version = $4)
+				  RETURNING id, name, email, password, role, version`
+
+	// Список колонок подставляется из UserPatch, значения передаются параметрами
+	PATCH_USER = `
+				  UPDATE users 
+				  SET %s, version = version + 1
+				  WHERE id = $%d AND deleted_at IS NULL AND version = $%d
+				  RETURNING id, name, email, password, role, version`
+
+	SELECT_USER_EXISTS = `
+				  SELECT EXISTS(
+				      SELECT 1
+				      FROM users
+				      WHERE id = $1 AND deleted_at IS NULL)`
+
+	SELECT_USER_ON_LOAN_FOR_UPDATE = `
+				  SELECT EXISTS(
+				      SELECT 1
+				      FROM user_books
+				      WHERE user_id = users.id AND return_date IS NULL)
+				  FROM users
+				  WHERE id = $1 AND deleted_at IS NULL
+				  FOR UPDATE`
 
 	DELETE_USER = `
+				  UPDATE users 
+				  SET deleted_at = NOW() 
+				  WHERE id=$1`
+
+	SELECT_USERS_TO_PURGE = `
+				  SELECT id
+				  FROM users
+				  WHERE deleted_at < $1
+				  FOR UPDATE`
+
+	// Одобренные отзывы удаляемых пользователей вычитаются из рейтинга книг до каскадного удаления
+	UPDATE_RATINGS_OF_PURGED_USERS = `
+				  UPDATE books AS b
+				  SET rating_count = b.rating_count - r.cnt, rating_sum = b.rating_sum - r.total, version = b.version + 1
+				  FROM (SELECT book_id, COUNT(*) AS cnt, SUM(rating) AS total
+				        FROM reviews
+				        WHERE user_id = ANY($1) AND status = 'approved'
+				        GROUP BY book_id) AS r
+				  WHERE b.id = r.book_id
+				  RETURNING b.id`
+
+	PURGE_USERS = `
 				  DELETE 
 				  FROM users 
-				  WHERE id=$1`
+				  WHERE id = ANY($1)`
 )
 
+// UserPatch содержит только изменённые поля пользователя; Password - уже захешированный пароль
+type UserPatch struct {
+	Name     *string
+	Email    *string
+	Password *string
+}
+
+//go:generate mockgen -source=UserRepository.go -destination=mock/UserRepository.go -package=repository
 type UserRepository interface {
 	GetAll(ctx context.Context) ([]entity.User, error)
 	GetByID(ctx context.Context, id int) (*entity.User, error)
 	Create(ctx context.Context, user *entity.User) error
 	Update(ctx context.Context, user *entity.User) (*entity.User, error)
+	Patch(ctx context.Context, id int, version int, patch UserPatch) (*entity.User, error)
 	Delete(ctx context.Context, id int) error
-	TakeBook(ctx context.Context, userId int, bookId int) error
-	ReturnBook(ctx context.Context, userId int, bookId int) error
 	GetByEmail(ctx context.Context, email string) (entity.User, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 }
 
 type UserRepositoryImpl struct {
-	Conn        *pgx.Conn
-	RedisClient *redis.Client
+	Conn   *pgxpool.Pool
+	Caches *Caches
 }
 
-func NewUserRepository(conn *pgx.Conn, redisClient *redis.Client) UserRepository {
-	return &UserRepositoryImpl{Conn: conn, RedisClient: redisClient}
+func NewUserRepository(conn *pgxpool.Pool, caches *Caches) UserRepository {
+	return &UserRepositoryImpl{Conn: conn, Caches: caches}
 }
 
 func (userRepository *UserRepositoryImpl) GetAll(ctx context.Context) ([]entity.User, error) {
 
 	rows, err := userRepository.Conn.Query(ctx, SELECT_ALL_USERS)
 	if err != nil {
-		return nil, err
+		return nil, dbError(err)
 	}
 	defer rows.Close()
 
 	var users []entity.User
+	userIndexes := make(map[int]int)
 	for rows.Next() {
 		var user entity.User
-		err = rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role)
+		err = rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.Version)
 		if err != nil {
-			return nil, err
+			return nil, dbError(err)
 		}
+		userIndexes[user.ID] = len(users)
 		users = append(users, user)
 	}
 
 	//TODO: Идея сделать через горутину
 	rows, err = userRepository.Conn.Query(ctx, SELECT_ALL_USERS_BOOKS)
 	if err != nil {
-		return nil, err
+		return nil, dbError(err)
 
 	}
 	defer rows.Close()
@@ -109,38 +169,40 @@ func (userRepository *UserRepositoryImpl) GetAll(ctx context.Context) ([]entity.
 
 		err := rows.Scan(&userID, &bookID, &bookTitle, &bookAuthor, &bookAvailable)
 		if err != nil {
-			return nil, err
+			return nil, dbError(err)
 		}
 
-		users[userID-1].Books = append(users[userID-1].Books, &entity.Book{ID: bookID, Title: bookTitle, Author: bookAuthor, Available: bookAvailable})
+		index, ok := userIndexes[userID]
+		if !ok {
+			continue
+		}
+		users[index].Books = append(users[index].Books, &entity.Book{ID: bookID, Title: bookTitle, Author: bookAuthor, Available: bookAvailable})
 	}
 	return users, nil
 
 }
 
 func (userRepository *UserRepositoryImpl) GetByID(ctx context.Context, id int) (*entity.User, error) {
-	// Проверка кеша
-	cacheKey := fmt.Sprintf("user:%d", id)
-	cachedUser, err := userRepository.RedisClient.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var user entity.User
-		err = json.Unmarshal([]byte(cachedUser), &user)
-		if err == nil {
-			return &user, nil
-		}
-	}
+	return userRepository.Caches.Users.Get(ctx, id, func(ctx context.Context) (*entity.User, error) {
+		return userRepository.getByID(ctx, id)
+	})
+}
 
+func (userRepository *UserRepositoryImpl) getByID(ctx context.Context, id int) (*entity.User, error) {
 	user := &entity.User{}
 
-	err = userRepository.Conn.QueryRow(ctx, SELECT_USER_BY_ID, id).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role)
+	err := userRepository.Conn.QueryRow(ctx, SELECT_USER_BY_ID, id).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
 	if err != nil {
-		return nil, err
+		return nil, dbError(err)
 	}
 
 	//TODO: Идея сделать через горутину
 	rows, err := userRepository.Conn.Query(ctx, SELECT_ALL_USER_BOOKS_BY_ID, id)
 	if err != nil {
-		return nil, err
+		return nil, dbError(err)
 	}
 	defer rows.Close()
 
@@ -148,19 +210,13 @@ func (userRepository *UserRepositoryImpl) GetByID(ctx context.Context, id int) (
 		book := &entity.Book{}
 		err = rows.Scan(&book.ID, &book.Title, &book.Author, &book.Available)
 		if err != nil {
-			return nil, err
+			return nil, dbError(err)
 		}
 		user.Books = append(user.Books, book)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	//Сохранение кеша
-	userData, err := json.Marshal(user)
-	if err == nil {
-		userRepository.RedisClient.Set(ctx, cacheKey, userData, 0)
+		return nil, dbError(err)
 	}
 	return user, nil
 }
@@ -168,121 +224,173 @@ func (userRepository *UserRepositoryImpl) GetByID(ctx context.Context, id int) (
 func (userRepository *UserRepositoryImpl) GetByEmail(ctx context.Context, email string) (entity.User, error) {
 	user := entity.User{}
 
-	err := userRepository.Conn.QueryRow(ctx, SELECT_USER_BY_EMAIL, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role)
+	err := userRepository.Conn.QueryRow(ctx, SELECT_USER_BY_EMAIL, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.Version)
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		return user, err
+		return user, dbError(err)
 	}
 	return user, nil
 }
 
+// Create сохраняет пользователя и событие UserRegistered - и при регистрации, и при создании администратором
 func (userRepository *UserRepositoryImpl) Create(ctx context.Context, user *entity.User) error {
-	err := userRepository.Conn.QueryRow(ctx, INSERT_USER, user.Name, user.Email, user.Password, user.Role).Scan(&user.ID)
-	return err
+	err := withTx(ctx, userRepository.Conn, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, INSERT_USER, user.Name, user.Email, user.Password, user.Role).Scan(&user.ID)
+		if err != nil {
+			return dbError(err)
+		}
+		return insertEvent(ctx, tx, entity.EventUserRegistered, user.ID,
+			entity.RegisteredUser{ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role})
+	})
+	if err != nil {
+		return err
+	}
+	// Запрос к ещё не созданному id мог запомнить, что пользователя нет
+	userRepository.Caches.Invalidate(ctx, Changes{Users: []int{user.ID}})
+	return nil
 }
 
+// Update изменяет пользователя, только если его версия совпадает с user.Version, иначе возвращает domain.ErrVersionMismatch
 func (userRepository *UserRepositoryImpl) Update(ctx context.Context, user *entity.User) (*entity.User, error) {
-	_, err := userRepository.Conn.Exec(ctx, UPDATE_USER, user.Name, user.Email, user.ID)
-	if err != nil {
-		return nil, err
+	updatedUser := &entity.User{}
+	err := userRepository.Conn.QueryRow(ctx, UPDATE_USER, user.Name, user.Email, user.ID, user.Version).
+		Scan(&updatedUser.ID, &updatedUser.Name, &updatedUser.Email, &updatedUser.Password, &updatedUser.Role, &updatedUser.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, userRepository.missingOrStale(ctx, user.ID)
 	}
-	err = userRepository.Conn.QueryRow(ctx, SELECT_USER_BY_ID, user.ID).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role)
 	if err != nil {
-		return nil, err
+		return nil, dbError(err)
 	}
 	// Удаляем данные из кеша
-	cacheKey := fmt.Sprintf("user:%d", user.ID)
-	err = userRepository.RedisClient.Del(ctx, cacheKey).Err()
+	userRepository.Caches.Invalidate(ctx, Changes{Users: []int{user.ID}})
+	return updatedUser, nil
+}
+
+// Patch записывает только заданные в patch колонки, если версия пользователя совпадает с version
+func (userRepository *UserRepositoryImpl) Patch(ctx context.Context, id int, version int, patch UserPatch) (*entity.User, error) {
+	var columns []string
+	var args []any
+	set := func(column string, value *string) {
+		if value != nil {
+			args = append(args, *value)
+			columns = append(columns, fmt.Sprintf("%s = $%d", column, len(args)))
+		}
+	}
+	set("name", patch.Name)
+	set("email", patch.Email)
+	set("password", patch.Password)
+	if len(columns) == 0 {
+		return userRepository.GetByID(ctx, id)
+	}
+	args = append(args, id, version)
+
+	user := &entity.User{}
+	err := userRepository.Conn.QueryRow(ctx, fmt.Sprintf(PATCH_USER, strings.Join(columns, ", "), len(args)-1, len(args)), args...).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, userRepository.missingOrStale(ctx, id)
+	}
 	if err != nil {
-		return nil, err
+		return nil, dbError(err)
 	}
+	// Удаляем данные из кеша
+	userRepository.Caches.Invalidate(ctx, Changes{Users: []int{id}})
 	return user, nil
 }
 
-func (userRepository *UserRepositoryImpl) Delete(ctx context.Context, id int) error {
-	_, err := userRepository.Conn.Exec(ctx, DELETE_USER, id)
-	// Удаление книги с кеша
-	bookCacheKey := fmt.Sprintf("book:%d", id)
-	err = userRepository.RedisClient.Del(ctx, bookCacheKey).Err()
-	if err != nil {
-		return err
+// missingOrStale объясняет, почему условное обновление не затронуло строк: пользователь удалён или его версия уже изменилась
+func (userRepository *UserRepositoryImpl) missingOrStale(ctx context.Context, id int) error {
+	var exists bool
+	if err := userRepository.Conn.QueryRow(ctx, SELECT_USER_EXISTS, id).Scan(&exists); err != nil {
+		return dbError(err)
+	}
+	if exists {
+		return domain.ErrVersionMismatch
 	}
-	return err
+	return domain.ErrUserNotFound
 }
 
-func (userRepository *UserRepositoryImpl) TakeBook(ctx context.Context, userId int, bookId int) error {
-
+// Delete помечает пользователя удалённым; пользователя с невозвращёнными книгами удалить нельзя
+func (userRepository *UserRepositoryImpl) Delete(ctx context.Context, id int) error {
 	tx, err := userRepository.Conn.Begin(ctx)
 	if err != nil {
-		return err
+		return dbError(err)
 	}
 	defer func() {
 		if err != nil {
 			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-				slog.Error(fmt.Sprintf("tx.Rollback failed: %v", rollbackErr))
+				slog.ErrorContext(ctx, fmt.Sprintf("tx.Rollback failed: %v", rollbackErr))
 			}
 		}
 	}()
 
-	_, err = tx.Exec(ctx, "INSERT INTO user_books (user_id, book_id) VALUES ($1, $2)", userId, bookId)
-	if err != nil {
-		return err
+	var onLoan bool
+	if err = tx.QueryRow(ctx, SELECT_USER_ON_LOAN_FOR_UPDATE, id).Scan(&onLoan); errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrUserNotFound
 	}
-
-	_, err = tx.Exec(ctx, "UPDATE books SET available = FALSE WHERE id = $1", bookId)
 	if err != nil {
+		return dbError(err)
+	}
+	if onLoan {
+		err = domain.ErrActiveLoans
 		return err
 	}
 
+	if _, err = tx.Exec(ctx, DELETE_USER, id); err != nil {
+		return dbError(err)
+	}
 	if err = tx.Commit(ctx); err != nil {
-		return err
+		return dbError(err)
 	}
 	// Удаляем данные из кеша
-	cacheKey := fmt.Sprintf("user:%d", userId)
-	err = userRepository.RedisClient.Del(ctx, cacheKey).Err()
-	if err != nil {
-		return err
-	}
-
+	userRepository.Caches.Invalidate(ctx, Changes{Users: []int{id}})
 	return nil
-
 }
 
-func (userRepository *UserRepositoryImpl) ReturnBook(ctx context.Context, userId int, bookId int) error {
+// Purge окончательно удаляет пользователей, помеченных удалёнными раньше deletedBefore, вместе с их займами и отзывами
+func (userRepository *UserRepositoryImpl) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
 	tx, err := userRepository.Conn.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, dbError(err)
 	}
 	defer func() {
 		if err != nil {
 			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-				slog.Error(fmt.Sprintf("tx.Rollback failed: %v", rollbackErr))
+				slog.ErrorContext(ctx, fmt.Sprintf("tx.Rollback failed: %v", rollbackErr))
 			}
 		}
 	}()
 
-	_, err = tx.Exec(ctx, "DELETE FROM user_books WHERE user_id = $1 AND book_id = $2", userId, bookId)
+	rows, err := tx.Query(ctx, SELECT_USERS_TO_PURGE, deletedBefore)
 	if err != nil {
-		return err
+		return 0, dbError(err)
 	}
-
-	_, err = tx.Exec(ctx, "UPDATE books SET available = TRUE WHERE id = $1", bookId)
+	userIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
 	if err != nil {
-		return err
+		return 0, dbError(err)
+	}
+	if len(userIds) == 0 {
+		err = tx.Commit(ctx)
+		return 0, dbError(err)
 	}
 
-	//_, err = tx.Exec(ctx, "UPDATE user_books SET return_date = NOW() WHERE user_id = $1 AND book_id = $2", userId, bookId)
-	//if err != nil {
-	//	return err
-	//}
-
-	if err = tx.Commit(ctx); err != nil {
-		return err
+	rows, err = tx.Query(ctx, UPDATE_RATINGS_OF_PURGED_USERS, userIds)
+	if err != nil {
+		return 0, dbError(err)
 	}
-	// Удаляем данные из кеша
-	cacheKey := fmt.Sprintf("user:%d", userId)
-	err = userRepository.RedisClient.Del(ctx, cacheKey).Err()
+	bookIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
 	if err != nil {
-		return err
+		return 0, dbError(err)
 	}
-	return nil
+
+	tag, err := tx.Exec(ctx, PURGE_USERS, userIds)
+	if err != nil {
+		return 0, dbError(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, dbError(err)
+	}
+
+	// Удаление пользователей и книг с изменившимся рейтингом с кеша
+	userRepository.Caches.Invalidate(ctx, Changes{Users: userIds, Books: bookIds})
+	return tag.RowsAffected(), nil
 }
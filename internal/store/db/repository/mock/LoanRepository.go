@@ -0,0 +1,112 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: LoanRepository.go
+
+// Package repository is a generated GoMock package.
+package repository
+
+import (
+	context "context"
+	reflect "reflect"
+
+	entity "github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	repository "github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockLoanRepository is a mock of LoanRepository interface.
+type MockLoanRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoanRepositoryMockRecorder
+}
+
+// MockLoanRepositoryMockRecorder is the mock recorder for MockLoanRepository.
+type MockLoanRepositoryMockRecorder struct {
+	mock *MockLoanRepository
+}
+
+// NewMockLoanRepository creates a new mock instance.
+func NewMockLoanRepository(ctrl *gomock.Controller) *MockLoanRepository {
+	mock := &MockLoanRepository{ctrl: ctrl}
+	mock.recorder = &MockLoanRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoanRepository) EXPECT() *MockLoanRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CountOpen mocks base method.
+func (m *MockLoanRepository) CountOpen(ctx context.Context) (repository.LoanCounts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountOpen", ctx)
+	ret0, _ := ret[0].(repository.LoanCounts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountOpen indicates an expected call of CountOpen.
+func (mr *MockLoanRepositoryMockRecorder) CountOpen(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOpen", reflect.TypeOf((*MockLoanRepository)(nil).CountOpen), ctx)
+}
+
+// Create mocks base method.
+func (m *MockLoanRepository) Create(ctx context.Context, userId, bookId int, terms repository.LoanTerms) (*entity.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, userId, bookId, terms)
+	ret0, _ := ret[0].(*entity.Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockLoanRepositoryMockRecorder) Create(ctx, userId, bookId, terms interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockLoanRepository)(nil).Create), ctx, userId, bookId, terms)
+}
+
+// GetActive mocks base method.
+func (m *MockLoanRepository) GetActive(ctx context.Context, userId, bookId int) (*entity.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActive", ctx, userId, bookId)
+	ret0, _ := ret[0].(*entity.Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActive indicates an expected call of GetActive.
+func (mr *MockLoanRepositoryMockRecorder) GetActive(ctx, userId, bookId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActive", reflect.TypeOf((*MockLoanRepository)(nil).GetActive), ctx, userId, bookId)
+}
+
+// GetByID mocks base method.
+func (m *MockLoanRepository) GetByID(ctx context.Context, id int) (*entity.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByID", ctx, id)
+	ret0, _ := ret[0].(*entity.Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByID indicates an expected call of GetByID.
+func (mr *MockLoanRepositoryMockRecorder) GetByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockLoanRepository)(nil).GetByID), ctx, id)
+}
+
+// Return mocks base method.
+func (m *MockLoanRepository) Return(ctx context.Context, id int) (*entity.Loan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Return", ctx, id)
+	ret0, _ := ret[0].(*entity.Loan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Return indicates an expected call of Return.
+func (mr *MockLoanRepositoryMockRecorder) Return(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Return", reflect.TypeOf((*MockLoanRepository)(nil).Return), ctx, id)
+}
@@ -0,0 +1,112 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: RecommendationRepository.go
+
+// Package repository is a generated GoMock package.
+package repository
+
+import (
+	context "context"
+	reflect "reflect"
+
+	entity "github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	repository "github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockRecommendationRepository is a mock of RecommendationRepository interface.
+type MockRecommendationRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRecommendationRepositoryMockRecorder
+}
+
+// MockRecommendationRepositoryMockRecorder is the mock recorder for MockRecommendationRepository.
+type MockRecommendationRepositoryMockRecorder struct {
+	mock *MockRecommendationRepository
+}
+
+// NewMockRecommendationRepository creates a new mock instance.
+func NewMockRecommendationRepository(ctrl *gomock.Controller) *MockRecommendationRepository {
+	mock := &MockRecommendationRepository{ctrl: ctrl}
+	mock.recorder = &MockRecommendationRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRecommendationRepository) EXPECT() *MockRecommendationRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetBooksLike mocks base method.
+func (m *MockRecommendationRepository) GetBooksLike(ctx context.Context, bookId, limit int) ([]entity.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBooksLike", ctx, bookId, limit)
+	ret0, _ := ret[0].([]entity.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBooksLike indicates an expected call of GetBooksLike.
+func (mr *MockRecommendationRepositoryMockRecorder) GetBooksLike(ctx, bookId, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBooksLike", reflect.TypeOf((*MockRecommendationRepository)(nil).GetBooksLike), ctx, bookId, limit)
+}
+
+// GetBorrowedBookIDs mocks base method.
+func (m *MockRecommendationRepository) GetBorrowedBookIDs(ctx context.Context, userId int) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBorrowedBookIDs", ctx, userId)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBorrowedBookIDs indicates an expected call of GetBorrowedBookIDs.
+func (mr *MockRecommendationRepositoryMockRecorder) GetBorrowedBookIDs(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBorrowedBookIDs", reflect.TypeOf((*MockRecommendationRepository)(nil).GetBorrowedBookIDs), ctx, userId)
+}
+
+// GetByIDs mocks base method.
+func (m *MockRecommendationRepository) GetByIDs(ctx context.Context, ids []int) ([]entity.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByIDs", ctx, ids)
+	ret0, _ := ret[0].([]entity.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByIDs indicates an expected call of GetByIDs.
+func (mr *MockRecommendationRepositoryMockRecorder) GetByIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIDs", reflect.TypeOf((*MockRecommendationRepository)(nil).GetByIDs), ctx, ids)
+}
+
+// GetPopular mocks base method.
+func (m *MockRecommendationRepository) GetPopular(ctx context.Context, excludeIds []int, limit int) ([]entity.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPopular", ctx, excludeIds, limit)
+	ret0, _ := ret[0].([]entity.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPopular indicates an expected call of GetPopular.
+func (mr *MockRecommendationRepositoryMockRecorder) GetPopular(ctx, excludeIds, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPopular", reflect.TypeOf((*MockRecommendationRepository)(nil).GetPopular), ctx, excludeIds, limit)
+}
+
+// GetSimilarBooks mocks base method.
+func (m *MockRecommendationRepository) GetSimilarBooks(ctx context.Context, limit int) ([]repository.SimilarBook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSimilarBooks", ctx, limit)
+	ret0, _ := ret[0].([]repository.SimilarBook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSimilarBooks indicates an expected call of GetSimilarBooks.
+func (mr *MockRecommendationRepositoryMockRecorder) GetSimilarBooks(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSimilarBooks", reflect.TypeOf((*MockRecommendationRepository)(nil).GetSimilarBooks), ctx, limit)
+}
@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: SeedRepository.go
+
+// Package repository is a generated GoMock package.
+package repository
+
+import (
+	context "context"
+	reflect "reflect"
+
+	repository "github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockSeedRepository is a mock of SeedRepository interface.
+type MockSeedRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockSeedRepositoryMockRecorder
+}
+
+// MockSeedRepositoryMockRecorder is the mock recorder for MockSeedRepository.
+type MockSeedRepositoryMockRecorder struct {
+	mock *MockSeedRepository
+}
+
+// NewMockSeedRepository creates a new mock instance.
+func NewMockSeedRepository(ctrl *gomock.Controller) *MockSeedRepository {
+	mock := &MockSeedRepository{ctrl: ctrl}
+	mock.recorder = &MockSeedRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSeedRepository) EXPECT() *MockSeedRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Apply mocks base method.
+func (m *MockSeedRepository) Apply(ctx context.Context, dataset repository.SeedDataset) (repository.SeedResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", ctx, dataset)
+	ret0, _ := ret[0].(repository.SeedResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Apply indicates an expected call of Apply.
+func (mr *MockSeedRepositoryMockRecorder) Apply(ctx, dataset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockSeedRepository)(nil).Apply), ctx, dataset)
+}
@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: StatsRepository.go
+
+// Package repository is a generated GoMock package.
+package repository
+
+import (
+	context "context"
+	reflect "reflect"
+
+	entity "github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	repository "github.com/Ablyamitov/simple-rest/internal/store/db/repository"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockStatsRepository is a mock of StatsRepository interface.
+type MockStatsRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockStatsRepositoryMockRecorder
+}
+
+// MockStatsRepositoryMockRecorder is the mock recorder for MockStatsRepository.
+type MockStatsRepositoryMockRecorder struct {
+	mock *MockStatsRepository
+}
+
+// NewMockStatsRepository creates a new mock instance.
+func NewMockStatsRepository(ctrl *gomock.Controller) *MockStatsRepository {
+	mock := &MockStatsRepository{ctrl: ctrl}
+	mock.recorder = &MockStatsRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStatsRepository) EXPECT() *MockStatsRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetActiveReaders mocks base method.
+func (m *MockStatsRepository) GetActiveReaders(ctx context.Context, filter repository.StatsFilter) ([]entity.WeeklyReaders, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActiveReaders", ctx, filter)
+	ret0, _ := ret[0].([]entity.WeeklyReaders)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActiveReaders indicates an expected call of GetActiveReaders.
+func (mr *MockStatsRepositoryMockRecorder) GetActiveReaders(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveReaders", reflect.TypeOf((*MockStatsRepository)(nil).GetActiveReaders), ctx, filter)
+}
+
+// GetLoanLength mocks base method.
+func (m *MockStatsRepository) GetLoanLength(ctx context.Context, filter repository.StatsFilter) ([]entity.LoanLength, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoanLength", ctx, filter)
+	ret0, _ := ret[0].([]entity.LoanLength)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoanLength indicates an expected call of GetLoanLength.
+func (mr *MockStatsRepositoryMockRecorder) GetLoanLength(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoanLength", reflect.TypeOf((*MockStatsRepository)(nil).GetLoanLength), ctx, filter)
+}
+
+// GetMostBorrowed mocks base method.
+func (m *MockStatsRepository) GetMostBorrowed(ctx context.Context, filter repository.StatsFilter, limit int) ([]entity.BookLoans, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMostBorrowed", ctx, filter, limit)
+	ret0, _ := ret[0].([]entity.BookLoans)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMostBorrowed indicates an expected call of GetMostBorrowed.
+func (mr *MockStatsRepositoryMockRecorder) GetMostBorrowed(ctx, filter, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMostBorrowed", reflect.TypeOf((*MockStatsRepository)(nil).GetMostBorrowed), ctx, filter, limit)
+}
+
+// GetNeverBorrowed mocks base method.
+func (m *MockStatsRepository) GetNeverBorrowed(ctx context.Context, filter repository.StatsFilter) ([]entity.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNeverBorrowed", ctx, filter)
+	ret0, _ := ret[0].([]entity.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNeverBorrowed indicates an expected call of GetNeverBorrowed.
+func (mr *MockStatsRepositoryMockRecorder) GetNeverBorrowed(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNeverBorrowed", reflect.TypeOf((*MockStatsRepository)(nil).GetNeverBorrowed), ctx, filter)
+}
+
+// Refresh mocks base method.
+func (m *MockStatsRepository) Refresh(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockStatsRepositoryMockRecorder) Refresh(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockStatsRepository)(nil).Refresh), ctx)
+}
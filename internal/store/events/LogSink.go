@@ -0,0 +1,27 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+)
+
+// LogSink пишет события в лог. Годится для разработки и тестов, когда внешнего получателя нет
+type LogSink struct {
+	Logger *slog.Logger
+}
+
+func NewLogSink(logger *slog.Logger) Sink {
+	return &LogSink{Logger: logger}
+}
+
+func (logSink *LogSink) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	logSink.Logger.InfoContext(ctx, "Domain event",
+		slog.Int64("event_id", event.ID),
+		slog.String("event_type", event.Type),
+		slog.Int("aggregate_id", event.AggregateID),
+		slog.String("payload", string(event.Payload)),
+	)
+	return nil
+}
@@ -0,0 +1,37 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStreamSink добавляет события в поток Redis, обрезая его примерно до MaxLen записей (0 - не обрезать)
+type RedisStreamSink struct {
+	RedisClient *redis.Client
+	Stream      string
+	MaxLen      int64
+}
+
+func NewRedisStreamSink(redisClient *redis.Client, stream string, maxLen int64) Sink {
+	return &RedisStreamSink{RedisClient: redisClient, Stream: stream, MaxLen: maxLen}
+}
+
+func (redisStreamSink *RedisStreamSink) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	return redisStreamSink.RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: redisStreamSink.Stream,
+		MaxLen: redisStreamSink.MaxLen,
+		Approx: true,
+		Values: []any{
+			"id", strconv.FormatInt(event.ID, 10),
+			"type", event.Type,
+			"aggregate_id", strconv.Itoa(event.AggregateID),
+			"payload", string(event.Payload),
+			"created_at", event.CreatedAt.Format(time.RFC3339Nano),
+		},
+	}).Err()
+}
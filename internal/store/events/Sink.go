@@ -0,0 +1,14 @@
+// Package events доставляет доменные события из outbox во внешние системы
+package events
+
+import (
+	"context"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+)
+
+// Sink публикует одно событие. Ошибка означает, что событие не доставлено и будет отправлено снова;
+// событие может прийти и дважды, поэтому получатели отбрасывают повторы по его id
+type Sink interface {
+	Publish(ctx context.Context, event entity.OutboxEvent) error
+}
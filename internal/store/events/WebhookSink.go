@@ -0,0 +1,66 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/app/tracing"
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+)
+
+// WebhookSink отправляет каждое событие POST-запросом с JSON события в теле. Доставленным считается ответ 2xx.
+// Если задан Secret, тело подписывается HMAC-SHA256 в заголовке X-Signature: sha256=<hex>
+type WebhookSink struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhookSink(url string, secret string, timeout time.Duration) Sink {
+	return &WebhookSink{
+		URL:    url,
+		Secret: secret,
+		Client: &http.Client{Timeout: timeout, Transport: tracing.NewTransport(nil)},
+	}
+}
+
+func (webhookSink *WebhookSink) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookSink.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(event.ID, 10))
+	req.Header.Set("X-Event-Type", event.Type)
+	if webhookSink.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(webhookSink.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := webhookSink.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Тело дочитывается, чтобы соединение вернулось в пул
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s to event %d", resp.Status, event.ID)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package events
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSink_Publish(t *testing.T) {
+	status := http.StatusAccepted
+	var received entity.OutboxEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		mac := hmac.New(sha256.New, []byte("webhook-secret"))
+		mac.Write(body)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get("X-Signature"))
+		assert.Equal(t, "42", r.Header.Get("X-Event-ID"))
+		assert.Equal(t, entity.EventLoanStarted, r.Header.Get("X-Event-Type"))
+		require.NoError(t, json.Unmarshal(body, &received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, "webhook-secret", time.Second)
+	event := entity.OutboxEvent{ID: 42, Type: entity.EventLoanStarted, AggregateID: 7,
+		Payload: json.RawMessage(`{"id":7,"book_id":3}`), CreatedAt: time.Now().UTC()}
+
+	require.NoError(t, sink.Publish(context.Background(), event))
+	assert.Equal(t, event.ID, received.ID)
+	assert.JSONEq(t, `{"id":7,"book_id":3}`, string(received.Payload))
+
+	// Событие, которое получатель не принял, не считается доставленным
+	status = http.StatusServiceUnavailable
+	assert.ErrorContains(t, sink.Publish(context.Background(), event), "503")
+}
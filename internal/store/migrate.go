@@ -1,33 +1,221 @@
 package store
 
 import (
-	"log"
-	"os/exec"
-	"path/filepath"
-	"runtime"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+
+	"github.com/Ablyamitov/simple-rest/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
-func ApplyMigrations(relativePath string, dbURL string) {
+// SELECT_MIGRATION_VERSION читает служебную таблицу golang-migrate
+const SELECT_MIGRATION_VERSION = `SELECT version, dirty FROM schema_migrations LIMIT 1`
+
+var ErrSchemaNewer = errors.New("database schema is newer than this binary")
+
+// Migration - одна встроенная миграция
+type Migration struct {
+	Version uint64
+	Name    string
+	Applied bool
+}
+
+type MigrationStatus struct {
+	Version    uint64
+	Dirty      bool
+	Latest     uint64
+	Migrations []Migration
+}
 
-	absPath, err := filepath.Abs(relativePath)
+// Migrator применяет миграции, встроенные в бинарник. На время изменений golang-migrate держит
+// pg_advisory_lock, поэтому реплики, стартующие одновременно, применяют миграции по очереди
+type Migrator struct {
+	migrate *migrate.Migrate
+}
+
+func NewMigrator(dbURL string) (*Migrator, error) {
+	db, err := sql.Open("pgx", dbURL)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		driver.Close()
+		return nil, err
+	}
+	m, err := migrate.NewWithInstance("iofs", source, "pgx5", driver)
 	if err != nil {
-		log.Fatalf("Failed to get absolute path: %v", err)
+		driver.Close()
+		return nil, err
 	}
+	m.Log = migrateLogger{}
+	return &Migrator{migrate: m}, nil
+}
+
+func (migrator *Migrator) Close() error {
+	sourceErr, dbErr := migrator.migrate.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+// Up применяет все ещё не применённые миграции
+func (migrator *Migrator) Up() error {
+	if err := migrator.CheckSchema(); err != nil {
+		return err
+	}
+	return ignoreNoChange(migrator.migrate.Up())
+}
+
+// Down откатывает steps последних миграций
+func (migrator *Migrator) Down(steps int) error {
+	return ignoreNoChange(migrator.migrate.Steps(-steps))
+}
 
-	if runtime.GOOS == "windows" {
-		absPath = strings.ReplaceAll(absPath, "\\", "/")
+// To переводит схему на версию version вверх или вниз; 0 откатывает все миграции
+func (migrator *Migrator) To(version uint64) error {
+	if version == 0 {
+		return ignoreNoChange(migrator.migrate.Down())
 	}
-	log.Printf("Using migration path: %s", absPath)
-	_, err = exec.LookPath("migrate")
+	return ignoreNoChange(migrator.migrate.Migrate(uint(version)))
+}
+
+// Force записывает версию без выполнения миграций и снимает признак dirty после ручного исправления
+func (migrator *Migrator) Force(version int) error {
+	return migrator.migrate.Force(version)
+}
+
+func (migrator *Migrator) Status() (MigrationStatus, error) {
+	var status MigrationStatus
+	version, dirty, err := migrator.migrate.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return status, err
+	}
+	status.Version, status.Dirty = uint64(version), dirty
+
+	status.Migrations, err = EmbeddedMigrations()
 	if err != nil {
-		log.Fatalf("migrate binary not found in PATH: %v", err)
+		return status, err
+	}
+	for i := range status.Migrations {
+		status.Migrations[i].Applied = status.Migrations[i].Version <= status.Version
+		status.Latest = status.Migrations[i].Version
 	}
+	return status, nil
+}
+
+// Print выводит версию схемы и таблицу встроенных миграций
+func (status MigrationStatus) Print(w io.Writer) error {
+	fmt.Fprintf(w, "Schema version: %d, latest migration: %d", status.Version, status.Latest)
+	if status.Dirty {
+		fmt.Fprint(w, " (dirty: fix the schema and run migrate force N)")
+	}
+	fmt.Fprintln(w)
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "VERSION\tNAME\tAPPLIED")
+	for _, migration := range status.Migrations {
+		applied := "no"
+		if migration.Applied {
+			applied = "yes"
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\n", migration.Version, migration.Name, applied)
+	}
+	return table.Flush()
+}
+
+// CheckSchema не даёт работать со схемой, которую создала более новая версия приложения
+func (migrator *Migrator) CheckSchema() error {
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	if status.Version > status.Latest {
+		return fmt.Errorf("%w: schema version %d, latest known migration %d", ErrSchemaNewer,
+			status.Version, status.Latest)
+	}
+	return nil
+}
+
+// ApplyMigrations применяет миграции при старте приложения
+func ApplyMigrations(dbURL string) error {
+	migrator, err := NewMigrator(dbURL)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+	return migrator.Up()
+}
 
-	cmd := exec.Command("migrate", "-path", absPath, "-database", dbURL, "up")
-	output, err := cmd.CombinedOutput()
+// EmbeddedMigrations возвращает встроенные миграции по возрастанию версии
+func EmbeddedMigrations() ([]Migration, error) {
+	files, err := fs.Glob(migrations.FS, "*.up.sql")
 	if err != nil {
-		log.Fatalf("Failed to apply migrations: %v\nOutput: %s", err, output)
+		return nil, err
 	}
+	list := make([]Migration, 0, len(files))
+	for _, file := range files {
+		number, name, found := strings.Cut(strings.TrimSuffix(file, ".up.sql"), "_")
+		if !found {
+			continue
+		}
+		version, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", file, err)
+		}
+		list = append(list, Migration{Version: version, Name: name})
+	}
+	return list, nil
+}
+
+// LatestMigrationVersion возвращает номер последней встроенной миграции
+func LatestMigrationVersion() (uint64, error) {
+	list, err := EmbeddedMigrations()
+	if err != nil || len(list) == 0 {
+		return 0, err
+	}
+	return list[len(list)-1].Version, nil
+}
+
+// MigrationVersion возвращает применённую версию схемы и признак незавершённой миграции
+func MigrationVersion(ctx context.Context, conn *pgxpool.Pool) (uint64, bool, error) {
+	var version int64
+	var dirty bool
+	if err := conn.QueryRow(ctx, SELECT_MIGRATION_VERSION).Scan(&version, &dirty); err != nil {
+		return 0, false, err
+	}
+	return uint64(version), dirty, nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
+
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...any) {
+	slog.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
 
+func (migrateLogger) Verbose() bool {
+	return false
 }
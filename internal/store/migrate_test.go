@@ -0,0 +1,29 @@
+package store
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/Ablyamitov/simple-rest/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Встроенные миграции должны идти без пропусков и откатываться: migrate down и to N на них полагаются
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := EmbeddedMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+
+	for i, migration := range list {
+		assert.Equal(t, uint64(i+1), migration.Version)
+		_, err := fs.Stat(migrations.FS, fmt.Sprintf("%06d_%s.down.sql", migration.Version, migration.Name))
+		assert.NoError(t, err, "migration %d has no down file", migration.Version)
+	}
+
+	latest, err := LatestMigrationVersion()
+	require.NoError(t, err)
+	assert.Equal(t, list[len(list)-1].Version, latest)
+}
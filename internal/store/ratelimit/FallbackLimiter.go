@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// FallbackLimiter считает запросы в Primary (Redis), а пока он недоступен - в Fallback (память процесса).
+// На время отказа каждый экземпляр сервиса пропускает клиента по своему счёту, то есть лимит умножается
+// на число экземпляров. После ошибки Primary не опрашивается RetryAfter
+type FallbackLimiter struct {
+	Primary    Limiter
+	Fallback   Limiter
+	RetryAfter time.Duration
+	downUntil  atomic.Int64
+	now        func() time.Time
+}
+
+func NewFallbackLimiter(primary Limiter, fallback Limiter, retryAfter time.Duration) *FallbackLimiter {
+	return &FallbackLimiter{Primary: primary, Fallback: fallback, RetryAfter: retryAfter, now: time.Now}
+}
+
+func (fallbackLimiter *FallbackLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if fallbackLimiter.now().UnixNano() >= fallbackLimiter.downUntil.Load() {
+		result, err := fallbackLimiter.Primary.Allow(ctx, key, limit)
+		if err == nil || ctx.Err() != nil {
+			return result, err
+		}
+		downUntil := fallbackLimiter.now().Add(fallbackLimiter.RetryAfter).UnixNano()
+		if previous := fallbackLimiter.downUntil.Swap(downUntil); previous < fallbackLimiter.now().UnixNano() {
+			slog.WarnContext(ctx, "Rate limiter is unavailable, limiting in memory",
+				slog.String("error", err.Error()), slog.Duration("retry_after", fallbackLimiter.RetryAfter))
+		}
+	}
+	return fallbackLimiter.Fallback.Allow(ctx, key, limit)
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type brokenLimiter struct {
+	calls int
+}
+
+func (broken *brokenLimiter) Allow(context.Context, string, Limit) (Result, error) {
+	broken.calls++
+	return Result{}, errors.New("connection refused")
+}
+
+func TestFallbackLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	primary := &brokenLimiter{}
+	fallback := NewMemoryLimiter()
+	fallback.now = func() time.Time { return now }
+	fallbackLimiter := NewFallbackLimiter(primary, fallback, 5*time.Second)
+	fallbackLimiter.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Period: time.Minute, Burst: 1}
+
+	// Пока Redis недоступен, лимит по-прежнему действует
+	result, err := fallbackLimiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	result, err = fallbackLimiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 1, primary.calls)
+
+	now = now.Add(5 * time.Second)
+	_, err = fallbackLimiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.Equal(t, 2, primary.calls)
+}
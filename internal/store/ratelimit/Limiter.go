@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit - ограничение по алгоритму GCRA: в среднем Rate запросов за Period и не больше Burst подряд.
+// Rate <= 0 снимает ограничение
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+func (limit Limit) Disabled() bool {
+	return limit.Rate <= 0
+}
+
+// interval - через сколько восстанавливается один запрос
+func (limit Limit) interval() time.Duration {
+	return limit.Period / time.Duration(limit.Rate)
+}
+
+// tolerance - на сколько теоретическое время прихода может опережать текущее
+func (limit Limit) tolerance() time.Duration {
+	return limit.interval() * time.Duration(limit.Burst)
+}
+
+// Result - ответ лимитера для заголовков RateLimit-*
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter - через сколько будет пропущен следующий запрос, если этот отклонён
+	RetryAfter time.Duration
+	// ResetAfter - через сколько лимит восстановится полностью
+	ResetAfter time.Duration
+}
+
+// result переводит опережение теоретического времени прихода над текущим в ответ лимитера
+func (limit Limit) result(allowed bool, ahead time.Duration) Result {
+	result := Result{Allowed: allowed, Limit: limit.Burst, ResetAfter: ahead}
+	if allowed {
+		result.Remaining = int((limit.tolerance() - ahead) / limit.interval())
+	} else {
+		result.RetryAfter = ahead + limit.interval() - limit.tolerance()
+	}
+	return result
+}
+
+// Limiter считает запросы по ключу клиента
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Ключи проверяются на истечение, когда их число удваивается с прошлой проверки
+const minSweep = 1024
+
+// MemoryLimiter считает запросы в памяти процесса, поэтому у каждого экземпляра сервиса свой счёт
+type MemoryLimiter struct {
+	mu sync.Mutex
+	// tats - теоретическое время прихода следующего запроса по ключу
+	tats    map[string]time.Time
+	sweepAt int
+	now     func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{tats: make(map[string]time.Time), sweepAt: minSweep, now: time.Now}
+}
+
+func (memoryLimiter *MemoryLimiter) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	memoryLimiter.mu.Lock()
+	defer memoryLimiter.mu.Unlock()
+
+	now := memoryLimiter.now()
+	memoryLimiter.sweep(now)
+	tat := memoryLimiter.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(limit.interval())
+	if next.Sub(now) > limit.tolerance() {
+		return limit.result(false, tat.Sub(now)), nil
+	}
+	memoryLimiter.tats[key] = next
+	return limit.result(true, next.Sub(now)), nil
+}
+
+// sweep удаляет ключи, лимит которых уже восстановился полностью
+func (memoryLimiter *MemoryLimiter) sweep(now time.Time) {
+	if len(memoryLimiter.tats) < memoryLimiter.sweepAt {
+		return
+	}
+	for key, tat := range memoryLimiter.tats {
+		if !tat.After(now) {
+			delete(memoryLimiter.tats, key)
+		}
+	}
+	memoryLimiter.sweepAt = max(2*len(memoryLimiter.tats), minSweep)
+}
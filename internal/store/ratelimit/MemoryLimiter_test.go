@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	memoryLimiter := NewMemoryLimiter()
+	memoryLimiter.now = func() time.Time { return now }
+	// Один запрос восстанавливается за 6 секунд, подряд можно три
+	limit := Limit{Rate: 10, Period: time.Minute, Burst: 3}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		result, err := memoryLimiter.Allow(ctx, "client", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+
+	result, err := memoryLimiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 6*time.Second, result.RetryAfter)
+	assert.Equal(t, 18*time.Second, result.ResetAfter)
+
+	other, err := memoryLimiter.Allow(ctx, "other", limit)
+	require.NoError(t, err)
+	assert.True(t, other.Allowed)
+
+	now = now.Add(6 * time.Second)
+	result, err = memoryLimiter.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	// Через полное восстановление ключи удаляются при очистке
+	now = now.Add(time.Minute)
+	memoryLimiter.sweepAt = 0
+	memoryLimiter.sweep(now)
+	assert.Empty(t, memoryLimiter.tats)
+}
@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript хранит теоретическое время прихода в микросекундах. Время берётся у Redis,
+// поэтому экземпляры сервиса с расходящимися часами считают одинаково.
+// Lua 5.1 выводит большие числа как %.14g с потерей точности, поэтому значение форматируется через %d
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local next = tat + interval
+if next - now > tolerance then
+	return {0, tat - now}
+end
+redis.call('SET', KEYS[1], string.format('%d', next), 'PX', math.ceil((next - now) / 1000))
+return {1, next - now}
+`)
+
+// RedisLimiter делит ограничения между всеми экземплярами сервиса
+type RedisLimiter struct {
+	RedisClient *redis.Client
+}
+
+func NewRedisLimiter(redisClient *redis.Client) Limiter {
+	return &RedisLimiter{RedisClient: redisClient}
+}
+
+func (redisLimiter *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	reply, err := gcraScript.Run(ctx, redisLimiter.RedisClient, []string{key},
+		limit.interval().Microseconds(), limit.tolerance().Microseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return limit.result(reply[0] == 1, time.Duration(reply[1])*time.Microsecond), nil
+}
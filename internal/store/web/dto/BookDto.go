@@ -1,8 +1,13 @@
 package dto
 
 type BookDTO struct {
-	ID        int    `json:"id"`
-	Title     string `json:"title" validate:"required,notblank"`
-	Author    string `json:"author" validate:"required,notblank"`
-	Available bool   `json:"available"`
+	ID            int     `json:"id"`
+	Title         string  `json:"title" validate:"required,notblank"`
+	Author        string  `json:"author" validate:"required,notblank"`
+	Category      string  `json:"category" validate:"max=100"`
+	Available     bool    `json:"available"`
+	CoverURL      string  `json:"cover_url,omitempty"`
+	RatingAverage float64 `json:"rating_average"`
+	RatingCount   int     `json:"rating_count"`
+	Version       int     `json:"version,omitempty"`
 }
@@ -0,0 +1,12 @@
+package dto
+
+import "time"
+
+type LoanDTO struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id" validate:"required,gt=0"`
+	BookID     int        `json:"book_id" validate:"required,gt=0"`
+	TakenDate  time.Time  `json:"taken_date"`
+	DueDate    time.Time  `json:"due_date"`
+	ReturnDate *time.Time `json:"return_date"`
+}
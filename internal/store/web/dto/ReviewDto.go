@@ -0,0 +1,14 @@
+package dto
+
+import "time"
+
+type ReviewDTO struct {
+	ID        int       `json:"id"`
+	BookID    int       `json:"book_id"`
+	UserID    int       `json:"user_id"`
+	Rating    int       `json:"rating" validate:"required,gte=1,lte=5"`
+	Text      string    `json:"text" validate:"max=5000"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+type BookLoansDTO struct {
+	Book  *BookDTO `json:"book"`
+	Loans int      `json:"loans"`
+}
+
+type LoanLengthDTO struct {
+	Category    string  `json:"category"`
+	Returned    int     `json:"returned"`
+	AverageDays float64 `json:"average_days"`
+}
+
+type WeeklyReadersDTO struct {
+	Week    string `json:"week"`
+	Readers int    `json:"readers"`
+}
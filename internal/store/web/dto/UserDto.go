@@ -7,4 +7,5 @@ type UserDTO struct {
 	Books    []*BookDTO `json:"books"`
 	Password string     `json:"password" validate:"required,notblank"`
 	Role     string     `json:"role"`
+	Version  int        `json:"version,omitempty"`
 }
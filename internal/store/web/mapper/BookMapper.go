@@ -1,17 +1,31 @@
 package mapper
 
 import (
+	"fmt"
+	"math"
+
 	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
 	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
 )
 
 func MapBookToDTO(book *entity.Book) *dto.BookDTO {
-	return &dto.BookDTO{
+	bookDTO := &dto.BookDTO{
 		ID:        book.ID,
 		Title:     book.Title,
 		Author:    book.Author,
+		Category:  book.Category,
 		Available: book.Available,
+		Version:   book.Version,
+	}
+	if book.RatingCount > 0 {
+		bookDTO.RatingAverage = math.Round(float64(book.RatingSum)/float64(book.RatingCount)*100) / 100
+		bookDTO.RatingCount = book.RatingCount
+	}
+	if book.CoverUpdatedAt != nil {
+		// Версия в URL позволяет клиентам долго кешировать обложку
+		bookDTO.CoverURL = fmt.Sprintf("/api/v1/books/%d/cover?v=%d", book.ID, book.CoverUpdatedAt.Unix())
 	}
+	return bookDTO
 }
 
 func MapDTOToBook(dto *dto.BookDTO) *entity.Book {
@@ -19,6 +33,8 @@ func MapDTOToBook(dto *dto.BookDTO) *entity.Book {
 		ID:        dto.ID,
 		Title:     dto.Title,
 		Author:    dto.Author,
+		Category:  dto.Category,
 		Available: dto.Available,
+		Version:   dto.Version,
 	}
 }
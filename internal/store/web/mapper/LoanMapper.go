@@ -0,0 +1,17 @@
+package mapper
+
+import (
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
+)
+
+func MapLoanToDTO(loan *entity.Loan) *dto.LoanDTO {
+	return &dto.LoanDTO{
+		ID:         loan.ID,
+		UserID:     loan.UserID,
+		BookID:     loan.BookID,
+		TakenDate:  loan.TakenDate,
+		DueDate:    loan.DueDate,
+		ReturnDate: loan.ReturnDate,
+	}
+}
@@ -0,0 +1,30 @@
+package mapper
+
+import (
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
+)
+
+func MapReviewToDTO(review *entity.Review) *dto.ReviewDTO {
+	return &dto.ReviewDTO{
+		ID:        review.ID,
+		BookID:    review.BookID,
+		UserID:    review.UserID,
+		Rating:    review.Rating,
+		Text:      review.Text,
+		Status:    review.Status,
+		CreatedAt: review.CreatedAt,
+		UpdatedAt: review.UpdatedAt,
+	}
+}
+
+func MapDTOToReview(dto *dto.ReviewDTO) *entity.Review {
+	return &entity.Review{
+		ID:     dto.ID,
+		BookID: dto.BookID,
+		UserID: dto.UserID,
+		Rating: dto.Rating,
+		Text:   dto.Text,
+		Status: dto.Status,
+	}
+}
@@ -0,0 +1,31 @@
+package mapper
+
+import (
+	"math"
+	"time"
+
+	"github.com/Ablyamitov/simple-rest/internal/store/db/entity"
+	"github.com/Ablyamitov/simple-rest/internal/store/web/dto"
+)
+
+func MapBookLoansToDTO(bookLoans *entity.BookLoans) *dto.BookLoansDTO {
+	return &dto.BookLoansDTO{
+		Book:  MapBookToDTO(&bookLoans.Book),
+		Loans: bookLoans.Loans,
+	}
+}
+
+func MapLoanLengthToDTO(loanLength *entity.LoanLength) *dto.LoanLengthDTO {
+	return &dto.LoanLengthDTO{
+		Category:    loanLength.Category,
+		Returned:    loanLength.Returned,
+		AverageDays: math.Round(loanLength.AverageDays*100) / 100,
+	}
+}
+
+func MapWeeklyReadersToDTO(weeklyReaders *entity.WeeklyReaders) *dto.WeeklyReadersDTO {
+	return &dto.WeeklyReadersDTO{
+		Week:    weeklyReaders.Week.Format(time.DateOnly),
+		Readers: weeklyReaders.Readers,
+	}
+}
@@ -17,6 +17,7 @@ func MapUserToDTO(user *entity.User) *dto.UserDTO {
 		Books:    booksDTO,
 		Password: user.Password,
 		Role:     user.Role,
+		Version:  user.Version,
 	}
 }
 
@@ -33,5 +34,6 @@ func MapDTOToUser(dto *dto.UserDTO) *entity.User {
 		Books:    books,
 		Password: dto.Password,
 		Role:     dto.Role,
+		Version:  dto.Version,
 	}
 }
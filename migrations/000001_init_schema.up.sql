@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id       SERIAL PRIMARY KEY,
+    name     VARCHAR(255) NOT NULL,
+    email    VARCHAR(255) NOT NULL UNIQUE,
+    password VARCHAR(255) NOT NULL,
+    role     VARCHAR(50)  NOT NULL DEFAULT 'user'
+);
+
+CREATE TABLE IF NOT EXISTS books
+(
+    id        SERIAL PRIMARY KEY,
+    title     VARCHAR(255) NOT NULL,
+    author    VARCHAR(255) NOT NULL,
+    available BOOLEAN      NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS user_books
+(
+    user_id     INT       NOT NULL REFERENCES users (id),
+    book_id     INT       NOT NULL REFERENCES books (id),
+    taken_date  TIMESTAMP NOT NULL DEFAULT NOW(),
+    return_date TIMESTAMP,
+    PRIMARY KEY (user_id, book_id)
+);
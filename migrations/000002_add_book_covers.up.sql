@@ -0,0 +1,2 @@
+ALTER TABLE books
+    ADD COLUMN cover_updated_at TIMESTAMPTZ;
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS reviews;
+
+ALTER TABLE books
+    DROP COLUMN IF EXISTS rating_sum,
+    DROP COLUMN IF EXISTS rating_count;
+
+-- История займов не удаляется: вернуть прежний первичный ключ (user_id, book_id) нельзя,
+-- пока у пары есть несколько займов, поэтому вместо него остаётся неуникальный индекс
+DROP INDEX IF EXISTS user_books_user_idx;
+DROP INDEX IF EXISTS user_books_active_book_idx;
+ALTER TABLE user_books
+    DROP COLUMN IF EXISTS id;
+CREATE INDEX IF NOT EXISTS user_books_user_book_idx ON user_books (user_id, book_id);
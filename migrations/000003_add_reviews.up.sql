@@ -0,0 +1,28 @@
+-- Выданные книги больше не удаляются при возврате, поэтому история займов
+-- хранится в user_books, а активным считается займ без return_date
+ALTER TABLE user_books
+    DROP CONSTRAINT IF EXISTS user_books_pkey;
+-- Остаётся после отката этой миграции вместо первичного ключа
+DROP INDEX IF EXISTS user_books_user_book_idx;
+ALTER TABLE user_books
+    ADD COLUMN id SERIAL PRIMARY KEY;
+CREATE UNIQUE INDEX user_books_active_book_idx ON user_books (book_id) WHERE return_date IS NULL;
+CREATE INDEX user_books_user_idx ON user_books (user_id);
+
+ALTER TABLE books
+    ADD COLUMN rating_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN rating_sum   INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS reviews
+(
+    id         SERIAL PRIMARY KEY,
+    book_id    INT         NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+    user_id    INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    rating     SMALLINT    NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    text       TEXT        NOT NULL DEFAULT '',
+    status     VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'hidden')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (book_id, user_id)
+);
+CREATE INDEX reviews_status_idx ON reviews (status, created_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS user_books_book_idx;
+DROP INDEX IF EXISTS books_author_idx;
+DROP INDEX IF EXISTS books_category_idx;
+
+ALTER TABLE books
+    DROP COLUMN IF EXISTS category;
@@ -0,0 +1,6 @@
+ALTER TABLE books
+    ADD COLUMN category VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE INDEX books_category_idx ON books (category);
+CREATE INDEX books_author_idx ON books (author);
+CREATE INDEX user_books_book_idx ON user_books (book_id);
@@ -0,0 +1,2 @@
+DROP MATERIALIZED VIEW IF EXISTS stats_weekly_readers;
+DROP MATERIALIZED VIEW IF EXISTS stats_daily_loans;
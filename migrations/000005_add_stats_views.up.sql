@@ -0,0 +1,25 @@
+-- Ежедневные агрегаты по выдачам: из них считаются "самые популярные",
+-- "средняя длительность займа" и "ни разу не выданные" за любой диапазон дат
+CREATE MATERIALIZED VIEW IF NOT EXISTS stats_daily_loans AS
+SELECT ub.taken_date::date                                                         AS day,
+       ub.book_id,
+       b.category,
+       COUNT(*)                                                                    AS loans,
+       COUNT(ub.return_date)                                                       AS returned,
+       COALESCE(SUM(EXTRACT(EPOCH FROM (ub.return_date - ub.taken_date))), 0)::BIGINT AS returned_seconds
+FROM user_books AS ub
+         JOIN books AS b ON b.id = ub.book_id
+GROUP BY ub.taken_date::date, ub.book_id, b.category;
+
+CREATE UNIQUE INDEX IF NOT EXISTS stats_daily_loans_day_book_idx ON stats_daily_loans (day, book_id);
+CREATE INDEX IF NOT EXISTS stats_daily_loans_category_idx ON stats_daily_loans (category, day);
+
+-- Читатели по неделям хранятся поштучно, т.к. уникальных читателей нельзя сложить из дневных сумм
+CREATE MATERIALIZED VIEW IF NOT EXISTS stats_weekly_readers AS
+SELECT DISTINCT DATE_TRUNC('week', ub.taken_date)::date AS week,
+                b.category,
+                ub.user_id
+FROM user_books AS ub
+         JOIN books AS b ON b.id = ub.book_id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS stats_weekly_readers_idx ON stats_weekly_readers (week, category, user_id);
@@ -0,0 +1,16 @@
+ALTER TABLE user_books
+    DROP CONSTRAINT IF EXISTS user_books_user_id_fkey,
+    DROP CONSTRAINT IF EXISTS user_books_book_id_fkey,
+    ADD CONSTRAINT user_books_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id),
+    ADD CONSTRAINT user_books_book_id_fkey FOREIGN KEY (book_id) REFERENCES books (id);
+
+DROP INDEX IF EXISTS users_email_active_idx;
+ALTER TABLE users
+    ADD CONSTRAINT users_email_key UNIQUE (email);
+
+DROP INDEX IF EXISTS books_deleted_at_idx;
+DROP INDEX IF EXISTS users_deleted_at_idx;
+ALTER TABLE books
+    DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,19 @@
+-- Записи удаляются мягко и окончательно стираются задачей очистки по истечении срока хранения
+ALTER TABLE users
+    ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE books
+    ADD COLUMN deleted_at TIMESTAMPTZ;
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX books_deleted_at_idx ON books (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Email удалённого пользователя можно зарегистрировать заново
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX users_email_active_idx ON users (email) WHERE deleted_at IS NULL;
+
+-- При окончательном удалении история займов уходит вместе с записью
+ALTER TABLE user_books
+    DROP CONSTRAINT IF EXISTS user_books_user_id_fkey,
+    DROP CONSTRAINT IF EXISTS user_books_book_id_fkey,
+    ADD CONSTRAINT user_books_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT user_books_book_id_fkey FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE;
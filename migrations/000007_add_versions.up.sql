@@ -0,0 +1,5 @@
+-- Версия строки растёт при каждом изменении и отдаётся клиентам как ETag
+ALTER TABLE books
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE users
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
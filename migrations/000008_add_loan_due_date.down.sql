@@ -0,0 +1,2 @@
+ALTER TABLE user_books
+    DROP COLUMN IF EXISTS due_date;
@@ -0,0 +1,7 @@
+-- Срок возврата фиксируется при выдаче по правилам, действовавшим в тот момент
+ALTER TABLE user_books
+    ADD COLUMN due_date TIMESTAMP;
+UPDATE user_books
+SET due_date = taken_date + INTERVAL '14 days';
+ALTER TABLE user_books
+    ALTER COLUMN due_date SET NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE books
+    DROP COLUMN IF EXISTS seed_key;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS seed_key;
@@ -0,0 +1,5 @@
+-- Ключ строки, созданной командой seed: повторный запуск обновляет те же строки, и их id не меняются
+ALTER TABLE users
+    ADD COLUMN seed_key VARCHAR(32) UNIQUE;
+ALTER TABLE books
+    ADD COLUMN seed_key VARCHAR(32) UNIQUE;
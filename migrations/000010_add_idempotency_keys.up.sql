@@ -0,0 +1,15 @@
+-- Ответы на запросы с заголовком Idempotency-Key: повтор с тем же ключом получает записанный ответ.
+-- Пока запрос выполняется, status пуст; ключи одного пользователя не пересекаются с ключами другого
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    scope        VARCHAR(32)  NOT NULL,
+    key          VARCHAR(255) NOT NULL,
+    fingerprint  CHAR(64)     NOT NULL,
+    status       SMALLINT,
+    headers      JSONB,
+    body         BYTEA,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    PRIMARY KEY (scope, key)
+);
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
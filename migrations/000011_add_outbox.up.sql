@@ -0,0 +1,16 @@
+-- Доменные события пишутся в одной транзакции с изменением, а задача outbox.relay отправляет их во внешние
+-- системы хотя бы один раз; отправленные события хранятся ещё какое-то время и стираются задачей outbox.purge.
+-- outbox.relay занимает пачку событий до locked_until и отправляет её вне транзакции;
+-- если экземпляр упал, не дослав пачку, по истечении срока её заберёт другой
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id           BIGSERIAL PRIMARY KEY,
+    type         VARCHAR(64) NOT NULL,
+    aggregate_id INT         NOT NULL,
+    payload      JSONB       NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMPTZ,
+    locked_until TIMESTAMPTZ
+);
+CREATE INDEX outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,7 @@
+// Package migrations встраивает SQL-миграции в бинарник, чтобы их не нужно было копировать рядом с ним
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS